@@ -0,0 +1,77 @@
+// Command scopes shows, grants and revokes the scopes a single user gets on
+// top of TOKEN_SCOPES, such as admin for operators. Changes reach the user's
+// access tokens from their next signin or refresh.
+//
+//	go run ./cmd/scopes -email ops@example.com
+//	go run ./cmd/scopes -email ops@example.com -grant admin
+//	go run ./cmd/scopes -email ops@example.com -revoke admin
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/joho/godotenv"
+
+	"github.com/coinbase/identity-service/internal/config"
+	"github.com/coinbase/identity-service/internal/store/backend"
+	"github.com/coinbase/identity-service/internal/validator"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user to change")
+	grant := flag.String("grant", "", "scope to grant")
+	revoke := flag.String("revoke", "", "scope to revoke")
+	flag.Parse()
+
+	if *email == "" || (*grant != "" && *revoke != "") || strings.ContainsAny(*grant+*revoke, " \t\n") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	addr, err := validator.NormalizeEmail(*email)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_ = godotenv.Load()
+	cfg := config.LoadStore()
+	if cfg.StoreDriver == "memory" {
+		log.Fatal("STORE_DRIVER=memory keeps no users between runs; use postgres or sqlite")
+	}
+
+	ctx := context.Background()
+	stores, closeStores, err := backend.Open(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeStores()
+
+	u, err := stores.Users.GetByEmail(ctx, addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if u == nil {
+		log.Fatalf("no user with email %s", addr)
+	}
+
+	changed := true
+	switch {
+	case *grant != "" && !slices.Contains(u.Scopes, *grant):
+		u.Scopes = append(u.Scopes, *grant)
+	case *revoke != "" && slices.Contains(u.Scopes, *revoke):
+		u.Scopes = slices.DeleteFunc(u.Scopes, func(s string) bool { return s == *revoke })
+	default:
+		changed = false
+	}
+	if changed {
+		if err := stores.Users.Update(ctx, u); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Printf("%s: %s\n", u.Email, strings.Join(u.Scopes, " "))
+}
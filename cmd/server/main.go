@@ -2,17 +2,26 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
 	"net/http"
+	"os"
+	"runtime"
 	"time"
 
 	"github.com/joho/godotenv"
 
+	"github.com/coinbase/identity-service/internal/audit"
 	"github.com/coinbase/identity-service/internal/config"
+	"github.com/coinbase/identity-service/internal/lockout"
+	"github.com/coinbase/identity-service/internal/mailer"
+	"github.com/coinbase/identity-service/internal/middleware"
+	"github.com/coinbase/identity-service/internal/ratelimit"
 	"github.com/coinbase/identity-service/internal/server"
 	"github.com/coinbase/identity-service/internal/service"
-	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/internal/store/backend"
 	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/seal"
 	"github.com/coinbase/identity-service/pkg/token"
 )
 
@@ -22,15 +31,104 @@ func main() {
 	cfg := config.Load()
 
 	// ── infrastructure
-	userStore := memory.NewUserStore()
-	hasher := hash.Bcrypt{}
-	tokens := token.NewJWTManager(cfg.JWTSecret, cfg.TokenTTL)
+	stores, closeStores, err := backend.Open(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer closeStores()
+	hasher, err := newHasher(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tokens, err := newTokenManager(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mail, err := newMailer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// ── services
-	authSvc := service.NewAuthService(userStore, hasher, tokens)
+	authOpts := []service.Option{
+		service.WithRefreshTokens(stores.Refresh, cfg.RefreshTokenTTL),
+		service.WithScopes(cfg.TokenScopes...),
+		service.WithLockout(newLockout(cfg)),
+		service.WithHashPool(hashWorkers(cfg), cfg.HashQueueSize, cfg.HashQueueTimeout),
+		service.WithAudit(audit.LogRecorder{}),
+	}
+	if cfg.SignupEnumerationSafe {
+		authOpts = append(authOpts, service.WithEnumerationSafeSignup(mail))
+	}
+	if cfg.EmailVerification != "off" {
+		authOpts = append(authOpts, service.WithEmailVerification(service.EmailVerification{
+			Mailer:           mail,
+			Signer:           token.NewActionSigner([]byte(cfg.ActionTokenSecret)),
+			TTL:              cfg.EmailVerificationTTL,
+			LinkURL:          cfg.EmailVerificationURL,
+			BlockSignin:      cfg.EmailVerification == "block",
+			UnverifiedScopes: cfg.UnverifiedTokenScopes,
+		}))
+	}
+	if cfg.PasswordReset {
+		authOpts = append(authOpts, service.WithPasswordReset(service.PasswordReset{
+			Store:   stores.Resets,
+			Mailer:  mail,
+			TTL:     cfg.PasswordResetTTL,
+			LinkURL: cfg.PasswordResetURL,
+		}))
+	}
+	if cfg.EmailChange {
+		authOpts = append(authOpts, service.WithEmailChange(service.EmailChange{
+			Mailer:     mail,
+			Signer:     token.NewActionSigner([]byte(cfg.ActionTokenSecret)),
+			TTL:        cfg.EmailChangeTTL,
+			ConfirmURL: cfg.EmailChangeConfirmURL,
+			CancelURL:  cfg.EmailChangeCancelURL,
+		}))
+	}
+	if cfg.MFA {
+		box, err := seal.New([]byte(cfg.MFAEncryptionKey))
+		if err != nil {
+			log.Fatalf("MFA_ENCRYPTION_KEY: %v", err)
+		}
+		authOpts = append(authOpts, service.WithMFA(service.MFA{
+			Box:          box,
+			Signer:       token.NewActionSigner([]byte(cfg.ActionTokenSecret)),
+			Issuer:       cfg.MFAIssuer,
+			ChallengeTTL: cfg.MFAChallengeTTL,
+			RecoveryKey:  []byte(cfg.MFAEncryptionKey),
+		}))
+	}
+	if cfg.PasswordHistory > 0 {
+		authOpts = append(authOpts, service.WithPasswordHistory(stores.History, cfg.PasswordHistory))
+	}
+	authSvc := service.NewAuthService(stores.Users, hasher, tokens, authOpts...)
+
+	// ── background jobs
+	if cfg.HashAuditInterval > 0 {
+		go auditPasswordHashes(authSvc, cfg.HashAuditInterval)
+	}
 
 	// ── HTTP server
-	r := server.NewRouter(authSvc, tokens)
+	routerOpts, err := newRouterOptions(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := server.NewRouter(authSvc, tokens, routerOpts...)
+
+	// Metrics stay off the public listener.
+	if cfg.MetricsAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/debug/vars", expvar.Handler())
+			log.Printf("metrics listening on %s", cfg.MetricsAddr)
+			if err := http.ListenAndServe(cfg.MetricsAddr, mux); err != nil {
+				log.Printf("metrics listener: %v", err)
+			}
+		}()
+	}
 
 	srv := &http.Server{
 		Addr:              cfg.HTTPAddr,
@@ -45,3 +143,149 @@ func main() {
 
 	<-context.Background().Done()
 }
+
+// newMailer builds the mailer selected by cfg.Mailer.
+func newMailer(cfg config.Config) (mailer.Mailer, error) {
+	if cfg.Mailer == "file" {
+		return mailer.NewFileMailer(cfg.MailerDir)
+	}
+	return mailer.LogMailer{}, nil
+}
+
+// newRouterOptions applies the trusted proxies, per-route rate limits and
+// token requirements from cfg.
+func newRouterOptions(cfg config.Config) ([]server.Option, error) {
+	proxies, err := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	opts := []server.Option{server.WithTrustedProxies(proxies)}
+	routes := []struct {
+		path  string
+		limit string
+		key   middleware.KeyFunc
+	}{
+		{"/signup", cfg.RateLimitSignupIP, middleware.KeyByIP},
+		{"/signup", cfg.RateLimitSignupEmail, middleware.KeyByEmail},
+		{"/signin", cfg.RateLimitSigninIP, middleware.KeyByIP},
+		{"/signin", cfg.RateLimitSigninEmail, middleware.KeyByEmail},
+		{"/signin/mfa", cfg.RateLimitSigninMFAIP, middleware.KeyByIP},
+		{"/token/refresh", cfg.RateLimitRefreshIP, middleware.KeyByIP},
+		{"/verify-email", cfg.RateLimitVerifyIP, middleware.KeyByIP},
+		{"/verify-email/resend", cfg.RateLimitResendIP, middleware.KeyByIP},
+		{"/verify-email/resend", cfg.RateLimitResendEmail, middleware.KeyByEmail},
+		{"/password/forgot", cfg.RateLimitForgotIP, middleware.KeyByIP},
+		{"/password/forgot", cfg.RateLimitForgotEmail, middleware.KeyByEmail},
+		{"/password/reset", cfg.RateLimitResetIP, middleware.KeyByIP},
+		{"/email-change/confirm", cfg.RateLimitEmailChange, middleware.KeyByIP},
+		{"/email-change/cancel", cfg.RateLimitEmailChange, middleware.KeyByIP},
+	}
+	for _, rt := range routes {
+		limit, err := ratelimit.ParseLimit(rt.limit)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, server.WithRateLimit(rt.path, limit, rt.key))
+	}
+	if cfg.AdminRequireMFA {
+		opts = append(opts, server.WithAdminMFA())
+	}
+	if cfg.JWTRequiredAudience != "" {
+		opts = append(opts, server.WithRequiredAudience(cfg.JWTRequiredAudience))
+	}
+	return opts, nil
+}
+
+// newLockout builds the failed signin limiter from cfg.
+func newLockout(cfg config.Config) *lockout.Limiter {
+	account := lockout.Policy{
+		MaxFailures:     cfg.LockoutMaxFailures,
+		LockoutDuration: cfg.LockoutDuration,
+		BaseDelay:       cfg.LockoutBaseDelay,
+		MaxDelay:        cfg.LockoutMaxDelay,
+	}
+	// Many users can share an address behind NAT, so IPs are only locked at
+	// their own, higher threshold and never slowed down before it.
+	ip := lockout.Policy{
+		MaxFailures:     cfg.LockoutIPMaxFailures,
+		LockoutDuration: cfg.LockoutDuration,
+	}
+	return lockout.NewLimiter(lockout.NewMemoryCounter(), account, ip, cfg.LockoutWindow)
+}
+
+// auditPasswordHashes refreshes the password hash metrics every interval.
+func auditPasswordHashes(authSvc *service.AuthService, interval time.Duration) {
+	for {
+		total, legacy, err := authSvc.AuditPasswordHashes(context.Background())
+		if err != nil {
+			log.Printf("password hash audit: %v", err)
+		} else {
+			log.Printf("password hash audit: %d of %d hashes need rehash", legacy, total)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// hashWorkers returns cfg.HashWorkers, defaulting to all CPUs but one so
+// hashing never starves health checks and token verification.
+func hashWorkers(cfg config.Config) int {
+	if cfg.HashWorkers > 0 {
+		return cfg.HashWorkers
+	}
+	return max(runtime.GOMAXPROCS(0)-1, 1)
+}
+
+// newHasher hashes new passwords with cfg.PasswordHashAlg, peppered when
+// cfg.PasswordPeppers is set, while still verifying hashes made by the other
+// supported algorithm and by the legacy formats accepted from imports.
+func newHasher(cfg config.Config) (hash.Hasher, error) {
+	bcryptHasher := hash.Bcrypt{Cost: cfg.BcryptCost}
+	argon2Hasher := hash.Argon2id{
+		Memory:      uint32(cfg.Argon2Memory),
+		Time:        uint32(cfg.Argon2Time),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	}
+	imported := []hash.Verifier{hash.PBKDF2{}, hash.Scrypt{}, hash.SaltedSHA256{}}
+	var hasher hash.Hasher = hash.Fallback{Primary: bcryptHasher, Legacy: append([]hash.Verifier{argon2Hasher}, imported...)}
+	if cfg.PasswordHashAlg == "argon2id" {
+		hasher = hash.Fallback{Primary: argon2Hasher, Legacy: append([]hash.Verifier{bcryptHasher}, imported...)}
+	}
+	if cfg.PasswordPeppers == "" {
+		return hasher, nil
+	}
+	peppers, err := hash.ParsePeppers(cfg.PasswordPeppers)
+	if err != nil {
+		return nil, err
+	}
+	return hash.NewPeppered(hasher, peppers)
+}
+
+// newTokenManager builds the access token manager from the rotation
+// schedule in cfg.JWTKeyRingFile, or from the single key for cfg.JWTAlg.
+func newTokenManager(cfg config.Config) (*token.JWTManager, error) {
+	opts := []token.ManagerOption{
+		token.WithIssuer(cfg.JWTIssuer),
+		token.WithDefaultAudience(cfg.JWTAudience...),
+		token.WithLeeway(cfg.JWTLeeway),
+	}
+	if cfg.JWTKeyRingFile != "" {
+		// Retired keys must outlive every token they signed, leeway included.
+		ring, err := token.LoadKeyRingFile(cfg.JWTKeyRingFile, cfg.TokenTTL+cfg.JWTLeeway)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewJWTManagerWithKeyRing(ring, cfg.TokenTTL, opts...)
+	}
+	if cfg.JWTAlg == "HS256" {
+		return token.NewJWTManagerWithKey(token.NewHMACKey(cfg.JWTKeyID, []byte(cfg.JWTSecret)), cfg.TokenTTL, opts...)
+	}
+	pemBytes, err := os.ReadFile(cfg.JWTPrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := token.ParsePrivateKeyPEM(cfg.JWTKeyID, cfg.JWTAlg, pemBytes)
+	if err != nil {
+		return nil, err
+	}
+	return token.NewJWTManagerWithKey(key, cfg.TokenTTL, opts...)
+}
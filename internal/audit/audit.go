@@ -0,0 +1,68 @@
+// Package audit records security-relevant account events, such as a
+// second factor being bypassed with a recovery code.
+package audit
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Actions recorded by the service.
+const (
+	ActionRecoveryCodeUsed         = "mfa.recovery_code.used"
+	ActionRecoveryCodesRegenerated = "mfa.recovery_codes.regenerated"
+)
+
+type Event struct {
+	Time   time.Time
+	Action string
+	UserID uuid.UUID
+	IP     string // client address, empty when unknown
+	Detail string // free-form context; never secrets
+}
+
+// Recorder stores events. Implementations must be safe for concurrent use.
+type Recorder interface {
+	Record(ctx context.Context, e Event) error
+}
+
+// LogRecorder writes events to the process log, one line each, for
+// collection by the log pipeline.
+type LogRecorder struct{}
+
+func (LogRecorder) Record(ctx context.Context, e Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	log.Printf("audit time=%s action=%s user=%s ip=%s detail=%q",
+		e.Time.UTC().Format(time.RFC3339), e.Action, e.UserID, e.IP, e.Detail)
+	return nil
+}
+
+// MemoryRecorder keeps events in memory, for tests.
+type MemoryRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (m *MemoryRecorder) Record(ctx context.Context, e Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, e)
+	return nil
+}
+
+// Events returns the events recorded so far, oldest first.
+func (m *MemoryRecorder) Events() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.events)
+}
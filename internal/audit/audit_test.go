@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLogRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(prev)
+
+	id := uuid.New()
+	err := LogRecorder{}.Record(context.Background(), Event{
+		Time:   time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Action: ActionRecoveryCodeUsed,
+		UserID: id,
+		IP:     "192.0.2.1",
+		Detail: "9 remaining",
+	})
+	if err != nil {
+		t.Fatalf("Record() failed: %v", err)
+	}
+	for _, want := range []string{"time=2025-01-02T03:04:05Z", "action=mfa.recovery_code.used",
+		"user=" + id.String(), "ip=192.0.2.1", `detail="9 remaining"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected log line to contain %q, got %q", want, buf.String())
+		}
+	}
+}
+
+func TestMemoryRecorder(t *testing.T) {
+	m := &MemoryRecorder{}
+	for _, action := range []string{ActionRecoveryCodesRegenerated, ActionRecoveryCodeUsed} {
+		if err := m.Record(context.Background(), Event{Action: action}); err != nil {
+			t.Fatalf("Record() failed: %v", err)
+		}
+	}
+	events := m.Events()
+	if len(events) != 2 || events[0].Action != ActionRecoveryCodesRegenerated || events[1].Action != ActionRecoveryCodeUsed {
+		t.Fatalf("Expected both events in order, got %+v", events)
+	}
+
+	// The returned slice is a copy
+	events[0].Action = "changed"
+	if m.Events()[0].Action != ActionRecoveryCodesRegenerated {
+		t.Error("recorded event changed through the returned slice")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Record(ctx, Event{}); err == nil {
+		t.Error("Record() with canceled context should fail")
+	}
+}
@@ -3,25 +3,401 @@ package config
 import (
 	"log"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	HTTPAddr  string
-	JWTSecret string
-	TokenTTL  time.Duration
+	HTTPAddr string
+	// MetricsAddr serves expvar metrics on /debug/vars; empty, the default,
+	// disables it.
+	MetricsAddr string
+
+	// JWTAlg selects how access tokens are signed: HS256 with JWTSecret, or
+	// RS256, ES256 or EdDSA with the PEM key at JWTPrivateKeyFile.
+	JWTAlg            string
+	JWTSecret         string
+	JWTPrivateKeyFile string
+	JWTKeyID          string
+	// JWTKeyRingFile, when set, replaces the single key above with a JSON
+	// rotation schedule (see token.LoadKeyRingFile).
+	JWTKeyRingFile string
+	TokenTTL       time.Duration
+
+	// JWTIssuer is set as iss on every token and required when verifying.
+	JWTIssuer string
+	// JWTAudience is the default aud of issued tokens.
+	JWTAudience []string
+	// JWTRequiredAudience must be in the aud of tokens presented to this
+	// service's own endpoints; empty accepts any audience.
+	JWTRequiredAudience string
+	// JWTLeeway tolerates clock skew when checking exp, nbf and iat.
+	JWTLeeway time.Duration
+	// TokenScopes are granted to every access token.
+	TokenScopes []string
+
+	RefreshTokenTTL time.Duration
+
+	// PasswordHashAlg selects how new passwords are hashed: "bcrypt" or
+	// "argon2id". Hashes made by the other algorithm keep verifying.
+	PasswordHashAlg   string
+	BcryptCost        int
+	Argon2Memory      int // KiB
+	Argon2Time        int
+	Argon2Parallelism int
+	// PasswordPeppers lists "version:secret" HMAC peppers, from
+	// PASSWORD_PEPPERS or the file at PASSWORD_PEPPERS_FILE. The highest
+	// version peppers new hashes; empty disables peppering.
+	PasswordPeppers string
+	// HashWorkers bounds concurrent password hashing; zero leaves one CPU
+	// free. Up to HashQueueSize requests wait for a worker, each for at most
+	// HashQueueTimeout, before requests are shed with 503.
+	HashWorkers      int
+	HashQueueSize    int
+	HashQueueTimeout time.Duration
+	// HashAuditInterval is how often stored hashes are checked against the
+	// current policy for the password_hashes_legacy metric; zero disables.
+	HashAuditInterval time.Duration
+
+	// SignupEnumerationSafe makes /signup answer identically for new and
+	// registered emails and mail the existing owner instead.
+	SignupEnumerationSafe bool
+	// Mailer selects how account emails are delivered: "log", or "file"
+	// to write one file per message into MailerDir.
+	Mailer    string
+	MailerDir string
+
+	// EmailVerification is "off", "restrict" (unverified users only get
+	// UnverifiedTokenScopes) or "block" (unverified users cannot sign in).
+	EmailVerification    string
+	EmailVerificationTTL time.Duration
+	// EmailVerificationURL is the page the emailed token is appended to.
+	EmailVerificationURL  string
+	UnverifiedTokenScopes []string
+	// ActionTokenSecret signs one-time links such as email verification,
+	// from ACTION_TOKEN_SECRET or ACTION_TOKEN_SECRET_FILE.
+	ActionTokenSecret string
+
+	// PasswordReset enables /password/forgot and /password/reset, which
+	// mail a single-use link valid for PasswordResetTTL.
+	PasswordReset    bool
+	PasswordResetTTL time.Duration
+	// PasswordResetURL is the page the emailed token is appended to.
+	PasswordResetURL string
+	// PasswordHistory is how many replaced passwords per user are refused
+	// as new ones, besides the current password; zero disables history.
+	PasswordHistory int
+
+	// EmailChange enables /me/email, which mails a confirmation link valid
+	// for EmailChangeTTL to the new address and a cancel link to the old one.
+	EmailChange    bool
+	EmailChangeTTL time.Duration
+	// EmailChangeConfirmURL and EmailChangeCancelURL are the pages the
+	// emailed tokens are appended to.
+	EmailChangeConfirmURL string
+	EmailChangeCancelURL  string
+
+	// MFA enables TOTP enrollment under /me/mfa and the second signin step
+	// at /signin/mfa, which must follow /signin within MFAChallengeTTL.
+	MFA             bool
+	MFAChallengeTTL time.Duration
+	// MFAEncryptionKey encrypts TOTP secrets at rest, from
+	// MFA_ENCRYPTION_KEY or MFA_ENCRYPTION_KEY_FILE.
+	MFAEncryptionKey string
+	// MFAIssuer names the service in authenticator apps.
+	MFAIssuer string
+	// AdminRequireMFA refuses admin endpoints to tokens from password-only
+	// signins.
+	AdminRequireMFA bool
+
+	// Failed signin throttling. Each failure for an email delays the next
+	// attempt by LockoutBaseDelay, doubling up to LockoutMaxDelay; after
+	// LockoutMaxFailures the account is locked for LockoutDuration, and
+	// after LockoutIPMaxFailures so is the client IP. Failures are forgotten
+	// LockoutWindow after the last one. Zero thresholds disable locking.
+	LockoutCounter       string // "memory"
+	LockoutMaxFailures   int
+	LockoutIPMaxFailures int
+	LockoutBaseDelay     time.Duration
+	LockoutMaxDelay      time.Duration
+	LockoutDuration      time.Duration
+	LockoutWindow        time.Duration
+
+	// TrustedProxies lists CIDR prefixes or addresses whose X-Forwarded-For
+	// header is believed when resolving client IPs.
+	TrustedProxies []string
+	// Per-route token-bucket limits as "N/duration", e.g. "10/1m", keyed
+	// by client IP or by the email in the request; "0" disables a limit.
+	RateLimitSignupIP    string
+	RateLimitSignupEmail string
+	RateLimitSigninIP    string
+	RateLimitSigninEmail string
+	RateLimitRefreshIP   string
+	RateLimitVerifyIP    string
+	RateLimitResendIP    string
+	RateLimitResendEmail string
+	RateLimitForgotIP    string
+	RateLimitForgotEmail string
+	RateLimitResetIP     string
+	// RateLimitEmailChange covers /email-change/confirm and /cancel per IP.
+	RateLimitEmailChange string
+	RateLimitSigninMFAIP string
+
+	// StoreDriver selects the UserStore backend: "memory", "postgres" or "sqlite".
+	StoreDriver string
+	DatabaseURL string
+	SQLitePath  string
+
+	// Connection pool settings for SQL-backed stores.
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
 }
 
+// Load reads the service configuration from the environment and exits if it
+// is incomplete or invalid.
 func Load() Config {
-	ttl, err := strconv.Atoi(getEnv("TOKEN_TTL_SECONDS", "900"))
-	if err != nil {
-		log.Fatalf("invalid TOKEN_TTL_SECONDS: %v", err)
-	}
+	cfg := read()
+	cfg.validateTokens()
+	cfg.validatePasswordHashing()
+	cfg.validateMailer()
+	cfg.validateEmailVerification()
+	cfg.validatePasswordReset()
+	cfg.validateEmailChange()
+	cfg.validateMFA()
+	cfg.validateLockout()
+	cfg.validateStore()
+	return cfg
+}
+
+// LoadStore is Load for tools that only touch storage, such as cmd/import:
+// token signing settings are not required.
+func LoadStore() Config {
+	cfg := read()
+	cfg.validateStore()
+	return cfg
+}
+
+func read() Config {
 	return Config{
-		HTTPAddr:  getEnv("HTTP_ADDR", ":8080"),
-		JWTSecret: getEnvOrPanic("JWT_SECRET"),
-		TokenTTL:  time.Duration(ttl) * time.Second,
+		HTTPAddr:              getEnv("HTTP_ADDR", ":8080"),
+		MetricsAddr:           getEnv("METRICS_ADDR", ""),
+		JWTAlg:                getEnv("JWT_ALG", "HS256"),
+		JWTSecret:             os.Getenv("JWT_SECRET"),
+		JWTPrivateKeyFile:     os.Getenv("JWT_PRIVATE_KEY_FILE"),
+		JWTKeyID:              getEnv("JWT_KEY_ID", "hs256"),
+		JWTKeyRingFile:        os.Getenv("JWT_KEYRING_FILE"),
+		TokenTTL:              time.Duration(getEnvInt("TOKEN_TTL_SECONDS", 900)) * time.Second,
+		JWTIssuer:             os.Getenv("JWT_ISSUER"),
+		JWTAudience:           getEnvList("JWT_AUDIENCE", nil),
+		JWTRequiredAudience:   os.Getenv("JWT_REQUIRED_AUDIENCE"),
+		JWTLeeway:             time.Duration(getEnvInt("JWT_LEEWAY_SECONDS", 30)) * time.Second,
+		TokenScopes:           getEnvList("TOKEN_SCOPES", []string{"profile"}),
+		RefreshTokenTTL:       time.Duration(getEnvInt("REFRESH_TOKEN_TTL_SECONDS", 30*24*3600)) * time.Second,
+		PasswordHashAlg:       getEnv("PASSWORD_HASH_ALG", "bcrypt"),
+		BcryptCost:            getEnvInt("BCRYPT_COST", 10),
+		Argon2Memory:          getEnvInt("ARGON2_MEMORY_KIB", 64*1024),
+		Argon2Time:            getEnvInt("ARGON2_TIME", 3),
+		Argon2Parallelism:     getEnvInt("ARGON2_PARALLELISM", 4),
+		PasswordPeppers:       getEnvOrFile("PASSWORD_PEPPERS"),
+		HashWorkers:           getEnvInt("HASH_WORKERS", 0),
+		HashQueueSize:         getEnvInt("HASH_QUEUE_SIZE", 64),
+		HashQueueTimeout:      time.Duration(getEnvInt("HASH_QUEUE_TIMEOUT_MS", 1000)) * time.Millisecond,
+		HashAuditInterval:     time.Duration(getEnvInt("HASH_AUDIT_INTERVAL_SECONDS", 3600)) * time.Second,
+		SignupEnumerationSafe: getEnvBool("SIGNUP_ENUMERATION_SAFE", false),
+		Mailer:                getEnv("MAILER", "log"),
+		MailerDir:             getEnv("MAILER_DIR", "mail"),
+		EmailVerification:     getEnv("EMAIL_VERIFICATION", "off"),
+		EmailVerificationTTL:  time.Duration(getEnvInt("EMAIL_VERIFICATION_TTL_SECONDS", 24*3600)) * time.Second,
+		EmailVerificationURL:  getEnv("EMAIL_VERIFICATION_URL", "http://localhost:8080/verify-email?token="),
+		UnverifiedTokenScopes: getEnvList("UNVERIFIED_TOKEN_SCOPES", nil),
+		ActionTokenSecret:     getEnvOrFile("ACTION_TOKEN_SECRET"),
+		PasswordReset:         getEnvBool("PASSWORD_RESET", false),
+		PasswordResetTTL:      time.Duration(getEnvInt("PASSWORD_RESET_TTL_SECONDS", 3600)) * time.Second,
+		PasswordResetURL:      getEnv("PASSWORD_RESET_URL", "http://localhost:8080/reset-password?token="),
+		EmailChange:           getEnvBool("EMAIL_CHANGE", false),
+		EmailChangeTTL:        time.Duration(getEnvInt("EMAIL_CHANGE_TTL_SECONDS", 86400)) * time.Second,
+		EmailChangeConfirmURL: getEnv("EMAIL_CHANGE_CONFIRM_URL", "http://localhost:8080/confirm-email-change?token="),
+		EmailChangeCancelURL:  getEnv("EMAIL_CHANGE_CANCEL_URL", "http://localhost:8080/cancel-email-change?token="),
+		MFA:                   getEnvBool("MFA", false),
+		MFAChallengeTTL:       time.Duration(getEnvInt("MFA_CHALLENGE_TTL_SECONDS", 300)) * time.Second,
+		MFAEncryptionKey:      getEnvOrFile("MFA_ENCRYPTION_KEY"),
+		MFAIssuer:             getEnv("MFA_ISSUER", "Identity Service"),
+		AdminRequireMFA:       getEnvBool("ADMIN_REQUIRE_MFA", false),
+		PasswordHistory:       getEnvInt("PASSWORD_HISTORY", 5),
+		LockoutCounter:        getEnv("LOCKOUT_COUNTER", "memory"),
+		LockoutMaxFailures:    getEnvInt("LOCKOUT_MAX_FAILURES", 5),
+		LockoutIPMaxFailures:  getEnvInt("LOCKOUT_IP_MAX_FAILURES", 100),
+		LockoutBaseDelay:      time.Duration(getEnvInt("LOCKOUT_BASE_DELAY_MS", 1000)) * time.Millisecond,
+		LockoutMaxDelay:       time.Duration(getEnvInt("LOCKOUT_MAX_DELAY_SECONDS", 60)) * time.Second,
+		LockoutDuration:       time.Duration(getEnvInt("LOCKOUT_DURATION_SECONDS", 900)) * time.Second,
+		LockoutWindow:         time.Duration(getEnvInt("LOCKOUT_WINDOW_SECONDS", 3600)) * time.Second,
+		TrustedProxies:        getEnvList("TRUSTED_PROXIES", nil),
+		RateLimitSignupIP:     getEnv("RATE_LIMIT_SIGNUP_IP", "10/1m"),
+		RateLimitSignupEmail:  getEnv("RATE_LIMIT_SIGNUP_EMAIL", "3/1m"),
+		RateLimitSigninIP:     getEnv("RATE_LIMIT_SIGNIN_IP", "30/1m"),
+		RateLimitSigninEmail:  getEnv("RATE_LIMIT_SIGNIN_EMAIL", "10/1m"),
+		RateLimitRefreshIP:    getEnv("RATE_LIMIT_REFRESH_IP", "60/1m"),
+		RateLimitVerifyIP:     getEnv("RATE_LIMIT_VERIFY_IP", "30/1m"),
+		RateLimitResendIP:     getEnv("RATE_LIMIT_RESEND_IP", "10/1m"),
+		RateLimitResendEmail:  getEnv("RATE_LIMIT_RESEND_EMAIL", "3/1h"),
+		RateLimitForgotIP:     getEnv("RATE_LIMIT_FORGOT_IP", "10/1m"),
+		RateLimitForgotEmail:  getEnv("RATE_LIMIT_FORGOT_EMAIL", "3/1h"),
+		RateLimitResetIP:      getEnv("RATE_LIMIT_RESET_IP", "30/1m"),
+		RateLimitEmailChange:  getEnv("RATE_LIMIT_EMAIL_CHANGE_IP", "30/1m"),
+		RateLimitSigninMFAIP:  getEnv("RATE_LIMIT_SIGNIN_MFA_IP", "30/1m"),
+		StoreDriver:           getEnv("STORE_DRIVER", "memory"),
+		DatabaseURL:           os.Getenv("DATABASE_URL"),
+		SQLitePath:            getEnv("SQLITE_PATH", "identity.db"),
+		DBMaxOpenConns:        getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:        getEnvInt("DB_MAX_IDLE_CONNS", 25),
+		DBConnMaxLifetime:     time.Duration(getEnvInt("DB_CONN_MAX_LIFETIME_SECONDS", 1800)) * time.Second,
+		DBConnMaxIdleTime:     time.Duration(getEnvInt("DB_CONN_MAX_IDLE_SECONDS", 300)) * time.Second,
+	}
+}
+
+func (cfg Config) validateTokens() {
+	switch cfg.JWTAlg {
+	case "HS256":
+		if cfg.JWTKeyRingFile != "" {
+			break
+		}
+		if cfg.JWTSecret == "" {
+			log.Fatalf("missing required env JWT_SECRET")
+		}
+	case "RS256", "ES256", "EdDSA":
+		if cfg.JWTPrivateKeyFile == "" && cfg.JWTKeyRingFile == "" {
+			log.Fatalf("missing required env JWT_PRIVATE_KEY_FILE for JWT_ALG=%s", cfg.JWTAlg)
+		}
+	default:
+		log.Fatalf("invalid JWT_ALG: %q", cfg.JWTAlg)
+	}
+	// Tokens for the required audience must come from this service too.
+	if cfg.JWTRequiredAudience != "" && !slices.Contains(cfg.JWTAudience, cfg.JWTRequiredAudience) {
+		log.Fatalf("JWT_AUDIENCE must include JWT_REQUIRED_AUDIENCE %q", cfg.JWTRequiredAudience)
+	}
+	if cfg.JWTIssuer == "" {
+		log.Printf("warning: JWT_ISSUER is not set; tokens carry no iss and tokens from any issuer sharing the key are accepted")
+	}
+	// admin is granted per user with cmd/scopes; putting it here would make
+	// every account an administrator.
+	if slices.Contains(cfg.TokenScopes, "admin") || slices.Contains(cfg.UnverifiedTokenScopes, "admin") {
+		log.Fatalf("TOKEN_SCOPES and UNVERIFIED_TOKEN_SCOPES must not include admin; grant it per user with cmd/scopes")
+	}
+}
+
+func (cfg Config) validatePasswordHashing() {
+	switch cfg.PasswordHashAlg {
+	case "bcrypt":
+		if cfg.BcryptCost < 4 || cfg.BcryptCost > 31 {
+			log.Fatalf("invalid BCRYPT_COST: %d", cfg.BcryptCost)
+		}
+	case "argon2id":
+		// Upper bounds match those pkg/hash enforces when decoding a hash.
+		if cfg.Argon2Memory < 8*cfg.Argon2Parallelism || cfg.Argon2Memory > 1<<20 ||
+			cfg.Argon2Time < 1 || cfg.Argon2Time > 16 ||
+			cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 16 {
+			log.Fatalf("invalid ARGON2_* parameters: m=%d t=%d p=%d",
+				cfg.Argon2Memory, cfg.Argon2Time, cfg.Argon2Parallelism)
+		}
+	default:
+		log.Fatalf("invalid PASSWORD_HASH_ALG: %q", cfg.PasswordHashAlg)
+	}
+	if cfg.HashWorkers < 0 || cfg.HashQueueSize < 0 || cfg.HashQueueTimeout < 0 {
+		log.Fatalf("invalid HASH_WORKERS, HASH_QUEUE_SIZE or HASH_QUEUE_TIMEOUT_MS")
+	}
+}
+
+func (cfg Config) validateMailer() {
+	switch cfg.Mailer {
+	case "log", "file":
+	default:
+		log.Fatalf("invalid MAILER: %q", cfg.Mailer)
+	}
+}
+
+func (cfg Config) validateEmailVerification() {
+	switch cfg.EmailVerification {
+	case "off":
+		return
+	case "restrict", "block":
+	default:
+		log.Fatalf("invalid EMAIL_VERIFICATION: %q", cfg.EmailVerification)
+	}
+	if len(cfg.ActionTokenSecret) < 32 {
+		log.Fatalf("ACTION_TOKEN_SECRET must be at least 32 bytes for EMAIL_VERIFICATION=%s", cfg.EmailVerification)
+	}
+	if cfg.EmailVerificationTTL <= 0 {
+		log.Fatalf("invalid EMAIL_VERIFICATION_TTL_SECONDS")
+	}
+}
+
+func (cfg Config) validatePasswordReset() {
+	if cfg.PasswordReset && cfg.PasswordResetTTL <= 0 {
+		log.Fatalf("invalid PASSWORD_RESET_TTL_SECONDS")
+	}
+	if cfg.PasswordHistory < 0 {
+		log.Fatalf("invalid PASSWORD_HISTORY: %d", cfg.PasswordHistory)
+	}
+}
+
+func (cfg Config) validateEmailChange() {
+	if !cfg.EmailChange {
+		return
+	}
+	if len(cfg.ActionTokenSecret) < 32 {
+		log.Fatalf("ACTION_TOKEN_SECRET must be at least 32 bytes for EMAIL_CHANGE")
+	}
+	if cfg.EmailChangeTTL <= 0 {
+		log.Fatalf("invalid EMAIL_CHANGE_TTL_SECONDS")
+	}
+}
+
+func (cfg Config) validateMFA() {
+	if !cfg.MFA {
+		if cfg.AdminRequireMFA {
+			log.Fatalf("ADMIN_REQUIRE_MFA needs MFA=true")
+		}
+		return
+	}
+	if len(cfg.ActionTokenSecret) < 32 {
+		log.Fatalf("ACTION_TOKEN_SECRET must be at least 32 bytes for MFA")
+	}
+	if len(cfg.MFAEncryptionKey) < 32 {
+		log.Fatalf("MFA_ENCRYPTION_KEY must be at least 32 bytes")
+	}
+	if cfg.MFAChallengeTTL <= 0 {
+		log.Fatalf("invalid MFA_CHALLENGE_TTL_SECONDS")
+	}
+}
+
+func (cfg Config) validateLockout() {
+	if cfg.LockoutCounter != "memory" {
+		log.Fatalf("invalid LOCKOUT_COUNTER: %q", cfg.LockoutCounter)
+	}
+	if cfg.LockoutMaxFailures < 0 || cfg.LockoutIPMaxFailures < 0 ||
+		cfg.LockoutBaseDelay < 0 || cfg.LockoutMaxDelay < cfg.LockoutBaseDelay {
+		log.Fatalf("invalid LOCKOUT_* thresholds or delays")
+	}
+	// The history must outlive the lock, or a locked key would be forgotten
+	// and start over with a clean slate.
+	if cfg.LockoutWindow < cfg.LockoutDuration || cfg.LockoutWindow < cfg.LockoutMaxDelay {
+		log.Fatalf("LOCKOUT_WINDOW_SECONDS must be at least LOCKOUT_DURATION_SECONDS and LOCKOUT_MAX_DELAY_SECONDS")
+	}
+}
+
+func (cfg Config) validateStore() {
+	switch cfg.StoreDriver {
+	case "memory", "sqlite":
+	case "postgres":
+		if cfg.DatabaseURL == "" {
+			log.Fatalf("missing required env DATABASE_URL for STORE_DRIVER=postgres")
+		}
+	default:
+		log.Fatalf("invalid STORE_DRIVER: %q", cfg.StoreDriver)
 	}
 }
 
@@ -31,10 +407,59 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
-func getEnvOrPanic(key string) string {
+
+func getEnvInt(key string, fallback int) int {
 	v := os.Getenv(key)
 	if v == "" {
-		log.Fatalf("missing required env %s", key)
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return b
+}
+
+// getEnvList splits a comma-separated variable, dropping empty items.
+func getEnvList(key string, fallback []string) []string {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvOrFile returns key, or the contents of the file named by key_FILE
+// so secrets can be mounted rather than placed in the environment.
+func getEnvOrFile(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return ""
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("read %s_FILE: %v", key, err)
 	}
-	return v
+	return string(b)
 }
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/coinbase/identity-service/internal/service"
+)
+
+// AdminHandler serves account administration for tokens holding the admin
+// scope.
+type AdminHandler struct {
+	auth *service.AuthService
+}
+
+func NewAdminHandler(a *service.AuthService) *AdminHandler {
+	return &AdminHandler{auth: a}
+}
+
+// Unlock lifts the signin lockout on the user named by the {id} route
+// variable.
+func (h *AdminHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, `{"error":"invalid user id"}`, http.StatusBadRequest)
+		return
+	}
+	err = h.auth.Unlock(r.Context(), id)
+	if errors.Is(err, service.ErrUserNotFound) {
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/service"
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+func setupAdminHandler() (*AdminHandler, *memory.UserStore) {
+	users := memory.NewUserStore()
+	authSvc := service.NewAuthService(users, hash.Bcrypt{},
+		token.NewJWTManager("test-secret-key", 15*time.Minute))
+	return NewAdminHandler(authSvc), users
+}
+
+func unlockRequest(id string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/"+id+"/unlock", nil)
+	return mux.SetURLVars(req, map[string]string{"id": id})
+}
+
+func TestAdminHandler_Unlock(t *testing.T) {
+	handler, users := setupAdminHandler()
+	ctx := context.Background()
+
+	until := time.Now().Add(time.Hour)
+	u := &model.User{Email: "test@example.com", Password: "x", LockedUntil: &until}
+	if err := users.Create(ctx, u); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	handler.Unlock(w, unlockRequest(u.ID.String()))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	got, _ := users.GetByID(ctx, u.ID)
+	if got.LockedUntil != nil {
+		t.Errorf("Expected LockedUntil to be cleared, got %v", got.LockedUntil)
+	}
+}
+
+func TestAdminHandler_UnlockErrors(t *testing.T) {
+	handler, _ := setupAdminHandler()
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{"not-a-uuid", http.StatusBadRequest},
+		{uuid.NewString(), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.Unlock(w, unlockRequest(tt.id))
+		if w.Code != tt.want {
+			t.Errorf("Unlock(%s): expected status %d, got %d", tt.id, tt.want, w.Code)
+		}
+	}
+}
@@ -1,11 +1,19 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/middleware"
+	"github.com/coinbase/identity-service/internal/model"
 	"github.com/coinbase/identity-service/internal/service"
 	"github.com/coinbase/identity-service/internal/validator"
+	"github.com/coinbase/identity-service/pkg/token"
 )
 
 type AuthHandler struct {
@@ -28,16 +36,30 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.auth.Signup(r.Context(), req.Email, req.Password)
-	if err != nil {
+	tokens, err := h.auth.Signup(r.Context(), req.Email, req.Password)
+	if errors.Is(err, service.ErrOverloaded) {
+		overloaded(w)
+		return
+	}
+	if errors.Is(err, service.ErrUserExists) {
 		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+	if err != nil {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	if tokens == nil {
+		// Enumeration-safe signup: the same answer for new and taken emails.
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(newTokenResponse(tokens))
 }
 
 func (h *AuthHandler) Signin(w http.ResponseWriter, r *http.Request) {
-	var req validator.AuthRequest
+	var req validator.SigninRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
 		return
@@ -48,14 +70,594 @@ func (h *AuthHandler) Signin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.auth.Signin(r.Context(), req.Email, req.Password)
+	ctx := service.WithClientIP(r.Context(), middleware.ClientIP(r))
+	tokens, err := h.auth.Signin(ctx, req.Email, req.Password)
+	var mfa *service.MFARequiredError
+	if errors.As(err, &mfa) {
+		_ = json.NewEncoder(w).Encode(mfaChallengeResponse{MFARequired: true, MFAToken: mfa.Token})
+		return
+	}
+	var locked *service.LockedError
+	if errors.As(err, &locked) {
+		middleware.SetRetryAfter(w, locked.RetryAfter)
+		http.Error(w, `{"error":"too many failed attempts"}`, http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, service.ErrOverloaded) {
+		overloaded(w)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidCreds) {
+		http.Error(w, `{"error":"invalid credentials"}`, http.StatusUnauthorized)
+		return
+	}
+	if errors.Is(err, service.ErrEmailNotVerified) {
+		http.Error(w, `{"error":"email not verified"}`, http.StatusForbidden)
+		return
+	}
 	if err != nil {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(newTokenResponse(tokens))
+}
+
+// SigninMFA completes a signin that /signin answered with an MFA challenge.
+func (h *AuthHandler) SigninMFA(w http.ResponseWriter, r *http.Request) {
+	var req validator.MFASigninRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx := service.WithClientIP(r.Context(), middleware.ClientIP(r))
+	var tokens *service.Tokens
+	var err error
+	if req.RecoveryCode != "" {
+		tokens, err = h.auth.SigninRecoveryCode(ctx, req.MFAToken, req.RecoveryCode)
+	} else {
+		tokens, err = h.auth.SigninMFA(ctx, req.MFAToken, req.Code)
+	}
+	var locked *service.LockedError
+	switch {
+	case errors.Is(err, service.ErrMFADisabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	case errors.As(err, &locked):
+		middleware.SetRetryAfter(w, locked.RetryAfter)
+		http.Error(w, `{"error":"too many failed attempts"}`, http.StatusTooManyRequests)
+		return
+	case errors.Is(err, service.ErrOverloaded):
+		overloaded(w)
+		return
+	case errors.Is(err, service.ErrInvalidMFAToken), errors.Is(err, service.ErrInvalidMFACode):
 		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusUnauthorized)
 		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(newTokenResponse(tokens))
+}
+
+func (h *AuthHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req validator.PasswordConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx := service.WithClientIP(r.Context(), middleware.ClientIP(r))
+	enrollment, err := h.auth.EnrollTOTP(ctx, claims, req.CurrentPassword)
+	var locked *service.LockedError
+	switch {
+	case errors.Is(err, service.ErrMFADisabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	case errors.As(err, &locked):
+		middleware.SetRetryAfter(w, locked.RetryAfter)
+		http.Error(w, `{"error":"too many failed attempts"}`, http.StatusTooManyRequests)
+		return
+	case errors.Is(err, service.ErrOverloaded):
+		overloaded(w)
+		return
+	case errors.Is(err, service.ErrInvalidCreds):
+		http.Error(w, `{"error":"invalid current password"}`, http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
 	}
-	_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+	_ = json.NewEncoder(w).Encode(totpEnrollmentResponse{
+		Secret:        enrollment.Secret,
+		URI:           enrollment.URI,
+		RecoveryCodes: enrollment.RecoveryCodes,
+	})
 }
 
-func (h *AuthHandler) Me(w http.ResponseWriter, _ *http.Request) {
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req validator.CodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := h.auth.ConfirmTOTP(r.Context(), claims, req.Code)
+	switch {
+	case errors.Is(err, service.ErrMFADisabled), errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrInvalidMFACode), errors.Is(err, service.ErrTOTPNotEnrolled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrMFAAlreadyEnabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req validator.PasswordConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx := service.WithClientIP(r.Context(), middleware.ClientIP(r))
+	codes, err := h.auth.RegenerateRecoveryCodes(ctx, claims, req.CurrentPassword)
+	var locked *service.LockedError
+	switch {
+	case errors.Is(err, service.ErrMFADisabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	case errors.As(err, &locked):
+		middleware.SetRetryAfter(w, locked.RetryAfter)
+		http.Error(w, `{"error":"too many failed attempts"}`, http.StatusTooManyRequests)
+		return
+	case errors.Is(err, service.ErrOverloaded):
+		overloaded(w)
+		return
+	case errors.Is(err, service.ErrInvalidCreds):
+		http.Error(w, `{"error":"invalid current password"}`, http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrMFANotEnabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req validator.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.auth.Refresh(r.Context(), req.RefreshToken)
+	switch {
+	case errors.Is(err, service.ErrInvalidRefreshToken), errors.Is(err, service.ErrRefreshTokenReused):
+		http.Error(w, `{"error":"invalid refresh token"}`, http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(newTokenResponse(tokens))
+}
+
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req validator.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := h.auth.VerifyEmail(r.Context(), req.Token)
+	switch {
+	case errors.Is(err, service.ErrVerificationDisabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrInvalidVerificationToken):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrEmailAlreadyVerified):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification answers 202 whether or not a mail was sent, so it does
+// not reveal which emails are registered or verified.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req validator.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := h.auth.ResendVerification(r.Context(), req.Email)
+	if errors.Is(err, service.ErrVerificationDisabled) {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+// ForgotPassword answers 202 whether or not a mail was sent, so it does
+// not reveal which emails are registered.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req validator.EmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := h.auth.ForgotPassword(r.Context(), req.Email)
+	if errors.Is(err, service.ErrPasswordResetDisabled) {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req validator.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := h.auth.ResetPassword(r.Context(), req.Token, req.Password)
+	switch {
+	case errors.Is(err, service.ErrPasswordResetDisabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrInvalidResetToken), errors.Is(err, service.ErrPasswordReused):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrOverloaded):
+		overloaded(w)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req validator.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx := service.WithClientIP(r.Context(), middleware.ClientIP(r))
+	err := h.auth.ChangePassword(ctx, claims, req.CurrentPassword, req.NewPassword, req.RevokeOtherSessions)
+	var locked *service.LockedError
+	switch {
+	case errors.As(err, &locked):
+		middleware.SetRetryAfter(w, locked.RetryAfter)
+		http.Error(w, `{"error":"too many failed attempts"}`, http.StatusTooManyRequests)
+		return
+	case errors.Is(err, service.ErrOverloaded):
+		overloaded(w)
+		return
+	case errors.Is(err, service.ErrInvalidCreds):
+		http.Error(w, `{"error":"invalid current password"}`, http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrPasswordReused):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestEmailChange answers 202 whether or not the new address is free, so
+// it does not reveal which emails are registered.
+func (h *AuthHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var req validator.ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	ctx := service.WithClientIP(r.Context(), middleware.ClientIP(r))
+	err := h.auth.RequestEmailChange(ctx, claims, req.CurrentPassword, req.NewEmail)
+	var locked *service.LockedError
+	switch {
+	case errors.Is(err, service.ErrEmailChangeDisabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	case errors.As(err, &locked):
+		middleware.SetRetryAfter(w, locked.RetryAfter)
+		http.Error(w, `{"error":"too many failed attempts"}`, http.StatusTooManyRequests)
+		return
+	case errors.Is(err, service.ErrOverloaded):
+		overloaded(w)
+		return
+	case errors.Is(err, service.ErrInvalidCreds):
+		http.Error(w, `{"error":"invalid current password"}`, http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrSameEmail):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
+}
+
+func (h *AuthHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	h.emailChangeToken(w, r, h.auth.ConfirmEmailChange)
+}
+
+func (h *AuthHandler) CancelEmailChange(w http.ResponseWriter, r *http.Request) {
+	h.emailChangeToken(w, r, h.auth.CancelEmailChange)
+}
+
+func (h *AuthHandler) emailChangeToken(w http.ResponseWriter, r *http.Request, apply func(context.Context, string) error) {
+	var req validator.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := apply(r.Context(), req.Token)
+	switch {
+	case errors.Is(err, service.ErrEmailChangeDisabled):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrInvalidEmailChangeToken):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrEmailTaken):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	h.logout(w, r, h.auth.Logout)
+}
+
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	h.logout(w, r, h.auth.LogoutAll)
+}
+
+func (h *AuthHandler) logout(w http.ResponseWriter, r *http.Request, revoke func(context.Context, *token.Claims) error) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+		return
+	}
+	if err := revoke(r.Context(), claims); err != nil {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// overloaded sheds a request the service has no hashing capacity for.
+func overloaded(w http.ResponseWriter) {
+	middleware.SetRetryAfter(w, time.Second)
+	http.Error(w, `{"error":"service overloaded"}`, http.StatusServiceUnavailable)
+}
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func newTokenResponse(t *service.Tokens) tokenResponse {
+	return tokenResponse{Token: t.AccessToken, RefreshToken: t.RefreshToken}
+}
+
+type mfaChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+type totpEnrollmentResponse struct {
+	Secret        string   `json:"secret"`
+	URI           string   `json:"uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type userResponse struct {
+	ID              string     `json:"id"`
+	Email           string     `json:"email"`
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	LockedUntil     *time.Time `json:"locked_until,omitempty"`
+	PendingEmail    string     `json:"pending_email,omitempty"`
+	MFAEnabled      bool       `json:"mfa_enabled"`
+}
+
+func newUserResponse(u *model.User) userResponse {
+	return userResponse{
+		ID:              u.ID.String(),
+		Email:           u.Email,
+		EmailVerified:   u.EmailVerifiedAt != nil,
+		EmailVerifiedAt: utcPtr(u.EmailVerifiedAt),
+		CreatedAt:       u.CreatedAt.UTC(),
+		UpdatedAt:       u.UpdatedAt.UTC(),
+		LockedUntil:     utcPtr(u.LockedUntil),
+		PendingEmail:    u.PendingEmail,
+		MFAEnabled:      u.TOTPEnabledAt != nil,
+	}
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(r.Context())
+	if !ok {
+		http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+		return
+	}
+	id, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, `{"error":"invalid token"}`, http.StatusUnauthorized)
+		return
+	}
+
+	u, err := h.auth.Profile(r.Context(), id)
+	if errors.Is(err, service.ErrUserNotFound) {
+		http.Error(w, `{"error":"user not found"}`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(newUserResponse(u))
 }
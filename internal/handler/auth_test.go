@@ -2,24 +2,42 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/lockout"
+	"github.com/coinbase/identity-service/internal/mailer"
+	"github.com/coinbase/identity-service/internal/middleware"
+	"github.com/coinbase/identity-service/internal/model"
 	"github.com/coinbase/identity-service/internal/service"
 	"github.com/coinbase/identity-service/internal/store/memory"
 	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/seal"
 	"github.com/coinbase/identity-service/pkg/token"
+	"github.com/coinbase/identity-service/pkg/totp"
 )
 
 func setupAuthHandler() *AuthHandler {
+	handler, _ := setupAuthHandlerWithTokens()
+	return handler
+}
+
+func setupAuthHandlerWithTokens() (*AuthHandler, token.Manager) {
 	userStore := memory.NewUserStore()
 	hasher := hash.Bcrypt{}
 	tokens := token.NewJWTManager("test-secret-key", 15*time.Minute)
-	authSvc := service.NewAuthService(userStore, hasher, tokens)
-	return NewAuthHandler(authSvc)
+	authSvc := service.NewAuthService(userStore, hasher, tokens,
+		service.WithRefreshTokens(memory.NewRefreshTokenStore(), time.Hour))
+	return NewAuthHandler(authSvc), tokens
 }
 
 func TestAuthHandler_Signup(t *testing.T) {
@@ -50,6 +68,10 @@ func TestAuthHandler_Signup(t *testing.T) {
 	if response["token"] == "" {
 		t.Error("Response should contain a token")
 	}
+
+	if response["refresh_token"] == "" {
+		t.Error("Response should contain a refresh token")
+	}
 }
 
 func TestAuthHandler_SignupInvalidJSON(t *testing.T) {
@@ -167,26 +189,359 @@ func TestAuthHandler_SigninInvalidCredentials(t *testing.T) {
 func TestAuthHandler_SigninInvalidPassword(t *testing.T) {
 	handler := setupAuthHandler()
 
-	// Try signin with password that doesn't meet validation rules
-	signinBody := map[string]string{
-		"email":    "test@example.com",
-		"password": "weak", // Too short and no numbers
+	signin := func(password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.Signin(w, req)
+		return w
+	}
+
+	if w := signin(""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an empty password, got %d", w.Code)
 	}
-	body, _ := json.Marshal(signinBody)
+	// The rules for new passwords do not apply at signin, so a password
+	// breaking them is just wrong for this unknown email
+	if w := signin("weak"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_SigninImportedUser(t *testing.T) {
+	users := memory.NewUserStore()
+	hasher := hash.Fallback{Primary: hash.Bcrypt{Cost: 4}, Legacy: []hash.Verifier{hash.PBKDF2{}}}
+	authSvc := service.NewAuthService(users, hasher, token.NewJWTManager("test-secret-key", 15*time.Minute))
+	handler := NewAuthHandler(authSvc)
+
+	// PBKDF2-SHA256 of "letmein", too short and without digits for signup
+	imported := "$pbkdf2-sha256$1000$bGVnYWN5LXNhbHQtMDAwMQ$KHga8F/.ZbSrk8ahkhq6ZqjcSe7kId6WjEI88urfd6A"
+	u := &model.User{Email: "legacy@example.com", Password: imported}
+	if err := users.Create(context.Background(), u); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"email": "legacy@example.com", "password": "letmein"})
 	req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewBuffer(body))
-	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	handler.Signin(w, req)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("Expected status 400, got %d", w.Code)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	stored, _ := users.GetByEmail(context.Background(), "legacy@example.com")
+	if hash.Identify(stored.Password) != "bcrypt" {
+		t.Errorf("Expected the imported hash to be upgraded, got %s", hash.Identify(stored.Password))
 	}
 }
 
+func TestAuthHandler_SigninUniformErrors(t *testing.T) {
+	handler := setupAuthHandler()
+	signupClaims(t, handler, "test@example.com")
+
+	signin := func(email, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"email": email, "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		handler.Signin(w, req)
+		return w
+	}
+
+	unknown := signin("nobody@example.com", "password123")
+	wrong := signin("test@example.com", "wrongpass123")
+
+	if unknown.Code != http.StatusUnauthorized || wrong.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for both, got %d and %d", unknown.Code, wrong.Code)
+	}
+	if unknown.Body.String() != wrong.Body.String() {
+		t.Errorf("Expected identical bodies, got %q and %q", unknown.Body.String(), wrong.Body.String())
+	}
+}
+
+func TestAuthHandler_EnumerationSafeSignup(t *testing.T) {
+	userStore := memory.NewUserStore()
+	tokens := token.NewJWTManager("test-secret-key", 15*time.Minute)
+	authSvc := service.NewAuthService(userStore, hash.Bcrypt{}, tokens,
+		service.WithEnumerationSafeSignup(mailer.LogMailer{}))
+	handler := NewAuthHandler(authSvc)
+
+	signup := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": "password123"})
+		req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		handler.Signup(w, req)
+		return w
+	}
+
+	fresh := signup()
+	taken := signup()
+
+	if fresh.Code != http.StatusAccepted || taken.Code != http.StatusAccepted {
+		t.Errorf("Expected 202 for both, got %d and %d", fresh.Code, taken.Code)
+	}
+	if fresh.Body.String() != taken.Body.String() {
+		t.Errorf("Expected identical bodies, got %q and %q", fresh.Body.String(), taken.Body.String())
+	}
+}
+
+func TestAuthHandler_SigninLockedOut(t *testing.T) {
+	limiter := lockout.NewLimiter(lockout.NewMemoryCounter(),
+		lockout.Policy{MaxFailures: 1, LockoutDuration: 90 * time.Second}, lockout.Policy{}, time.Hour)
+	// The lock runs from when the attempt was reserved, before the password
+	// comparison, so keep that comparison cheap.
+	authSvc := service.NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute), service.WithLockout(limiter))
+	handler := NewAuthHandler(authSvc)
+
+	signin := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": "password123"})
+		req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		handler.Signin(w, req)
+		return w
+	}
+
+	if w := signin(); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected status 401, got %d", w.Code)
+	}
+	w := signin()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "90" {
+		t.Errorf("Expected Retry-After 90, got %q", got)
+	}
+}
+
+// gatedHasher blocks Hash until the gate is opened.
+type gatedHasher struct {
+	hash.Hasher
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (g *gatedHasher) Hash(pw string) (string, error) {
+	g.entered <- struct{}{}
+	<-g.gate
+	return g.Hasher.Hash(pw)
+}
+
+func TestAuthHandler_Overloaded(t *testing.T) {
+	hasher := &gatedHasher{Hasher: hash.Bcrypt{Cost: 4}, entered: make(chan struct{}, 1), gate: make(chan struct{})}
+	defer close(hasher.gate)
+	authSvc := service.NewAuthService(memory.NewUserStore(), hasher,
+		token.NewJWTManager("test-secret-key", 15*time.Minute), service.WithHashPool(1, 0, 0))
+	handler := NewAuthHandler(authSvc)
+
+	signup := func(email string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"email": email, "password": "password123"})
+		req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		handler.Signup(w, req)
+		return w
+	}
+	go signup("first@example.com")
+	<-hasher.entered
+
+	w := signup("second@example.com")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Expected Retry-After 1, got %q", got)
+	}
+}
+
+// readMailedToken waits for the file mailer to write a message with a link
+// to linkURL and returns the token at the end of it.
+func readMailedToken(t *testing.T, dir, linkURL string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err == nil && strings.Contains(string(b), linkURL) {
+				_, rest, _ := strings.Cut(string(b), linkURL)
+				tok, _, _ := strings.Cut(rest, "\n")
+				return strings.TrimSpace(tok)
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("Expected a mail with a link")
+	return ""
+}
+
+func TestAuthHandler_VerifyEmail(t *testing.T) {
+	const linkURL = "https://app.example.com/verify?token="
+	dir := t.TempDir()
+	mail, err := mailer.NewFileMailer(dir)
+	if err != nil {
+		t.Fatalf("NewFileMailer() failed: %v", err)
+	}
+	authSvc := service.NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute),
+		service.WithEmailVerification(service.EmailVerification{
+			Mailer:      mail,
+			Signer:      token.NewActionSigner([]byte("action-secret-key-for-tests-only")),
+			TTL:         time.Hour,
+			LinkURL:     linkURL,
+			BlockSignin: true,
+		}))
+	handler := NewAuthHandler(authSvc)
+
+	post := func(h http.HandlerFunc, path string, body map[string]string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(b)))
+		return w
+	}
+	creds := map[string]string{"email": "test@example.com", "password": "password123"}
+
+	if w := post(handler.Signup, "/signup", creds); w.Code != http.StatusAccepted {
+		t.Fatalf("Signup: expected status 202, got %d", w.Code)
+	}
+	if w := post(handler.Signin, "/signin", creds); w.Code != http.StatusForbidden {
+		t.Fatalf("Signin before verification: expected status 403, got %d", w.Code)
+	}
+
+	tok := readMailedToken(t, dir, linkURL)
+	tests := []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"missing token", map[string]string{}, http.StatusBadRequest},
+		{"invalid token", map[string]string{"token": "garbage"}, http.StatusBadRequest},
+		{"valid token", map[string]string{"token": tok}, http.StatusNoContent},
+		{"reused token", map[string]string{"token": tok}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if w := post(handler.VerifyEmail, "/verify-email", tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+
+	if w := post(handler.Signin, "/signin", creds); w.Code != http.StatusOK {
+		t.Errorf("Signin after verification: expected status 200, got %d", w.Code)
+	}
+	for _, email := range []string{"test@example.com", "nobody@example.com"} {
+		w := post(handler.ResendVerification, "/verify-email/resend", map[string]string{"email": email})
+		if w.Code != http.StatusAccepted {
+			t.Errorf("Resend for %s: expected status 202, got %d", email, w.Code)
+		}
+	}
+}
+
+func TestAuthHandler_VerifyEmailDisabled(t *testing.T) {
+	handler := setupAuthHandler()
+	body, _ := json.Marshal(map[string]string{"token": "abc"})
+	w := httptest.NewRecorder()
+	handler.VerifyEmail(w, httptest.NewRequest(http.MethodPost, "/verify-email", bytes.NewBuffer(body)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_PasswordReset(t *testing.T) {
+	const linkURL = "https://app.example.com/reset?token="
+	dir := t.TempDir()
+	mail, err := mailer.NewFileMailer(dir)
+	if err != nil {
+		t.Fatalf("NewFileMailer() failed: %v", err)
+	}
+	authSvc := service.NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute),
+		service.WithPasswordReset(service.PasswordReset{
+			Store:   memory.NewPasswordResetStore(),
+			Mailer:  mail,
+			TTL:     time.Hour,
+			LinkURL: linkURL,
+		}))
+	handler := NewAuthHandler(authSvc)
+
+	post := func(h http.HandlerFunc, path string, body map[string]string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(b)))
+		return w
+	}
+
+	if w := post(handler.Signup, "/signup", map[string]string{"email": "test@example.com", "password": "password123"}); w.Code != http.StatusOK {
+		t.Fatalf("Signup: expected status 200, got %d", w.Code)
+	}
+	for _, email := range []string{"test@example.com", "nobody@example.com"} {
+		w := post(handler.ForgotPassword, "/password/forgot", map[string]string{"email": email})
+		if w.Code != http.StatusAccepted {
+			t.Errorf("Forgot for %s: expected status 202, got %d", email, w.Code)
+		}
+	}
+
+	tok := readMailedToken(t, dir, linkURL)
+	tests := []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"missing token", map[string]string{"password": "newpassword1"}, http.StatusBadRequest},
+		{"weak password", map[string]string{"token": tok, "password": "short"}, http.StatusBadRequest},
+		{"invalid token", map[string]string{"token": "garbage", "password": "newpassword1"}, http.StatusBadRequest},
+		{"valid token", map[string]string{"token": tok, "password": "newpassword1"}, http.StatusNoContent},
+		{"reused token", map[string]string{"token": tok, "password": "otherpassword1"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := post(handler.ResetPassword, "/password/reset", tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+
+	creds := map[string]string{"email": "test@example.com", "password": "newpassword1"}
+	if w := post(handler.Signin, "/signin", creds); w.Code != http.StatusOK {
+		t.Errorf("Signin with the new password: expected status 200, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_PasswordResetDisabled(t *testing.T) {
+	handler := setupAuthHandler()
+	body, _ := json.Marshal(map[string]string{"email": "test@example.com"})
+	w := httptest.NewRecorder()
+	handler.ForgotPassword(w, httptest.NewRequest(http.MethodPost, "/password/forgot", bytes.NewBuffer(body)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+// signupClaims signs up a user through the handler and returns the claims
+// AuthMiddleware would place on the context for the issued token.
+func signupClaims(t *testing.T, handler *AuthHandler, email string) *token.Claims {
+	t.Helper()
+
+	body, _ := json.Marshal(map[string]string{"email": email, "password": "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	handler.Signup(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Signup failed with status %d", w.Code)
+	}
+
+	var response map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	claims, err := token.NewJWTManager("test-secret-key", 15*time.Minute).Verify(response["token"])
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	return claims
+}
+
 func TestAuthHandler_Me(t *testing.T) {
 	handler := setupAuthHandler()
+	claims := signupClaims(t, handler, "test@example.com")
 
 	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req = req.WithContext(middleware.WithClaims(req.Context(), claims))
 	w := httptest.NewRecorder()
 	handler.Me(w, req)
 
@@ -194,13 +549,442 @@ func TestAuthHandler_Me(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", w.Code)
 	}
 
-	var response map[string]string
+	var response map[string]interface{}
 	err := json.NewDecoder(w.Body).Decode(&response)
 	if err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	if response["status"] != "ok" {
-		t.Error("Response should contain status: ok")
+	if response["id"] != claims.UserID {
+		t.Errorf("Expected id %s, got %v", claims.UserID, response["id"])
+	}
+
+	if response["email"] != "test@example.com" {
+		t.Errorf("Expected email test@example.com, got %v", response["email"])
+	}
+
+	if _, exists := response["password"]; exists {
+		t.Error("Response must not expose the password hash")
+	}
+
+	for _, field := range []string{"created_at", "updated_at"} {
+		if _, exists := response[field]; !exists {
+			t.Errorf("Expected %s to be present", field)
+		}
+	}
+}
+
+func TestAuthHandler_MeWithoutClaims(t *testing.T) {
+	handler := setupAuthHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	w := httptest.NewRecorder()
+	handler.Me(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_MeUnknownUser(t *testing.T) {
+	handler := setupAuthHandler()
+
+	claims := &token.Claims{UserID: uuid.New().String(), Email: "ghost@example.com"}
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req = req.WithContext(middleware.WithClaims(req.Context(), claims))
+	w := httptest.NewRecorder()
+	handler.Me(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	handler := setupAuthHandler()
+
+	body, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": "password123"})
+	req1 := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer(body))
+	w1 := httptest.NewRecorder()
+	handler.Signup(w1, req1)
+
+	var signup map[string]string
+	if err := json.NewDecoder(w1.Body).Decode(&signup); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	refreshBody, _ := json.Marshal(map[string]string{"refresh_token": signup["refresh_token"]})
+
+	req2 := httptest.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBuffer(refreshBody))
+	w2 := httptest.NewRecorder()
+	handler.Refresh(w2, req2)
+
+	if w2.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w2.Code)
+	}
+
+	var response map[string]string
+	if err := json.NewDecoder(w2.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response["token"] == "" || response["refresh_token"] == "" {
+		t.Error("Response should contain a token and a refresh token")
+	}
+
+	if response["refresh_token"] == signup["refresh_token"] {
+		t.Error("Refresh should rotate the refresh token")
+	}
+
+	// Replaying the original token is rejected
+	req3 := httptest.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBuffer(refreshBody))
+	w3 := httptest.NewRecorder()
+	handler.Refresh(w3, req3)
+
+	if w3.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 on reuse, got %d", w3.Code)
+	}
+}
+
+func TestAuthHandler_RefreshMissingToken(t *testing.T) {
+	handler := setupAuthHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", bytes.NewBufferString(`{}`))
+	w := httptest.NewRecorder()
+	handler.Refresh(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_Logout(t *testing.T) {
+	handler, tokens := setupAuthHandlerWithTokens()
+
+	body, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": "password123"})
+	req1 := httptest.NewRequest(http.MethodPost, "/signup", bytes.NewBuffer(body))
+	w1 := httptest.NewRecorder()
+	handler.Signup(w1, req1)
+
+	var signup map[string]string
+	if err := json.NewDecoder(w1.Body).Decode(&signup); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	claims, err := tokens.Verify(signup["token"])
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	req2 := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req2 = req2.WithContext(middleware.WithClaims(req2.Context(), claims))
+	w2 := httptest.NewRecorder()
+	handler.Logout(w2, req2)
+
+	if w2.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w2.Code)
+	}
+
+	if _, err := tokens.Verify(signup["token"]); err != token.ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked after logout, got %v", err)
+	}
+}
+
+func TestAuthHandler_LogoutAll(t *testing.T) {
+	handler, tokens := setupAuthHandlerWithTokens()
+
+	body, _ := json.Marshal(map[string]string{"email": "test@example.com", "password": "password123"})
+	var issued []string
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/signin", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		if i == 0 {
+			handler.Signup(w, req)
+		} else {
+			handler.Signin(w, req)
+		}
+		var response map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		issued = append(issued, response["token"])
+	}
+
+	claims, err := tokens.Verify(issued[0])
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/logout/all", nil)
+	req = req.WithContext(middleware.WithClaims(req.Context(), claims))
+	w := httptest.NewRecorder()
+	handler.LogoutAll(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", w.Code)
+	}
+
+	for i, tok := range issued {
+		if _, err := tokens.Verify(tok); err != token.ErrTokenRevoked {
+			t.Errorf("token %d: expected ErrTokenRevoked, got %v", i, err)
+		}
+	}
+}
+
+func TestAuthHandler_LogoutWithoutClaims(t *testing.T) {
+	handler := setupAuthHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	w := httptest.NewRecorder()
+	handler.Logout(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_ChangePassword(t *testing.T) {
+	handler := setupAuthHandler()
+	claims := signupClaims(t, handler, "test@example.com")
+
+	tests := []struct {
+		name string
+		body map[string]any
+		want int
+	}{
+		{"missing current", map[string]any{"new_password": "newpassword1"}, http.StatusBadRequest},
+		{"weak new", map[string]any{"current_password": "password123", "new_password": "short"}, http.StatusBadRequest},
+		{"wrong current", map[string]any{"current_password": "wrongpassword1", "new_password": "newpassword1"}, http.StatusForbidden},
+		{"reused", map[string]any{"current_password": "password123", "new_password": "password123"}, http.StatusBadRequest},
+		{"valid", map[string]any{"current_password": "password123", "new_password": "newpassword1", "revoke_other_sessions": true}, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(tt.body)
+		req := httptest.NewRequest(http.MethodPost, "/me/password", bytes.NewBuffer(body))
+		req = req.WithContext(middleware.WithClaims(req.Context(), claims))
+		w := httptest.NewRecorder()
+		handler.ChangePassword(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+}
+
+func TestAuthHandler_ChangePasswordWithoutClaims(t *testing.T) {
+	handler := setupAuthHandler()
+	w := httptest.NewRecorder()
+	handler.ChangePassword(w, httptest.NewRequest(http.MethodPost, "/me/password", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_EmailChange(t *testing.T) {
+	const confirmURL = "https://app.example.com/email/confirm?token="
+	const cancelURL = "https://app.example.com/email/cancel?token="
+	dir := t.TempDir()
+	mail, err := mailer.NewFileMailer(dir)
+	if err != nil {
+		t.Fatalf("NewFileMailer() failed: %v", err)
+	}
+	authSvc := service.NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute),
+		service.WithEmailChange(service.EmailChange{
+			Mailer:     mail,
+			Signer:     token.NewActionSigner([]byte("action-secret-key-for-tests-only")),
+			TTL:        time.Hour,
+			ConfirmURL: confirmURL,
+			CancelURL:  cancelURL,
+		}))
+	handler := NewAuthHandler(authSvc)
+	claims := signupClaims(t, handler, "old@example.com")
+
+	requests := []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"missing password", map[string]string{"new_email": "new@example.com"}, http.StatusBadRequest},
+		{"invalid email", map[string]string{"new_email": "invalid", "current_password": "password123"}, http.StatusBadRequest},
+		{"wrong password", map[string]string{"new_email": "new@example.com", "current_password": "wrongpassword1"}, http.StatusForbidden},
+		{"same email", map[string]string{"new_email": "old@example.com", "current_password": "password123"}, http.StatusBadRequest},
+		{"valid", map[string]string{"new_email": "new@example.com", "current_password": "password123"}, http.StatusAccepted},
+	}
+	for _, tt := range requests {
+		body, _ := json.Marshal(tt.body)
+		req := httptest.NewRequest(http.MethodPost, "/me/email", bytes.NewBuffer(body))
+		req = req.WithContext(middleware.WithClaims(req.Context(), claims))
+		w := httptest.NewRecorder()
+		handler.RequestEmailChange(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+
+	post := func(h http.HandlerFunc, path string, body map[string]string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(b)))
+		return w
+	}
+	confirm := readMailedToken(t, dir, confirmURL)
+	cancel := readMailedToken(t, dir, cancelURL)
+	tests := []struct {
+		name string
+		h    http.HandlerFunc
+		body map[string]string
+		want int
+	}{
+		{"missing token", handler.ConfirmEmailChange, map[string]string{}, http.StatusBadRequest},
+		{"cancel token to confirm", handler.ConfirmEmailChange, map[string]string{"token": cancel}, http.StatusBadRequest},
+		{"valid token", handler.ConfirmEmailChange, map[string]string{"token": confirm}, http.StatusNoContent},
+		{"reused token", handler.ConfirmEmailChange, map[string]string{"token": confirm}, http.StatusBadRequest},
+		{"cancel after confirm", handler.CancelEmailChange, map[string]string{"token": cancel}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := post(tt.h, "/email-change", tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+
+	creds := map[string]string{"email": "new@example.com", "password": "password123"}
+	if w := post(handler.Signin, "/signin", creds); w.Code != http.StatusOK {
+		t.Errorf("Signin with the new email: expected status 200, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_EmailChangeDisabled(t *testing.T) {
+	handler := setupAuthHandler()
+	claims := signupClaims(t, handler, "old@example.com")
+
+	body, _ := json.Marshal(map[string]string{"new_email": "new@example.com", "current_password": "password123"})
+	req := httptest.NewRequest(http.MethodPost, "/me/email", bytes.NewBuffer(body))
+	req = req.WithContext(middleware.WithClaims(req.Context(), claims))
+	w := httptest.NewRecorder()
+	handler.RequestEmailChange(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_MFA(t *testing.T) {
+	box, err := seal.New([]byte("mfa-encryption-key-for-tests-only"))
+	if err != nil {
+		t.Fatalf("seal.New() failed: %v", err)
+	}
+	authSvc := service.NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute),
+		service.WithMFA(service.MFA{
+			Box:          box,
+			Signer:       token.NewActionSigner([]byte("action-secret-key-for-tests-only")),
+			Issuer:       "Identity Service",
+			ChallengeTTL: 5 * time.Minute,
+			RecoveryKey:  []byte("mfa-encryption-key-for-tests-only"),
+		}))
+	handler := NewAuthHandler(authSvc)
+	claims := signupClaims(t, handler, "test@example.com")
+
+	authed := func(h http.HandlerFunc, path string, body map[string]string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(b))
+		req = req.WithContext(middleware.WithClaims(req.Context(), claims))
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+	post := func(h http.HandlerFunc, path string, body map[string]string) *httptest.ResponseRecorder {
+		b, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(b)))
+		return w
+	}
+
+	if w := authed(handler.EnrollTOTP, "/me/mfa/totp", map[string]string{"current_password": "wrongpassword1"}); w.Code != http.StatusForbidden {
+		t.Errorf("Enroll with a wrong password: expected status 403, got %d", w.Code)
+	}
+	w := authed(handler.EnrollTOTP, "/me/mfa/totp", map[string]string{"current_password": "password123"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Enroll: expected status 200, got %d", w.Code)
+	}
+	var enrollment struct {
+		Secret        string   `json:"secret"`
+		URI           string   `json:"uri"`
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&enrollment)
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/") {
+		t.Errorf("Expected an otpauth URI, got %q", enrollment.URI)
+	}
+	if len(enrollment.RecoveryCodes) != 10 {
+		t.Errorf("Expected 10 recovery codes, got %v", enrollment.RecoveryCodes)
+	}
+	secret, err := totp.Encoding.DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("Secret is not base32: %v", err)
+	}
+
+	step := totp.Counter(time.Now())
+	if w := authed(handler.ConfirmTOTP, "/me/mfa/totp/confirm", map[string]string{"code": "000000"}); w.Code != http.StatusBadRequest {
+		t.Errorf("Confirm with a wrong code: expected status 400, got %d", w.Code)
+	}
+	if w := authed(handler.ConfirmTOTP, "/me/mfa/totp/confirm", map[string]string{"code": totp.Code(secret, step)}); w.Code != http.StatusNoContent {
+		t.Fatalf("Confirm: expected status 204, got %d", w.Code)
+	}
+
+	w = post(handler.Signin, "/signin", map[string]string{"email": "test@example.com", "password": "password123"})
+	var challenge struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+		Token       string `json:"token"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&challenge)
+	if w.Code != http.StatusOK || !challenge.MFARequired || challenge.MFAToken == "" || challenge.Token != "" {
+		t.Fatalf("Signin: expected an MFA challenge, got %d %+v", w.Code, challenge)
+	}
+
+	recovery := enrollment.RecoveryCodes[0]
+	tests := []struct {
+		name string
+		body map[string]string
+		want int
+	}{
+		{"missing code", map[string]string{"mfa_token": challenge.MFAToken}, http.StatusBadRequest},
+		{"both codes", map[string]string{"mfa_token": challenge.MFAToken, "code": "123456", "recovery_code": recovery}, http.StatusBadRequest},
+		{"invalid token", map[string]string{"mfa_token": "garbage", "code": "123456"}, http.StatusUnauthorized},
+		{"replayed code", map[string]string{"mfa_token": challenge.MFAToken, "code": totp.Code(secret, step)}, http.StatusUnauthorized},
+		{"valid code", map[string]string{"mfa_token": challenge.MFAToken, "code": totp.Code(secret, step+1)}, http.StatusOK},
+		{"wrong recovery code", map[string]string{"mfa_token": challenge.MFAToken, "recovery_code": "aaaaa-aaaaa"}, http.StatusUnauthorized},
+		{"recovery code", map[string]string{"mfa_token": challenge.MFAToken, "recovery_code": recovery}, http.StatusOK},
+		{"used recovery code", map[string]string{"mfa_token": challenge.MFAToken, "recovery_code": recovery}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if w := post(handler.SigninMFA, "/signin/mfa", tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, w.Code)
+		}
+	}
+
+	if w := authed(handler.RegenerateRecoveryCodes, "/me/mfa/recovery-codes", map[string]string{"current_password": "wrongpassword1"}); w.Code != http.StatusForbidden {
+		t.Errorf("Regenerate with a wrong password: expected status 403, got %d", w.Code)
+	}
+	w = authed(handler.RegenerateRecoveryCodes, "/me/mfa/recovery-codes", map[string]string{"current_password": "password123"})
+	var regenerated struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	_ = json.NewDecoder(w.Body).Decode(&regenerated)
+	if w.Code != http.StatusOK || len(regenerated.RecoveryCodes) != 10 {
+		t.Fatalf("Regenerate: expected status 200 with 10 codes, got %d %+v", w.Code, regenerated)
+	}
+	old := map[string]string{"mfa_token": challenge.MFAToken, "recovery_code": enrollment.RecoveryCodes[1]}
+	if w := post(handler.SigninMFA, "/signin/mfa", old); w.Code != http.StatusUnauthorized {
+		t.Errorf("Replaced recovery code: expected status 401, got %d", w.Code)
+	}
+}
+
+func TestAuthHandler_MFADisabled(t *testing.T) {
+	handler := setupAuthHandler()
+	body, _ := json.Marshal(map[string]string{"mfa_token": "abc", "code": "123456"})
+	w := httptest.NewRecorder()
+	handler.SigninMFA(w, httptest.NewRequest(http.MethodPost, "/signin/mfa", bytes.NewBuffer(body)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 }
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+// jwksMaxAge bounds how long verifiers cache the key set. Schedule new keys
+// at least this far ahead of their activation so every verifier has seen
+// them before the first token they sign arrives.
+const jwksMaxAge = "300"
+
+type KeysHandler struct {
+	tokens token.Manager
+}
+
+func NewKeysHandler(tm token.Manager) *KeysHandler {
+	return &KeysHandler{tokens: tm}
+}
+
+// JWKS serves the public keys access tokens can be verified with: the
+// active signing key, keys scheduled to sign next, and retired keys whose
+// tokens may not have expired yet.
+func (h *KeysHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+jwksMaxAge)
+	_ = json.NewEncoder(w).Encode(h.tokens.JWKS())
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+func TestKeysHandler_JWKS(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := token.ParsePrivateKeyPEM("2025-01", "EdDSA", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM() failed: %v", err)
+	}
+	tm, err := token.NewJWTManagerWithKey(key, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("NewJWTManagerWithKey() failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	NewKeysHandler(tm).JWKS(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		t.Error("Expected Cache-Control header")
+	}
+
+	var set token.JWKS
+	if err := json.NewDecoder(w.Body).Decode(&set); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(set.Keys) != 1 {
+		t.Fatalf("Expected 1 key, got %d", len(set.Keys))
+	}
+	if jwk := set.Keys[0]; jwk.KeyID != "2025-01" || jwk.KeyType != "OKP" || jwk.Alg != "EdDSA" {
+		t.Errorf("Unexpected JWK %+v", jwk)
+	}
+}
+
+func TestKeysHandler_JWKSOmitsHMAC(t *testing.T) {
+	tm := token.NewJWTManager("test-secret", 15*time.Minute)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	NewKeysHandler(tm).JWKS(w, req)
+
+	if body := w.Body.String(); body != "{\"keys\":[]}\n" {
+		t.Errorf("Expected empty key set, got %s", body)
+	}
+}
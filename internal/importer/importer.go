@@ -0,0 +1,215 @@
+// Package importer loads users exported from other systems into a
+// store.UserStore, keeping their existing password hashes.
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/internal/validator"
+	"github.com/coinbase/identity-service/pkg/hash"
+)
+
+var (
+	ErrUnknownFormat      = errors.New("unknown import format")
+	ErrMissingColumn      = errors.New("missing column")
+	ErrUnknownHash        = errors.New("unrecognized password hash format")
+	ErrInvalidHash        = errors.New("malformed or out-of-range password hash")
+	ErrMalformedRecord    = errors.New("malformed record")
+	ErrPasswordHashNeeded = errors.New("password_hash is required")
+)
+
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+)
+
+// Record is one user to import. CSV input needs a header row naming the
+// email and password_hash columns; JSONL input has one object per line with
+// the same keys.
+type Record struct {
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// RowError reports a record that was not imported.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+type Result struct {
+	Imported int
+	// Duplicates counts records whose email is already registered; they are
+	// left untouched.
+	Duplicates int
+	Rejected   []RowError
+}
+
+type Options struct {
+	// DryRun validates every record without writing anything.
+	DryRun bool
+}
+
+// Import reads records in format from r and creates a user for each. Invalid
+// records are reported in Result.Rejected and do not stop the import; a
+// store or read failure does.
+func Import(ctx context.Context, us store.UserStore, format Format, r io.Reader, opts Options) (*Result, error) {
+	res := &Result{}
+	seen := map[string]bool{}
+	err := readRecords(format, r, func(line int, rec Record, parseErr error) error {
+		if parseErr != nil {
+			res.Rejected = append(res.Rejected, RowError{Line: line, Err: parseErr})
+			return nil
+		}
+		if err := rec.normalize(); err != nil {
+			res.Rejected = append(res.Rejected, RowError{Line: line, Err: err})
+			return nil
+		}
+		if seen[rec.Email] {
+			res.Duplicates++
+			return nil
+		}
+		seen[rec.Email] = true
+
+		if opts.DryRun {
+			existing, err := us.GetByEmail(ctx, rec.Email)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				res.Duplicates++
+			} else {
+				res.Imported++
+			}
+			return nil
+		}
+
+		err := us.Create(ctx, &model.User{Email: rec.Email, Password: rec.PasswordHash})
+		switch {
+		case errors.Is(err, store.ErrDuplicateEmail):
+			res.Duplicates++
+		case err != nil:
+			return fmt.Errorf("line %d: %w", line, err)
+		default:
+			res.Imported++
+		}
+		return nil
+	})
+	return res, err
+}
+
+func (rec *Record) normalize() error {
+	email, err := validator.NormalizeEmail(rec.Email)
+	if err != nil {
+		return err
+	}
+	rec.Email = email
+	rec.PasswordHash = strings.TrimSpace(rec.PasswordHash)
+	if rec.PasswordHash == "" {
+		return ErrPasswordHashNeeded
+	}
+	scheme := hash.Identify(rec.PasswordHash)
+	if scheme == "" {
+		return ErrUnknownHash
+	}
+	if hash.Validate(rec.PasswordHash) != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidHash, scheme)
+	}
+	return nil
+}
+
+// readRecords calls fn for every record in r with its 1-based line number,
+// or with the error that kept that line from being parsed.
+func readRecords(format Format, r io.Reader, fn func(line int, rec Record, parseErr error) error) error {
+	switch format {
+	case CSV:
+		return readCSV(r, fn)
+	case JSONL:
+		return readJSONL(r, fn)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+func readCSV(r io.Reader, fn func(int, Record, error) error) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	emailCol, ok := cols["email"]
+	if !ok {
+		return fmt.Errorf("%w: email", ErrMissingColumn)
+	}
+	hashCol, ok := cols["password_hash"]
+	if !ok {
+		return fmt.Errorf("%w: password_hash", ErrMissingColumn)
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+		var rec Record
+		if emailCol < len(row) {
+			rec.Email = row[emailCol]
+		}
+		if hashCol < len(row) {
+			rec.PasswordHash = row[hashCol]
+		}
+		if err := fn(line, rec, nil); err != nil {
+			return err
+		}
+	}
+}
+
+func readJSONL(r io.Reader, fn func(int, Record, error) error) error {
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		// A malformed line is a bad record, not a reason to stop.
+		var rec Record
+		var parseErr error
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			parseErr = fmt.Errorf("%w: %v", ErrMalformedRecord, err)
+		}
+		if err := fn(line, rec, parseErr); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,133 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/pkg/hash"
+)
+
+const (
+	pbkdf2Hash = "$pbkdf2-sha256$1000$bGVnYWN5LXNhbHQtMDAwMQ$GOzgi0210x3UfZ05aRLYlKB95IPl305r88lVM3aUUm8"
+	sshaHash   = "{SSHA256}8+2hqbD76p4UyJAX0VHbrtqqQIa9eNRLw9MH275JhIxsZWdhY3ktc2FsdC0wMDAx"
+	bcryptHash = "$2a$04$Yoi35m/JE/TAZFl.7PxKXe5WXDSJ2Y40JjpbisMPwH8whJlk9MwU2"
+)
+
+func TestImport_CSV(t *testing.T) {
+	us := memory.NewUserStore()
+	ctx := context.Background()
+	_ = us.Create(ctx, &model.User{Email: "taken@example.com", Password: "$2a$04$existing"})
+
+	input := "password_hash,email,legacy_id\n" +
+		pbkdf2Hash + ",Alice@Example.com,1\n" +
+		sshaHash + ",bob@example.com,2\n" +
+		bcryptHash + ",taken@example.com,3\n" +
+		"md5:abc,carol@example.com,4\n" +
+		pbkdf2Hash + ",not-an-email,5\n" +
+		sshaHash + ",alice@example.com,6\n"
+
+	res, err := Import(ctx, us, CSV, strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	if res.Imported != 2 || res.Duplicates != 2 || len(res.Rejected) != 2 {
+		t.Fatalf("Expected 2 imported, 2 duplicates, 2 rejected; got %+v", res)
+	}
+	if res.Rejected[0].Line != 5 || !errors.Is(res.Rejected[0], ErrUnknownHash) {
+		t.Errorf("Expected line 5 rejected for its hash, got %v", res.Rejected[0])
+	}
+	if res.Rejected[1].Line != 6 {
+		t.Errorf("Expected line 6 rejected, got %v", res.Rejected[1])
+	}
+
+	alice, _ := us.GetByEmail(ctx, "alice@example.com")
+	if alice == nil || alice.Email != "alice@example.com" || alice.Password != pbkdf2Hash {
+		t.Fatalf("Expected alice imported with her original hash, got %+v", alice)
+	}
+	if !(hash.PBKDF2{}).Compare(alice.Password, "password123") {
+		t.Error("Imported hash should verify the original password")
+	}
+}
+
+func TestImport_JSONL(t *testing.T) {
+	us := memory.NewUserStore()
+	ctx := context.Background()
+
+	input := `{"email": "alice@example.com", "password_hash": "` + pbkdf2Hash + `"}
+
+{"email": "bob@example.com"}
+{not json
+{"email": "carol@example.com", "password_hash": "` + sshaHash + `"}
+`
+	res, err := Import(ctx, us, JSONL, strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	if res.Imported != 2 || len(res.Rejected) != 2 {
+		t.Fatalf("Expected 2 imported and 2 rejected, got %+v", res)
+	}
+	if res.Rejected[0].Line != 3 || !errors.Is(res.Rejected[0], ErrPasswordHashNeeded) {
+		t.Errorf("Expected line 3 rejected for missing hash, got %v", res.Rejected[0])
+	}
+	if res.Rejected[1].Line != 4 || !errors.Is(res.Rejected[1], ErrMalformedRecord) {
+		t.Errorf("Expected line 4 rejected as malformed, got %v", res.Rejected[1])
+	}
+}
+
+func TestImport_DryRun(t *testing.T) {
+	us := memory.NewUserStore()
+	ctx := context.Background()
+	_ = us.Create(ctx, &model.User{Email: "taken@example.com", Password: "$2a$04$existing"})
+
+	input := "email,password_hash\nalice@example.com," + pbkdf2Hash + "\ntaken@example.com," + sshaHash + "\n"
+	res, err := Import(ctx, us, CSV, strings.NewReader(input), Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	if res.Imported != 1 || res.Duplicates != 1 {
+		t.Errorf("Expected 1 importable and 1 duplicate, got %+v", res)
+	}
+	if alice, _ := us.GetByEmail(ctx, "alice@example.com"); alice != nil {
+		t.Error("Dry run should not create users")
+	}
+}
+
+func TestImport_BadInput(t *testing.T) {
+	us := memory.NewUserStore()
+	ctx := context.Background()
+
+	if _, err := Import(ctx, us, CSV, strings.NewReader("email,hash\n"), Options{}); !errors.Is(err, ErrMissingColumn) {
+		t.Errorf("Expected ErrMissingColumn, got %v", err)
+	}
+	if _, err := Import(ctx, us, "xml", strings.NewReader(""), Options{}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestImport_RejectsOutOfRangeHashes(t *testing.T) {
+	us := memory.NewUserStore()
+	ctx := context.Background()
+
+	input := "email,password_hash\n" +
+		"a@example.com,$pbkdf2-sha256$999999999$bGVnYWN5LXNhbHQtMDAwMQ$GOzgi0210x3UfZ05aRLYlKB95IPl305r88lVM3aUUm8\n" +
+		"b@example.com,$scrypt$ln=30,r=8,p=1$bGVnYWN5LXNhbHQtMDAwMQ$QLFuh5gzSK3Ig5fRg/bqzHBv6S6WjYRpNyzhd.bf7Xk\n" +
+		"c@example.com,$2a$04$whatever\n" +
+		"d@example.com," + pbkdf2Hash + "\n"
+
+	res, err := Import(ctx, us, CSV, strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	if res.Imported != 1 || len(res.Rejected) != 3 {
+		t.Fatalf("Expected 1 imported, 3 rejected; got %+v", res)
+	}
+	for _, rej := range res.Rejected {
+		if !errors.Is(rej, ErrInvalidHash) {
+			t.Errorf("Expected line %d rejected with ErrInvalidHash, got %v", rej.Line, rej.Err)
+		}
+	}
+}
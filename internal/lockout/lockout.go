@@ -0,0 +1,264 @@
+// Package lockout throttles repeated failed signins per account and per
+// client IP with exponential backoff and temporary lockout.
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Attempts is the failure history of one key.
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Counter stores failure histories. The memory implementation limits each
+// instance separately; a shared implementation makes the limits hold across
+// every instance of the service.
+type Counter interface {
+	// Fail records a failure at at and returns the history as it was just
+	// before, in one atomic step, so concurrent callers each see the
+	// failures of the others. The history is forgotten ttl after its last
+	// failure.
+	Fail(ctx context.Context, key string, at time.Time, ttl time.Duration) (Attempts, error)
+	// Refund takes back the failure Fail recorded at at, given the history
+	// prev it returned. LastFailure goes back to prev's unless a later
+	// failure has been recorded since.
+	Refund(ctx context.Context, key string, at time.Time, prev Attempts) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy decides how long a key must wait given its failure history.
+type Policy struct {
+	// MaxFailures locks the key for LockoutDuration once reached; zero never
+	// locks.
+	MaxFailures     int
+	LockoutDuration time.Duration
+	// BaseDelay is the wait after the first failure, doubled for each
+	// further one up to MaxDelay; zero disables backoff.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Locked reports whether a has reached the lockout threshold.
+func (p Policy) Locked(a Attempts) bool {
+	return p.MaxFailures > 0 && a.Failures >= p.MaxFailures
+}
+
+// Wait returns how long after now the next attempt must wait.
+func (p Policy) Wait(a Attempts, now time.Time) time.Duration {
+	if a.Failures == 0 {
+		return 0
+	}
+	until := a.LastFailure.Add(p.backoff(a.Failures))
+	if p.Locked(a) {
+		until = a.LastFailure.Add(p.LockoutDuration)
+	}
+	if d := until.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func (p Policy) backoff(failures int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// Limiter applies an account policy keyed by email and an IP policy keyed
+// by client IP. Keys are per email rather than per user, so unknown emails
+// are throttled exactly like registered ones.
+type Limiter struct {
+	counter Counter
+	account Policy
+	ip      Policy
+	// window is how long failures are remembered after the last one.
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewLimiter(c Counter, account, ip Policy, window time.Duration) *Limiter {
+	return &Limiter{counter: c, account: account, ip: ip, window: window, now: time.Now}
+}
+
+func accountKey(email string) string { return "account:" + email }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// Attempt is a signin attempt reserved by Begin. It counts as a failure
+// from the start, and stays one unless Succeed or Cancel takes it back.
+type Attempt struct {
+	email, ip string
+	at        time.Time
+	// account and ipPrev are the histories before the attempt.
+	account, ipPrev Attempts
+}
+
+// Begin reserves an attempt to sign in as email from ip before any
+// password or code is checked. The attempt is recorded as a failure at
+// once, so requests sent concurrently cannot all slip past the limits
+// before the first of them fails. When the histories so far call for a
+// wait, the reservation is taken back and Begin returns that wait instead
+// of an attempt. An empty ip skips the IP policy.
+func (l *Limiter) Begin(ctx context.Context, email, ip string) (*Attempt, time.Duration, error) {
+	a := &Attempt{email: email, ip: ip, at: l.now()}
+	var err error
+	a.account, err = l.counter.Fail(ctx, accountKey(email), a.at, l.window)
+	if err != nil {
+		return nil, 0, err
+	}
+	wait := l.account.Wait(a.account, a.at)
+	if ip != "" {
+		a.ipPrev, err = l.counter.Fail(ctx, ipKey(ip), a.at, l.window)
+		if err != nil {
+			_ = l.counter.Refund(context.WithoutCancel(ctx), accountKey(email), a.at, a.account)
+			return nil, 0, err
+		}
+		wait = max(wait, l.ip.Wait(a.ipPrev, a.at))
+	}
+	if wait > 0 {
+		return nil, wait, l.Cancel(ctx, a)
+	}
+	return a, 0, nil
+}
+
+// Fail settles a as a failed signin. When it locks the account, Fail
+// returns the time the lock ends.
+func (l *Limiter) Fail(a *Attempt) (lockedUntil time.Time, locked bool) {
+	if !l.account.Locked(Attempts{Failures: a.account.Failures + 1}) {
+		return time.Time{}, false
+	}
+	return a.at.Add(l.account.LockoutDuration), true
+}
+
+// Succeed settles a as a successful signin, forgetting the account's
+// failures. The IP history only loses a itself: succeeding on one account
+// must not buy an attacker more guesses against others. Like Cancel, it
+// goes ahead even if ctx is canceled, since a request the client gave up on
+// must not stay counted as a failure.
+func (l *Limiter) Succeed(ctx context.Context, a *Attempt) error {
+	ctx = context.WithoutCancel(ctx)
+	if a.ip != "" {
+		if err := l.counter.Refund(ctx, ipKey(a.ip), a.at, a.ipPrev); err != nil {
+			return err
+		}
+	}
+	return l.Reset(ctx, a.email)
+}
+
+// Cancel takes back a when it ended without a verdict, such as when the
+// check could not run or a second factor is still due. It goes ahead even
+// if ctx is canceled, which is often why the check could not run.
+func (l *Limiter) Cancel(ctx context.Context, a *Attempt) error {
+	ctx = context.WithoutCancel(ctx)
+	if a.ip != "" {
+		if err := l.counter.Refund(ctx, ipKey(a.ip), a.at, a.ipPrev); err != nil {
+			return err
+		}
+	}
+	return l.counter.Refund(ctx, accountKey(a.email), a.at, a.account)
+}
+
+// Reset forgets the account's failures, after a password reset or an admin
+// unlock.
+func (l *Limiter) Reset(ctx context.Context, email string) error {
+	return l.counter.Reset(ctx, accountKey(email))
+}
+
+// memorySweepInterval is how often MemoryCounter drops expired entries.
+const memorySweepInterval = time.Minute
+
+// MemoryCounter is a process-local Counter.
+type MemoryCounter struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	lastSweep time.Time
+}
+
+type memoryEntry struct {
+	Attempts
+	expires time.Time
+}
+
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{entries: map[string]memoryEntry{}}
+}
+
+func (c *MemoryCounter) Fail(ctx context.Context, key string, at time.Time, ttl time.Duration) (Attempts, error) {
+	if err := ctx.Err(); err != nil {
+		return Attempts{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+	e := c.entries[key]
+	if !now.Before(e.expires) {
+		e = memoryEntry{}
+	}
+	prev := e.Attempts
+	e.Failures++
+	e.LastFailure = at
+	e.expires = at.Add(ttl)
+	c.entries[key] = e
+	return prev, nil
+}
+
+// sweep drops expired entries. It runs at most once per
+// memorySweepInterval, so Fail stays cheap however many keys are tracked.
+func (c *MemoryCounter) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < memorySweepInterval {
+		return
+	}
+	c.lastSweep = now
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *MemoryCounter) Refund(ctx context.Context, key string, at time.Time, prev Attempts) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	e.Failures--
+	if e.Failures <= 0 {
+		delete(c.entries, key)
+		return nil
+	}
+	if e.LastFailure.Equal(at) {
+		e.LastFailure = prev.LastFailure
+	}
+	c.entries[key] = e
+	return nil
+}
+
+func (c *MemoryCounter) Reset(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+	return nil
+}
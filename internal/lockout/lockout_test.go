@@ -0,0 +1,261 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPolicy_Wait(t *testing.T) {
+	p := Policy{MaxFailures: 5, LockoutDuration: 15 * time.Minute, BaseDelay: time.Second, MaxDelay: 4 * time.Second}
+	last := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		failures int
+		after    time.Duration
+		want     time.Duration
+	}{
+		{0, 0, 0},
+		{1, 0, time.Second},
+		{2, 0, 2 * time.Second},
+		{3, 0, 4 * time.Second},
+		{4, 0, 4 * time.Second}, // capped at MaxDelay
+		{3, time.Second, 3 * time.Second},
+		{3, time.Minute, 0},
+		{5, 0, 15 * time.Minute},
+		{6, 10 * time.Minute, 5 * time.Minute},
+		{5, 15 * time.Minute, 0},
+	}
+	for _, tt := range tests {
+		got := p.Wait(Attempts{Failures: tt.failures, LastFailure: last}, last.Add(tt.after))
+		if got != tt.want {
+			t.Errorf("Wait(%d failures, +%v) = %v, want %v", tt.failures, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_ZeroDisables(t *testing.T) {
+	now := time.Now()
+	a := Attempts{Failures: 1000, LastFailure: now}
+	if got := (Policy{}).Wait(a, now); got != 0 {
+		t.Errorf("zero Policy Wait() = %v, want 0", got)
+	}
+	if (Policy{}).Locked(a) {
+		t.Error("zero Policy should never lock")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now() // MemoryCounter expires histories by the real clock
+	account := Policy{MaxFailures: 3, LockoutDuration: time.Minute, BaseDelay: time.Second, MaxDelay: time.Second}
+	ip := Policy{MaxFailures: 5, LockoutDuration: time.Minute}
+	l := NewLimiter(NewMemoryCounter(), account, ip, time.Hour)
+	l.now = func() time.Time { return now }
+
+	fail := func(email, ip string) (time.Time, bool) {
+		t.Helper()
+		a, wait, err := l.Begin(ctx, email, ip)
+		if err != nil || wait != 0 {
+			t.Fatalf("Begin(%s, %s) = %v, %v; want an attempt", email, ip, wait, err)
+		}
+		return l.Fail(a)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if i > 1 {
+			// Right after a failure the account must back off
+			if _, wait, _ := l.Begin(ctx, "a@example.com", "192.0.2.1"); wait != time.Second {
+				t.Fatalf("Begin() during backoff = %v, want 1s", wait)
+			}
+			now = now.Add(time.Second)
+		}
+		until, locked := fail("a@example.com", "192.0.2.1")
+		if wantLocked := i == 3; locked != wantLocked {
+			t.Fatalf("failure %d: locked = %v, want %v", i, locked, wantLocked)
+		}
+		if locked && !until.Equal(now.Add(time.Minute)) {
+			t.Errorf("lockedUntil = %v, want %v", until, now.Add(time.Minute))
+		}
+	}
+	if _, wait, _ := l.Begin(ctx, "a@example.com", "192.0.2.9"); wait != time.Minute {
+		t.Errorf("locked account Begin() = %v, want 1m", wait)
+	}
+	// The IP has not reached its own threshold and is never slowed before
+	// it; refused attempts above did not count towards it either.
+	fail("b@example.com", "192.0.2.1")
+	a, wait, _ := l.Begin(ctx, "c@example.com", "192.0.2.1")
+	if wait != 0 {
+		t.Fatalf("other account from same IP Begin() = %v, want 0", wait)
+	}
+	l.Fail(a)
+	if _, wait, _ := l.Begin(ctx, "d@example.com", "192.0.2.1"); wait != time.Minute {
+		t.Errorf("locked IP Begin() = %v, want 1m", wait)
+	}
+	if _, wait, _ := l.Begin(ctx, "d@example.com", ""); wait != 0 {
+		t.Errorf("Begin() without IP = %v, want 0", wait)
+	}
+
+	if err := l.Reset(ctx, "a@example.com"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if _, wait, _ := l.Begin(ctx, "a@example.com", "192.0.2.9"); wait != 0 {
+		t.Errorf("Begin() after Reset = %v, want 0", wait)
+	}
+	if _, wait, _ := l.Begin(ctx, "a@example.com", "192.0.2.1"); wait != time.Minute {
+		t.Errorf("Reset should not clear the IP: Begin() = %v, want 1m", wait)
+	}
+}
+
+func TestLimiter_SucceedAndCancel(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	account := Policy{MaxFailures: 5, LockoutDuration: time.Minute, BaseDelay: time.Second, MaxDelay: time.Second}
+	l := NewLimiter(NewMemoryCounter(), account, Policy{MaxFailures: 2, LockoutDuration: time.Minute}, time.Hour)
+	l.now = func() time.Time { return now }
+
+	begin := func(email, ip string) *Attempt {
+		t.Helper()
+		a, wait, err := l.Begin(ctx, email, ip)
+		if err != nil || wait != 0 {
+			t.Fatalf("Begin(%s, %s) = %v, %v; want an attempt", email, ip, wait, err)
+		}
+		return a
+	}
+
+	// A cancelled attempt leaves no failure behind, so no backoff either
+	if err := l.Cancel(ctx, begin("a@example.com", "192.0.2.1")); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	l.Fail(begin("a@example.com", "192.0.2.1"))
+
+	// Success clears the account and takes only itself off the IP, which
+	// keeps the earlier failure
+	now = now.Add(time.Second)
+	if err := l.Succeed(ctx, begin("a@example.com", "192.0.2.1")); err != nil {
+		t.Fatalf("Succeed() error = %v", err)
+	}
+	begin("a@example.com", "192.0.2.9")
+	l.Fail(begin("b@example.com", "192.0.2.1"))
+	if _, wait, _ := l.Begin(ctx, "c@example.com", "192.0.2.1"); wait != time.Minute {
+		t.Errorf("Begin() from an IP at its threshold = %v, want 1m", wait)
+	}
+}
+
+func TestLimiter_SettlesCanceledRequests(t *testing.T) {
+	l := NewLimiter(NewMemoryCounter(), Policy{MaxFailures: 1, LockoutDuration: time.Minute}, Policy{}, time.Hour)
+
+	for _, settle := range []func(context.Context, *Attempt) error{l.Cancel, l.Succeed} {
+		ctx, cancel := context.WithCancel(context.Background())
+		a, _, err := l.Begin(ctx, "a@example.com", "192.0.2.1")
+		if err != nil || a == nil {
+			t.Fatalf("Begin() = %v, %v", a, err)
+		}
+		// The client went away while the password was being checked
+		cancel()
+		if err := settle(ctx, a); err != nil {
+			t.Fatalf("settling a canceled request failed: %v", err)
+		}
+		if _, wait, _ := l.Begin(context.Background(), "a@example.com", "192.0.2.1"); wait > 0 {
+			t.Fatalf("a canceled request stayed counted: wait %v", wait)
+		}
+		_ = l.Reset(context.Background(), "a@example.com")
+	}
+}
+
+func TestLimiter_ConcurrentAttempts(t *testing.T) {
+	ctx := context.Background()
+	l := NewLimiter(NewMemoryCounter(), Policy{MaxFailures: 5, LockoutDuration: time.Minute}, Policy{}, time.Hour)
+
+	const n = 50
+	var granted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, wait, err := l.Begin(ctx, "a@example.com", "192.0.2.1")
+			if err != nil {
+				t.Errorf("Begin() error = %v", err)
+				return
+			}
+			if wait == 0 {
+				granted.Add(1)
+				l.Fail(a)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := granted.Load(); got != 5 {
+		t.Errorf("%d concurrent attempts were let through, want 5", got)
+	}
+}
+
+func TestMemoryCounter_Expires(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCounter()
+
+	if _, err := c.Fail(ctx, "k", time.Now().Add(-time.Hour), time.Minute); err != nil {
+		t.Fatalf("Fail() error = %v", err)
+	}
+	prev, err := c.Fail(ctx, "k", time.Now(), time.Minute)
+	if err != nil {
+		t.Fatalf("Fail() error = %v", err)
+	}
+	if prev.Failures != 0 {
+		t.Errorf("expired history Failures = %d, want 0", prev.Failures)
+	}
+	if prev, _ = c.Fail(ctx, "k", time.Now(), time.Minute); prev.Failures != 1 {
+		t.Errorf("Fail() after expiry returned Failures = %d, want 1", prev.Failures)
+	}
+}
+
+func TestMemoryCounter_Sweeps(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCounter()
+
+	c.Fail(ctx, "a", time.Now().Add(-time.Hour), time.Minute)
+	c.Fail(ctx, "b", time.Now().Add(-time.Hour), time.Minute)
+	if len(c.entries) != 2 {
+		t.Fatalf("Expected expired entries to wait for the next sweep, have %d", len(c.entries))
+	}
+	c.lastSweep = time.Now().Add(-memorySweepInterval)
+	c.Fail(ctx, "c", time.Now(), time.Minute)
+	if len(c.entries) != 1 {
+		t.Errorf("Expected expired entries to be swept, have %d", len(c.entries))
+	}
+}
+
+func TestMemoryCounter_Refund(t *testing.T) {
+	ctx := context.Background()
+	c := NewMemoryCounter()
+	t1 := time.Now()
+	t2 := t1.Add(time.Second)
+
+	c.Fail(ctx, "k", t1, time.Minute)
+	prev, _ := c.Fail(ctx, "k", t2, time.Minute)
+	if err := c.Refund(ctx, "k", t2, prev); err != nil {
+		t.Fatalf("Refund() error = %v", err)
+	}
+	got, _ := c.Fail(ctx, "k", t2, time.Minute)
+	if got.Failures != 1 || !got.LastFailure.Equal(t1) {
+		t.Errorf("history after Refund = %+v, want 1 failure at %v", got, t1)
+	}
+}
+
+func TestMemoryCounter_CanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := NewMemoryCounter()
+	if _, err := c.Fail(ctx, "k", time.Now(), time.Minute); err == nil {
+		t.Error("Fail() with canceled context should fail")
+	}
+	if err := c.Refund(ctx, "k", time.Now(), Attempts{}); err == nil {
+		t.Error("Refund() with canceled context should fail")
+	}
+	if err := c.Reset(ctx, "k"); err == nil {
+		t.Error("Reset() with canceled context should fail")
+	}
+}
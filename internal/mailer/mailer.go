@@ -0,0 +1,66 @@
+// Package mailer delivers account notifications to users.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends a message. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogMailer writes messages to the process log instead of delivering them.
+// Message bodies may carry secrets such as one-time links, so use it for
+// development only.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	log.Printf("mail to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileMailer writes each message to its own file in Dir, for development
+// and end-to-end tests that need to read the mail a flow sends. Like
+// LogMailer, it leaves secrets readable on disk.
+type FileMailer struct {
+	Dir string
+}
+
+// NewFileMailer creates dir if needed and returns a FileMailer writing to it.
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// The timestamp keeps files in send order; CreateTemp's random suffix
+	// keeps concurrent sends apart.
+	f, err := os.CreateTemp(m.Dir, time.Now().UTC().Format("20060102T150405.000000000Z")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "To: %s\r\nSubject: %s\r\n\r\n%s\r\n", msg.To, msg.Subject, msg.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir)
+	if err != nil {
+		t.Fatalf("NewFileMailer() failed: %v", err)
+	}
+
+	for _, to := range []string{"one@example.com", "two@example.com"} {
+		err := m.Send(context.Background(), Message{To: to, Subject: "Hello", Body: "link: https://example.com/x"})
+		if err != nil {
+			t.Fatalf("Send() failed: %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(entries))
+	}
+	b, _ := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	for _, want := range []string{"To: one@example.com", "Subject: Hello", "link: https://example.com/x"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("Expected first message to contain %q, got %q", want, b)
+		}
+	}
+}
+
+func TestFileMailerCanceledContext(t *testing.T) {
+	m, _ := NewFileMailer(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Send(ctx, Message{To: "one@example.com"}); err == nil {
+		t.Error("Send() with canceled context should fail")
+	}
+}
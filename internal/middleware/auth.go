@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying the verified token claims.
+func WithClaims(ctx context.Context, claims *token.Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by AuthMiddleware, if any.
+func ClaimsFromContext(ctx context.Context) (*token.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*token.Claims)
+	return claims, ok && claims != nil
+}
+
+// AuthMiddleware rejects requests without a valid bearer token and makes the
+// verified claims available to next via ClaimsFromContext. opts add
+// requirements such as an expected audience.
+func AuthMiddleware(tm token.Manager, next http.HandlerFunc, opts ...token.VerifyOption) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		fields := strings.Fields(auth)
+		if len(fields) != 2 || fields[0] != "Bearer" {
+			http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+			return
+		}
+		claims, err := tm.Verify(fields[1], opts...)
+		if err != nil {
+			http.Error(w, `{"error":"invalid token"}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+	}
+}
+
+// RequireScope rejects requests whose token was not granted scope. It must
+// run inside AuthMiddleware.
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+			return
+		}
+		if !claims.HasScope(scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			http.Error(w, `{"error":"insufficient scope"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireAMR rejects requests whose token does not come from a signin that
+// used method, such as token.AMRMulti for multi-factor signins. It must run
+// inside AuthMiddleware.
+func RequireAMR(method string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
+			return
+		}
+		if !claims.HasAMR(method) {
+			// Step-up challenge, RFC 9470.
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_user_authentication"`)
+			http.Error(w, `{"error":"stronger authentication required"}`, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
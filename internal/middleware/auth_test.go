@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	tm := token.NewJWTManager("test-secret-key", 15*time.Minute)
+	userID := uuid.New()
+	valid, err := tm.Generate(userID, "test@example.com")
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{"valid token", "Bearer " + valid, http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + valid, http.StatusUnauthorized},
+		{"invalid token", "Bearer not-a-jwt", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *token.Claims
+			next := func(w http.ResponseWriter, r *http.Request) {
+				got, _ = ClaimsFromContext(r.Context())
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			AuthMiddleware(tm, next)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got == nil || got.UserID != userID.String() {
+					t.Errorf("Expected claims for %s on context, got %+v", userID, got)
+				}
+			} else if got != nil {
+				t.Error("next handler should not run for rejected requests")
+			}
+		})
+	}
+}
+
+func TestClaimsFromContextMissing(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if _, ok := ClaimsFromContext(req.Context()); ok {
+		t.Error("ClaimsFromContext() should report false without AuthMiddleware")
+	}
+}
+
+func TestAuthMiddleware_VerifyOptions(t *testing.T) {
+	tm := token.NewJWTManager("test-secret-key", 15*time.Minute, token.WithDefaultAudience("web"))
+	valid, _ := tm.Generate(uuid.New(), "test@example.com")
+
+	tests := []struct {
+		name       string
+		audience   string
+		wantStatus int
+	}{
+		{"matching audience", "web", http.StatusOK},
+		{"other audience", "admin-console", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := func(w http.ResponseWriter, r *http.Request) {}
+
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+valid)
+			w := httptest.NewRecorder()
+			AuthMiddleware(tm, next, token.RequireAudience(tt.audience))(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     *token.Claims
+		wantStatus int
+	}{
+		{"granted", &token.Claims{Scope: "profile admin"}, http.StatusOK},
+		{"not granted", &token.Claims{Scope: "profile"}, http.StatusForbidden},
+		{"no scopes", &token.Claims{}, http.StatusForbidden},
+		{"no claims", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := func(w http.ResponseWriter, r *http.Request) { called = true }
+
+			req := httptest.NewRequest(http.MethodPost, "/admin", nil)
+			if tt.claims != nil {
+				req = req.WithContext(WithClaims(req.Context(), tt.claims))
+			}
+			w := httptest.NewRecorder()
+			RequireScope("admin", next)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("next called = %v, want %v", called, tt.wantStatus == http.StatusOK)
+			}
+			if tt.wantStatus == http.StatusForbidden && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header on 403")
+			}
+		})
+	}
+}
+
+func TestRequireAMR(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     *token.Claims
+		wantStatus int
+	}{
+		{"multi-factor", &token.Claims{AMR: []string{"pwd", "otp", "mfa"}}, http.StatusOK},
+		{"password only", &token.Claims{AMR: []string{"pwd"}}, http.StatusUnauthorized},
+		{"no amr", &token.Claims{}, http.StatusUnauthorized},
+		{"no claims", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			next := func(w http.ResponseWriter, r *http.Request) { called = true }
+
+			req := httptest.NewRequest(http.MethodPost, "/admin", nil)
+			if tt.claims != nil {
+				req = req.WithContext(WithClaims(req.Context(), tt.claims))
+			}
+			w := httptest.NewRecorder()
+			RequireAMR(token.AMRMulti, next)(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if called != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("next called = %v, want %v", called, tt.wantStatus == http.StatusOK)
+			}
+			if tt.claims != nil && tt.wantStatus != http.StatusOK && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header on a step-up challenge")
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// ParseTrustedProxies parses CIDR prefixes or bare addresses of the proxies
+// allowed to report client addresses in X-Forwarded-For.
+func ParseTrustedProxies(items []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		if p, err := netip.ParsePrefix(item); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: not an address or CIDR prefix", item)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// RealIP resolves the client address of each request for ClientIP. When the
+// connection comes from a trusted proxy, X-Forwarded-For is walked from the
+// right, past further trusted proxies, to the first address they did not
+// add themselves; anything left of it could have been forged by the client.
+// With no trusted proxies the header is ignored.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r)
+			if addr, err := netip.ParseAddr(ip); err == nil && isTrusted(addr) {
+				ip = forwardedFor(r, isTrusted, ip)
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// forwardedFor returns the rightmost untrusted X-Forwarded-For address, or
+// the leftmost one if every hop is trusted. Unparseable entries end the walk
+// at the last good address.
+func forwardedFor(r *http.Request, isTrusted func(netip.Addr) bool, fallback string) string {
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	ip := fallback
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		ip = addr.Unmap().String()
+		if !isTrusted(addr) {
+			break
+		}
+	}
+	return ip
+}
+
+// ClientIP returns the client address resolved by RealIP, or the address r
+// was received from when RealIP is not in use.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/ratelimit"
+	"github.com/coinbase/identity-service/internal/validator"
+)
+
+// maxKeyBody bounds how much of a request body KeyByEmail reads.
+const maxKeyBody = 64 << 10
+
+// KeyFunc picks the bucket a request is counted against. An empty key
+// exempts the request from the limit.
+type KeyFunc func(r *http.Request) string
+
+// KeyByIP keys requests by ClientIP.
+func KeyByIP(r *http.Request) string {
+	return ClientIP(r)
+}
+
+// KeyByEmail keys requests by the normalized email in their JSON body, which
+// is left intact for the handler. Bodies without a valid email are exempt;
+// the handler rejects them anyway.
+func KeyByEmail(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxKeyBody))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return ""
+	}
+	var req struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	email, err := validator.NormalizeEmail(req.Email)
+	if err != nil {
+		return ""
+	}
+	return email
+}
+
+// RateLimit counts requests against l under the key chosen by key and
+// answers 429 once the bucket is empty. Every counted response carries the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers; when
+// several limits apply, the one closest to running out is reported.
+func RateLimit(l *ratelimit.Limiter, key KeyFunc, next http.HandlerFunc) http.HandlerFunc {
+	if !l.Enabled() {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		k := key(r)
+		if k == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		res := l.Allow(k)
+		setRateLimitHeaders(w.Header(), res)
+		if !res.Allowed {
+			SetRetryAfter(w, res.RetryAfter)
+			http.Error(w, `{"error":"too many requests"}`, http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+func setRateLimitHeaders(h http.Header, res ratelimit.Result) {
+	if prev, err := strconv.Atoi(h.Get("RateLimit-Remaining")); err == nil && prev <= res.Remaining {
+		return
+	}
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", seconds(res.Reset))
+}
+
+// SetRetryAfter sets the Retry-After header to d in whole seconds, rounded
+// up.
+func SetRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", seconds(d))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
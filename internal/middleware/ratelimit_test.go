@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limit{Requests: 2, Per: time.Minute})
+	h := RateLimit(l, KeyByIP, func(w http.ResponseWriter, r *http.Request) {})
+
+	do := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/signin", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	for i, wantRemaining := range []string{"1", "0"} {
+		w := do("203.0.113.5:1")
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d", i+1, w.Code)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != wantRemaining {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i+1, got, wantRemaining)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("RateLimit-Limit = %q, want 2", got)
+		}
+	}
+
+	w := do("203.0.113.5:2")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Retry-After = %q, want 30", got)
+	}
+	if got := w.Header().Get("RateLimit-Reset"); got != "60" {
+		t.Errorf("RateLimit-Reset = %q, want 60", got)
+	}
+
+	if w := do("198.51.100.1:1"); w.Code != http.StatusOK {
+		t.Errorf("Expected another IP to be allowed, got %d", w.Code)
+	}
+}
+
+func TestRateLimitReportsTightestLimit(t *testing.T) {
+	loose := ratelimit.NewLimiter(ratelimit.Limit{Requests: 10, Per: time.Minute})
+	tight := ratelimit.NewLimiter(ratelimit.Limit{Requests: 3, Per: time.Minute})
+	h := RateLimit(tight, KeyByIP, RateLimit(loose, KeyByIP, func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodPost, "/signin", nil))
+	if got := w.Header().Get("RateLimit-Limit"); got != "3" {
+		t.Errorf("RateLimit-Limit = %q, want the tighter limit 3", got)
+	}
+}
+
+func TestKeyByEmail(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"email":" Test@Example.com ","password":"x"}`, "test@example.com"},
+		{`{"password":"x"}`, ""},
+		{`{"email":"not-an-email"}`, ""},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/signin", strings.NewReader(tt.body))
+		if got := KeyByEmail(req); got != tt.want {
+			t.Errorf("KeyByEmail(%s) = %q, want %q", tt.body, got, tt.want)
+		}
+		// The handler still sees the whole body
+		rest, _ := io.ReadAll(req.Body)
+		if string(rest) != tt.body {
+			t.Errorf("Body after KeyByEmail = %q, want %q", rest, tt.body)
+		}
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	l := ratelimit.NewLimiter(ratelimit.Limit{})
+	h := RateLimit(l, KeyByIP, func(w http.ResponseWriter, r *http.Request) {})
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		h(w, httptest.NewRequest(http.MethodPost, "/signin", nil))
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("Expected a disabled limit to pass without headers, got %d %v", w.Code, w.Header())
+		}
+	}
+}
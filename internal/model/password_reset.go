@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PasswordResetToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string // SHA-256 of the emailed token; the token itself is never stored
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time // set once the token has reset the password or been superseded
+}
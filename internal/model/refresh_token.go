@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RefreshToken struct {
+	ID        uuid.UUID
+	FamilyID  uuid.UUID // shared by every token rotated from the same signin
+	UserID    uuid.UUID
+	TokenHash string // SHA-256 of the opaque token; the token itself is never stored
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time // set once the token has been exchanged
+	RevokedAt *time.Time
+	// AMR lists the authentication methods of the signin that started the
+	// family, carried into every access token refreshed from it.
+	AMR []string
+}
@@ -9,7 +9,35 @@ import (
 type User struct {
 	ID        uuid.UUID
 	Email     string
-	Password  string // bcrypt hash
+	Password  string // self-describing hash, see pkg/hash
+	Version   int64  // bumped on every update, used for optimistic concurrency
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	DeletedAt *time.Time
+
+	// LockedUntil is set while signin is locked after repeated failures.
+	LockedUntil *time.Time
+	// EmailVerifiedAt is when the owner proved control of Email; nil until
+	// then.
+	EmailVerifiedAt *time.Time
+	// PendingEmail is the address the user asked to switch to, until they
+	// confirm it from that inbox or cancel; empty when there is none.
+	PendingEmail string
+
+	// TOTPSecret is the user's TOTP seed sealed with the MFA key (see
+	// pkg/seal); empty until they start enrolling.
+	TOTPSecret string
+	// TOTPEnabledAt is when the user confirmed TOTP enrollment; from then
+	// on signin asks for a code. Nil while enrollment is unconfirmed.
+	TOTPEnabledAt *time.Time
+	// TOTPLastStep is the time step of the last code accepted. Codes from
+	// it or earlier steps are refused, so none can be replayed.
+	TOTPLastStep int64
+	// RecoveryCodes holds HMACs of the user's unused MFA recovery codes,
+	// keyed by the server's MFA key. Each code stands in for a TOTP code once.
+	RecoveryCodes []string
+
+	// Scopes are granted to this user's access tokens on top of the ones
+	// every user gets, e.g. admin for operators.
+	Scopes []string
 }
@@ -0,0 +1,139 @@
+// Package ratelimit implements token-bucket request limits per key.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Limit allows bursts of up to Requests, refilled at Requests per Per.
+// A zero Limit allows everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses "N/duration", such as "10/1m". "" and "0" disable the
+// limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+	n, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q: want N/duration", ErrInvalidLimit, s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 0 {
+		return Limit{}, fmt.Errorf("%w: %q: bad request count", ErrInvalidLimit, s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q: bad period", ErrInvalidLimit, s)
+	}
+	return Limit{Requests: requests, Per: d}, nil
+}
+
+func (l Limit) String() string {
+	if l.Requests == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// Result describes the bucket after a request was counted against it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long a refused request must wait for a token.
+	RetryAfter time.Duration
+}
+
+// Limiter keeps a token bucket per key in memory, so every instance of the
+// service enforces its limits separately.
+type Limiter struct {
+	limit Limit
+	rate  float64 // tokens per second
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	l := &Limiter{limit: limit, buckets: map[string]*bucket{}, now: time.Now}
+	if limit.Requests > 0 {
+		l.rate = float64(limit.Requests) / limit.Per.Seconds()
+	}
+	return l
+}
+
+// Enabled reports whether the limiter refuses anything at all.
+func (l *Limiter) Enabled() bool { return l.limit.Requests > 0 }
+
+// Allow takes a token from key's bucket if one is available.
+func (l *Limiter) Allow(key string) Result {
+	if !l.Enabled() {
+		return Result{Allowed: true}
+	}
+	now := l.now()
+	burst := float64(l.limit.Requests)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	b.updated = now
+
+	res := Result{Limit: l.limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.refill(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.refill(burst - b.tokens)
+	return res
+}
+
+// refill returns how long it takes to earn tokens.
+func (l *Limiter) refill(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets untouched for a whole period, which are full again
+// and so indistinguishable from new ones. It runs at most once per period.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.limit.Per {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) >= l.limit.Per {
+			delete(l.buckets, key)
+		}
+	}
+}
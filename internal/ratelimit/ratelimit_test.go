@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{"", Limit{}, false},
+		{"0", Limit{}, false},
+		{"10/1m", Limit{Requests: 10, Per: time.Minute}, false},
+		{" 5/30s ", Limit{Requests: 5, Per: 30 * time.Second}, false},
+		{"10", Limit{}, true},
+		{"x/1m", Limit{}, true},
+		{"-1/1m", Limit{}, true},
+		{"10/0s", Limit{}, true},
+		{"10/soon", Limit{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("ParseLimit(%q) error = %v, want ErrInvalidLimit", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{Requests: 3, Per: 3 * time.Second})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		res := l.Allow("a")
+		if !res.Allowed || res.Remaining != 2-i || res.Limit != 3 {
+			t.Fatalf("request %d: got %+v", i+1, res)
+		}
+	}
+	res := l.Allow("a")
+	if res.Allowed {
+		t.Fatal("Expected the fourth request to be refused")
+	}
+	if res.RetryAfter != time.Second || res.Reset != 3*time.Second {
+		t.Errorf("RetryAfter = %v, Reset = %v, want 1s and 3s", res.RetryAfter, res.Reset)
+	}
+
+	// Other keys have their own bucket
+	if !l.Allow("b").Allowed {
+		t.Error("Expected another key to be allowed")
+	}
+
+	// One token is earned back per second
+	now = now.Add(time.Second)
+	if !l.Allow("a").Allowed {
+		t.Error("Expected a request after refill to be allowed")
+	}
+	if l.Allow("a").Allowed {
+		t.Error("Expected only one token to have been refilled")
+	}
+
+	// Buckets never hold more than the burst
+	now = now.Add(time.Hour)
+	if res := l.Allow("a"); res.Remaining != 2 {
+		t.Errorf("Remaining after a long idle = %d, want 2", res.Remaining)
+	}
+}
+
+func TestLimiter_Disabled(t *testing.T) {
+	l := NewLimiter(Limit{})
+	for i := 0; i < 100; i++ {
+		if !l.Allow("a").Allowed {
+			t.Fatal("A zero limit should allow everything")
+		}
+	}
+}
+
+func TestLimiter_SweepsIdleBuckets(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{Requests: 1, Per: time.Minute})
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	l.Allow("b")
+	now = now.Add(2 * time.Minute)
+	l.Allow("c")
+	if len(l.buckets) != 1 {
+		t.Errorf("Expected idle buckets to be swept, have %d", len(l.buckets))
+	}
+}
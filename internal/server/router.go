@@ -2,23 +2,102 @@ package server
 
 import (
 	"net/http"
-	"strings"
+	"net/netip"
 
 	"github.com/gorilla/mux"
 
 	"github.com/coinbase/identity-service/internal/handler"
 	"github.com/coinbase/identity-service/internal/middleware"
+	"github.com/coinbase/identity-service/internal/ratelimit"
 	"github.com/coinbase/identity-service/internal/service"
 	"github.com/coinbase/identity-service/pkg/token"
 )
 
-func NewRouter(authSvc *service.AuthService, tm token.Manager) *mux.Router {
+// Option configures NewRouter.
+type Option func(*routerConfig)
+
+type routerConfig struct {
+	trustedProxies []netip.Prefix
+	limits         map[string][]routeLimit
+	adminMFA       bool
+	verify         []token.VerifyOption
+}
+
+type routeLimit struct {
+	limiter *ratelimit.Limiter
+	key     middleware.KeyFunc
+}
+
+// WithTrustedProxies trusts X-Forwarded-For from these proxies when
+// resolving client IPs for rate limits and signin lockout.
+func WithTrustedProxies(prefixes []netip.Prefix) Option {
+	return func(c *routerConfig) { c.trustedProxies = prefixes }
+}
+
+// WithRateLimit limits requests to path to limit per key. Limits added for
+// the same path all apply, in the order given.
+func WithRateLimit(path string, limit ratelimit.Limit, key middleware.KeyFunc) Option {
+	return func(c *routerConfig) {
+		c.limits[path] = append(c.limits[path], routeLimit{limiter: ratelimit.NewLimiter(limit), key: key})
+	}
+}
+
+// WithAdminMFA only lets tokens from multi-factor signins reach the admin
+// endpoints.
+func WithAdminMFA() Option {
+	return func(c *routerConfig) { c.adminMFA = true }
+}
+
+// WithRequiredAudience rejects tokens whose aud does not include aud on
+// every authenticated endpoint.
+func WithRequiredAudience(aud string) Option {
+	return func(c *routerConfig) { c.verify = append(c.verify, token.RequireAudience(aud)) }
+}
+
+// authenticated wraps h in AuthMiddleware with the configured token
+// requirements.
+func (c *routerConfig) authenticated(tm token.Manager, h http.HandlerFunc) http.HandlerFunc {
+	return middleware.AuthMiddleware(tm, h, c.verify...)
+}
+
+// account wraps h in the checks every endpoint acting on the caller's own
+// account applies.
+func (c *routerConfig) account(tm token.Manager, h http.HandlerFunc) http.HandlerFunc {
+	return c.authenticated(tm, middleware.RequireScope(service.ScopeProfile, h))
+}
+
+// admin wraps h in the checks every admin endpoint applies.
+func (c *routerConfig) admin(h http.HandlerFunc) http.HandlerFunc {
+	if c.adminMFA {
+		h = middleware.RequireAMR(token.AMRMulti, h)
+	}
+	return middleware.RequireScope(service.ScopeAdmin, h)
+}
+
+// limited wraps h in the rate limits configured for path.
+func (c *routerConfig) limited(path string, h http.HandlerFunc) http.HandlerFunc {
+	limits := c.limits[path]
+	for i := len(limits) - 1; i >= 0; i-- {
+		h = middleware.RateLimit(limits[i].limiter, limits[i].key, h)
+	}
+	return h
+}
+
+func NewRouter(authSvc *service.AuthService, tm token.Manager, opts ...Option) *mux.Router {
+	cfg := &routerConfig{limits: map[string][]routeLimit{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	authHandler := handler.NewAuthHandler(authSvc)
 	healthHandler := handler.NewHealthHandler()
+	keysHandler := handler.NewKeysHandler(tm)
+	adminHandler := handler.NewAdminHandler(authSvc)
 
 	r := mux.NewRouter()
 
 	// Global middleware
+	r.Use(middleware.RealIP(cfg.trustedProxies))
 	r.Use(middleware.LoggingMiddleware)
 	r.Use(jsonMiddleware)
 
@@ -26,12 +105,33 @@ func NewRouter(authSvc *service.AuthService, tm token.Manager) *mux.Router {
 	r.HandleFunc("/health", healthHandler.Health).Methods(http.MethodGet)
 	r.HandleFunc("/ready", healthHandler.Ready).Methods(http.MethodGet)
 
+	// Public signing keys for verifiers of our access tokens
+	r.HandleFunc("/.well-known/jwks.json", keysHandler.JWKS).Methods(http.MethodGet)
+
 	// Authentication endpoints
-	r.HandleFunc("/signup", authHandler.Signup).Methods(http.MethodPost)
-	r.HandleFunc("/signin", authHandler.Signin).Methods(http.MethodPost)
+	r.HandleFunc("/signup", cfg.limited("/signup", authHandler.Signup)).Methods(http.MethodPost)
+	r.HandleFunc("/signin", cfg.limited("/signin", authHandler.Signin)).Methods(http.MethodPost)
+	r.HandleFunc("/signin/mfa", cfg.limited("/signin/mfa", authHandler.SigninMFA)).Methods(http.MethodPost)
+	r.HandleFunc("/token/refresh", cfg.limited("/token/refresh", authHandler.Refresh)).Methods(http.MethodPost)
+	r.HandleFunc("/verify-email", cfg.limited("/verify-email", authHandler.VerifyEmail)).Methods(http.MethodPost)
+	r.HandleFunc("/verify-email/resend", cfg.limited("/verify-email/resend", authHandler.ResendVerification)).Methods(http.MethodPost)
+	r.HandleFunc("/password/forgot", cfg.limited("/password/forgot", authHandler.ForgotPassword)).Methods(http.MethodPost)
+	r.HandleFunc("/password/reset", cfg.limited("/password/reset", authHandler.ResetPassword)).Methods(http.MethodPost)
+	r.HandleFunc("/email-change/confirm", cfg.limited("/email-change/confirm", authHandler.ConfirmEmailChange)).Methods(http.MethodPost)
+	r.HandleFunc("/email-change/cancel", cfg.limited("/email-change/cancel", authHandler.CancelEmailChange)).Methods(http.MethodPost)
 
 	// Protected endpoints
-	r.Handle("/me", authMiddleware(tm, authHandler.Me)).Methods(http.MethodGet)
+	r.Handle("/me", cfg.account(tm, authHandler.Me)).Methods(http.MethodGet)
+	r.Handle("/me/password", cfg.account(tm, authHandler.ChangePassword)).Methods(http.MethodPost)
+	r.Handle("/me/email", cfg.account(tm, authHandler.RequestEmailChange)).Methods(http.MethodPost)
+	r.Handle("/me/mfa/totp", cfg.account(tm, authHandler.EnrollTOTP)).Methods(http.MethodPost)
+	r.Handle("/me/mfa/totp/confirm", cfg.account(tm, authHandler.ConfirmTOTP)).Methods(http.MethodPost)
+	r.Handle("/me/mfa/recovery-codes", cfg.account(tm, authHandler.RegenerateRecoveryCodes)).Methods(http.MethodPost)
+	r.Handle("/logout", cfg.account(tm, authHandler.Logout)).Methods(http.MethodPost)
+	r.Handle("/logout/all", cfg.account(tm, authHandler.LogoutAll)).Methods(http.MethodPost)
+
+	// Administration
+	r.Handle("/admin/users/{id}/unlock", cfg.authenticated(tm, cfg.admin(adminHandler.Unlock))).Methods(http.MethodPost)
 
 	return r
 }
@@ -42,23 +142,3 @@ func jsonMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
-func authMiddleware(tm token.Manager, next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		auth := r.Header.Get("Authorization")
-		fields := strings.Fields(auth)
-		if len(fields) != 2 || fields[0] != "Bearer" {
-			http.Error(w, `{"error":"missing token"}`, http.StatusUnauthorized)
-			return
-		}
-		claims, err := tm.Verify(fields[1])
-		if err != nil {
-			http.Error(w, `{"error":"invalid token"}`, http.StatusUnauthorized)
-			return
-		}
-		// attach claims to context if needed
-		r = r.WithContext(r.Context())
-		_ = claims
-		next.ServeHTTP(w, r)
-	}
-}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/mailer"
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/service"
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+func TestRouter_RequiredAudience(t *testing.T) {
+	users := memory.NewUserStore()
+	u := &model.User{Email: "test@example.com", Password: "$2a$04$unused"}
+	if err := users.Create(context.Background(), u); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	tm := token.NewJWTManager("test-secret-key", 15*time.Minute)
+	authSvc := service.NewAuthService(users, hash.Bcrypt{Cost: 4}, tm)
+	r := NewRouter(authSvc, tm, WithRequiredAudience("identity"))
+
+	tests := []struct {
+		name string
+		aud  []string
+		want int
+	}{
+		{"required audience", []string{"web", "identity"}, http.StatusOK},
+		{"other audience", []string{"web"}, http.StatusUnauthorized},
+		{"no audience", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			access, err := tm.Generate(u.ID, u.Email,
+				token.WithAudience(tt.aud...), token.WithScopes(service.ScopeProfile))
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			req.Header.Set("Authorization", "Bearer "+access)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("GET /me: expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestRouter_AccountRoutesRequireProfileScope(t *testing.T) {
+	tm := token.NewJWTManager("test-secret-key", 15*time.Minute)
+	authSvc := service.NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4}, tm,
+		service.WithScopes(service.ScopeProfile),
+		service.WithRefreshTokens(memory.NewRefreshTokenStore(), time.Hour),
+		service.WithEmailVerification(service.EmailVerification{
+			Mailer:  mailer.LogMailer{},
+			Signer:  token.NewActionSigner([]byte("action-secret-key-for-tests-only")),
+			TTL:     time.Hour,
+			LinkURL: "https://app.example.com/verify?token=",
+		}))
+	r := NewRouter(authSvc, tm)
+
+	// Restrict mode with no UNVERIFIED_TOKEN_SCOPES
+	tokens, err := authSvc.Signup(context.Background(), "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	routes := []struct{ method, path string }{
+		{http.MethodGet, "/me"},
+		{http.MethodPost, "/me/password"},
+		{http.MethodPost, "/me/email"},
+		{http.MethodPost, "/me/mfa/totp"},
+		{http.MethodPost, "/me/mfa/totp/confirm"},
+		{http.MethodPost, "/me/mfa/recovery-codes"},
+		{http.MethodPost, "/logout"},
+		{http.MethodPost, "/logout/all"},
+	}
+	for _, rt := range routes {
+		req := httptest.NewRequest(rt.method, rt.path, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s with an unverified token: expected status 403, got %d", rt.method, rt.path, w.Code)
+		}
+	}
+}
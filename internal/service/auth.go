@@ -3,7 +3,16 @@ package service
 import (
 	"context"
 	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/audit"
+	"github.com/coinbase/identity-service/internal/lockout"
+	"github.com/coinbase/identity-service/internal/mailer"
 	"github.com/coinbase/identity-service/internal/model"
 	"github.com/coinbase/identity-service/internal/store"
 	"github.com/coinbase/identity-service/pkg/hash"
@@ -11,43 +20,635 @@ import (
 )
 
 var (
-	ErrUserExists   = errors.New("user already exists")
-	ErrInvalidCreds = errors.New("invalid credentials")
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists          = errors.New("user already exists")
+	ErrInvalidCreds        = errors.New("invalid credentials")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrTooManyAttempts     = errors.New("too many failed signin attempts")
+	// ErrOverloaded means password hashing is saturated and the request was
+	// shed rather than queued indefinitely; the client should retry later.
+	ErrOverloaded = errors.New("service overloaded")
+)
+
+// LockedError rejects a signin made before the backoff or lockout earned by
+// earlier failures has ended. It matches ErrTooManyAttempts.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string { return ErrTooManyAttempts.Error() }
+func (e *LockedError) Unwrap() error { return ErrTooManyAttempts }
+
+const (
+	// ScopeProfile grants access to the caller's own account: reading the
+	// profile, changing the password, email and MFA settings, and logging out.
+	ScopeProfile = "profile"
+	// ScopeAdmin grants account administration, such as lifting lockouts.
+	ScopeAdmin = "admin"
 )
 
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of ctx carrying the address the request came
+// from, which Signin throttles on alongside the account.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// Tokens is the credential set handed to a client after authenticating.
+// RefreshToken is empty when refresh tokens are not enabled.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
 type AuthService struct {
 	users  store.UserStore
-	hasher hash.Bcrypt
+	hasher hash.Hasher
 	tokens token.Manager
+
+	// pool, when set, bounds concurrent hashing; see hash and compare.
+	pool *hash.Pool
+
+	refresh    store.RefreshTokenStore
+	refreshTTL time.Duration
+
+	scopes []string
+
+	// safeSignupMailer, when set, makes Signup enumeration-safe.
+	safeSignupMailer mailer.Mailer
+
+	lockout *lockout.Limiter
+
+	verification *EmailVerification
+
+	reset *PasswordReset
+
+	emailChange *EmailChange
+
+	mfa *MFA
+
+	auditor audit.Recorder
+
+	history     store.PasswordHistoryStore
+	historySize int
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
-func NewAuthService(us store.UserStore, h hash.Bcrypt, t token.Manager) *AuthService {
-	return &AuthService{users: us, hasher: h, tokens: t}
+// Option configures optional AuthService features.
+type Option func(*AuthService)
+
+// WithRefreshTokens enables opaque refresh tokens persisted in rs and valid
+// for ttl from the moment they are issued.
+func WithRefreshTokens(rs store.RefreshTokenStore, ttl time.Duration) Option {
+	return func(a *AuthService) {
+		a.refresh = rs
+		a.refreshTTL = ttl
+	}
 }
 
-func (a *AuthService) Signup(ctx context.Context, email, password string) (string, error) {
-	if existing, _ := a.users.GetByEmail(ctx, email); existing != nil {
-		return "", ErrUserExists
+// WithScopes grants scopes to every access token the service issues. Scopes
+// for particular users, such as ScopeAdmin, belong in model.User.Scopes.
+func WithScopes(scopes ...string) Option {
+	return func(a *AuthService) { a.scopes = scopes }
+}
+
+// WithEnumerationSafeSignup makes Signup respond the same way whether or not
+// the email is already registered: it never issues tokens, and the owner of
+// an existing account is told about the attempt through m instead.
+func WithEnumerationSafeSignup(m mailer.Mailer) Option {
+	return func(a *AuthService) { a.safeSignupMailer = m }
+}
+
+// WithLockout throttles failed signins through l, delaying and then locking
+// accounts and client IPs that keep guessing.
+func WithLockout(l *lockout.Limiter) Option {
+	return func(a *AuthService) { a.lockout = l }
+}
+
+// WithHashPool runs password hashing and comparison on at most workers
+// goroutines at once, with up to queue requests waiting no longer than
+// queueTimeout each. Requests beyond that fail with ErrOverloaded.
+func WithHashPool(workers, queue int, queueTimeout time.Duration) Option {
+	return func(a *AuthService) { a.pool = hash.NewPool(a.hasher, workers, queue, queueTimeout) }
+}
+
+// WithAudit records security-relevant account events, such as recovery
+// code use, in r.
+func WithAudit(r audit.Recorder) Option {
+	return func(a *AuthService) { a.auditor = r }
+}
+
+func NewAuthService(us store.UserStore, h hash.Hasher, t token.Manager, opts ...Option) *AuthService {
+	a := &AuthService{users: us, hasher: h, tokens: t}
+	for _, opt := range opts {
+		opt(a)
 	}
-	hashPw, err := a.hasher.Hash(password)
+	return a
+}
+
+// Signup registers a user and signs them in. With enumeration-safe signup
+// enabled it returns nil tokens and a nil error both for new and already
+// registered emails; new users sign in separately. So it does when email
+// verification blocks signin, as the new user is not verified yet.
+func (a *AuthService) Signup(ctx context.Context, email, password string) (*Tokens, error) {
+	// Hashing happens before the uniqueness check on every path, so the
+	// response time does not tell new emails from registered ones.
+	hashPw, err := a.hash(ctx, password)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	// Uniqueness is enforced by the store in the same step as the insert; a
+	// separate lookup first would let concurrent signups race past it.
 	u := &model.User{Email: email, Password: hashPw}
 	if err := a.users.Create(ctx, u); err != nil {
-		return "", err
+		if !errors.Is(err, store.ErrDuplicateEmail) {
+			return nil, err
+		}
+		if a.safeSignupMailer == nil {
+			return nil, ErrUserExists
+		}
+		a.notifyExistingAccount(ctx, email)
+		return nil, nil
+	}
+	if a.verification != nil {
+		if err := a.sendVerification(ctx, u); err != nil {
+			log.Printf("send verification to user %s: %v", u.ID, err)
+		}
 	}
-	return a.tokens.Generate(u.ID, u.Email)
+	if a.safeSignupMailer != nil || !a.canSignin(u) {
+		return nil, nil
+	}
+	return a.issue(ctx, u, uuid.New(), []string{token.AMRPassword})
+}
+
+// notifyExistingAccount tells the owner of email that someone tried to
+// register it.
+func (a *AuthService) notifyExistingAccount(ctx context.Context, email string) {
+	a.sendAsync(ctx, a.safeSignupMailer, mailer.Message{
+		To:      email,
+		Subject: "Sign-up attempt for your account",
+		Body: "Someone tried to create an account with this email address, " +
+			"which is already registered.\n\nIf it was you, sign in instead or reset " +
+			"your password. Otherwise you can ignore this message.",
+	})
+}
+
+// sendAsync sends msg in the background, so mail latency neither slows the
+// request down nor sets apart the paths that send mail from those that do
+// not. Failures are logged.
+func (a *AuthService) sendAsync(ctx context.Context, m mailer.Mailer, msg mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	go func() {
+		defer cancel()
+		if err := m.Send(ctx, msg); err != nil {
+			log.Printf("send %q mail: %v", msg.Subject, err)
+		}
+	}()
 }
 
-func (a *AuthService) Signin(ctx context.Context, email, password string) (string, error) {
+// Signin exchanges an email and password for a token set. Unknown emails
+// and wrong passwords both yield ErrInvalidCreds after a full password
+// comparison, so neither the error nor the timing reveals which emails are
+// registered. With lockout enabled, attempts made too soon after earlier
+// failures are refused with a *LockedError before any password is checked.
+// Users with TOTP enabled get an *MFARequiredError carrying the challenge
+// to complete with SigninMFA instead of tokens.
+func (a *AuthService) Signin(ctx context.Context, email, password string) (*Tokens, error) {
+	attempt, err := a.beginAttempt(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
 	u, err := a.users.GetByEmail(ctx, email)
-	if err != nil || u == nil {
-		return "", ErrUserNotFound
+	if err != nil {
+		a.cancelAttempt(ctx, attempt)
+		return nil, err
+	}
+	// The stored lock outlives counters kept in memory across restarts. It is
+	// answered and counted like a wrong password rather than refused
+	// outright, so that a restart does not set locked accounts apart from
+	// unknown emails, whose locks live only in the counters.
+	locked := u != nil && u.LockedUntil != nil && time.Until(*u.LockedUntil) > 0
+	var hashed string
+	if u != nil {
+		hashed = u.Password
+	} else {
+		hashed = a.dummyPasswordHash()
+	}
+	// A shed comparison fails the same way for known and unknown emails,
+	// and does not count as a failed attempt.
+	match, err := a.compare(ctx, hashed, password)
+	if err != nil {
+		a.cancelAttempt(ctx, attempt)
+		return nil, err
+	}
+	if locked {
+		// Already recorded; count the attempt without storing the lock again.
+		return nil, a.signinFailed(ctx, nil, attempt)
+	}
+	if u == nil || !match {
+		return nil, a.signinFailed(ctx, u, attempt)
+	}
+
+	// With a second factor pending, the counters are only reset once it is
+	// checked too, so knowing the password buys no extra code guesses.
+	if a.mfaRequired(u) {
+		a.cancelAttempt(ctx, attempt)
+	} else {
+		a.signinSucceeded(ctx, u, attempt)
+	}
+	if u.LockedUntil != nil {
+		a.clearExpiredLock(ctx, u)
+	}
+	if a.hasher.NeedsRehash(u.Password) {
+		a.rehash(ctx, u, password)
+	}
+	if !a.canSignin(u) {
+		return nil, ErrEmailNotVerified
+	}
+	if a.mfaRequired(u) {
+		return nil, a.challengeMFA(u)
+	}
+	return a.issue(ctx, u, uuid.New(), []string{token.AMRPassword})
+}
+
+// canSignin reports whether u may be issued tokens at all.
+func (a *AuthService) canSignin(u *model.User) bool {
+	return a.verified(u) || !a.verification.BlockSignin
+}
+
+// beginAttempt reserves a password or code check for email from the client
+// IP in ctx with the lockout limiter, or returns a *LockedError when it
+// must wait. Every reserved attempt must end in signinFailed,
+// signinSucceeded or cancelAttempt. Without lockout the attempt is nil.
+func (a *AuthService) beginAttempt(ctx context.Context, email string) (*lockout.Attempt, error) {
+	if a.lockout == nil {
+		return nil, nil
+	}
+	attempt, wait, err := a.lockout.Begin(ctx, email, clientIP(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		return nil, &LockedError{RetryAfter: wait}
+	}
+	return attempt, nil
+}
+
+// signinSucceeded settles attempt as a successful check by u, forgetting
+// the failed signins counted against them.
+func (a *AuthService) signinSucceeded(ctx context.Context, u *model.User, attempt *lockout.Attempt) {
+	if attempt == nil {
+		return
+	}
+	if err := a.lockout.Succeed(ctx, attempt); err != nil {
+		log.Printf("reset failed signins for user %s: %v", u.ID, err)
+	}
+}
+
+// cancelAttempt takes back attempt when it ended without a verdict.
+func (a *AuthService) cancelAttempt(ctx context.Context, attempt *lockout.Attempt) {
+	if attempt == nil {
+		return
+	}
+	if err := a.lockout.Cancel(ctx, attempt); err != nil {
+		log.Printf("cancel signin attempt: %v", err)
+	}
+}
+
+// signinFailed settles attempt as a failed signin and, once that locks the
+// account, records the lock on u. It always returns ErrInvalidCreds: the
+// attempt that trips the lock is answered like any other wrong password,
+// and only the next one is refused.
+func (a *AuthService) signinFailed(ctx context.Context, u *model.User, attempt *lockout.Attempt) error {
+	if attempt == nil {
+		return ErrInvalidCreds
+	}
+	until, locked := a.lockout.Fail(attempt)
+	if !locked {
+		return ErrInvalidCreds
+	}
+	metricLockouts.Add(1)
+	if u == nil {
+		return ErrInvalidCreds
+	}
+	updated := *u
+	updated.LockedUntil = &until
+	if err := a.users.Update(ctx, &updated); err != nil && !errors.Is(err, store.ErrConflict) {
+		log.Printf("lock user %s: %v", u.ID, err)
+	}
+	return ErrInvalidCreds
+}
+
+// clearExpiredLock removes a lock that has run out from u. Like rehash, a
+// failure only leaves the stale value for the next signin to clear.
+func (a *AuthService) clearExpiredLock(ctx context.Context, u *model.User) {
+	updated := *u
+	updated.LockedUntil = nil
+	if err := a.users.Update(ctx, &updated); err != nil {
+		if !errors.Is(err, store.ErrConflict) {
+			log.Printf("clear lock on user %s: %v", u.ID, err)
+		}
+		return
+	}
+	*u = updated
+}
+
+// Unlock lifts any lockout on the user with the given ID and forgets the
+// failed signins counted against their email.
+func (a *AuthService) Unlock(ctx context.Context, id uuid.UUID) error {
+	u, err := a.users.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrUserNotFound
+	}
+	if u.LockedUntil != nil {
+		u.LockedUntil = nil
+		if err := a.users.Update(ctx, u); err != nil {
+			return err
+		}
+	}
+	if a.lockout != nil {
+		return a.lockout.Reset(ctx, u.Email)
+	}
+	return nil
+}
+
+// record adds an event about u to the audit trail when one is configured.
+// Failures are logged rather than failing the action being recorded.
+func (a *AuthService) record(ctx context.Context, action string, u *model.User, detail string) {
+	if a.auditor == nil {
+		return
+	}
+	e := audit.Event{Time: time.Now(), Action: action, UserID: u.ID, IP: clientIP(ctx), Detail: detail}
+	if err := a.auditor.Record(ctx, e); err != nil {
+		log.Printf("audit %s for user %s: %v", action, u.ID, err)
+	}
+}
+
+// hash hashes password through the pool when one is configured.
+func (a *AuthService) hash(ctx context.Context, password string) (string, error) {
+	if a.pool == nil {
+		return a.hasher.Hash(password)
+	}
+	h, err := a.pool.Hash(ctx, password)
+	return h, shed(err)
+}
+
+// compare checks password against hashed through the pool when one is
+// configured. An error means no comparison was made.
+func (a *AuthService) compare(ctx context.Context, hashed, password string) (bool, error) {
+	if a.pool == nil {
+		return a.hasher.Compare(hashed, password), nil
+	}
+	ok, err := a.pool.Compare(ctx, hashed, password)
+	return ok, shed(err)
+}
+
+// shed translates the pool turning work away into ErrOverloaded.
+func shed(err error) error {
+	if errors.Is(err, hash.ErrPoolFull) || errors.Is(err, hash.ErrQueueTimeout) {
+		metricHashShed.Add(1)
+		return ErrOverloaded
+	}
+	return err
+}
+
+// dummyPasswordHash returns a hash made under the current policy to compare
+// against when the user does not exist, so that path costs the same as a
+// wrong password.
+func (a *AuthService) dummyPasswordHash() string {
+	a.dummyHashOnce.Do(func() {
+		h, err := a.hasher.Hash("dummy password for unknown users")
+		if err != nil {
+			log.Printf("dummy password hash: %v", err)
+		}
+		a.dummyHash = h
+	})
+	return a.dummyHash
+}
+
+// rehash upgrades u's stored hash to the current policy while the plaintext
+// is at hand. Failure is not fatal to the signin: the old hash still works
+// and the upgrade is retried on the next one.
+func (a *AuthService) rehash(ctx context.Context, u *model.User, password string) {
+	hashPw, err := a.hash(ctx, password)
+	if err != nil {
+		metricRehashFailed.Add(1)
+		log.Printf("rehash user %s: %v", u.ID, err)
+		return
+	}
+	updated := *u
+	updated.Password = hashPw
+	if err := a.users.Update(ctx, &updated); err != nil {
+		// ErrConflict means the user changed concurrently; leave it be.
+		metricRehashFailed.Add(1)
+		if !errors.Is(err, store.ErrConflict) {
+			log.Printf("rehash user %s: %v", u.ID, err)
+		}
+		return
+	}
+	metricRehashed.Add(1)
+}
+
+// AuditPasswordHashes counts stored hashes and how many of them fall short
+// of the current hashing policy, and publishes both as metrics. It walks
+// every user, so run it periodically rather than per request.
+func (a *AuthService) AuditPasswordHashes(ctx context.Context) (total, legacy int, err error) {
+	opts := store.ListOptions{Limit: store.MaxListLimit}
+	for {
+		page, err := a.users.List(ctx, opts)
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, u := range page.Users {
+			total++
+			if a.hasher.NeedsRehash(u.Password) {
+				legacy++
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	metricHashesTotal.Set(int64(total))
+	metricHashesLegacy.Set(int64(legacy))
+	return total, legacy, nil
+}
+
+// Refresh exchanges a refresh token for a new token set. Each refresh token
+// can be exchanged once; presenting one a second time is treated as theft
+// and revokes every token in its family, logging out both the attacker and
+// the legitimate client.
+func (a *AuthService) Refresh(ctx context.Context, refreshToken string) (*Tokens, error) {
+	if a.refresh == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	rt, err := a.refresh.GetByHash(ctx, token.HashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if rt == nil || rt.RevokedAt != nil || now.After(rt.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if rt.UsedAt == nil {
+		err = a.refresh.MarkUsed(ctx, rt.ID, now)
+	} else {
+		err = store.ErrRefreshTokenUsed
+	}
+	if errors.Is(err, store.ErrRefreshTokenUsed) {
+		if err := a.revokeSession(ctx, rt.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := a.users.GetByID(ctx, rt.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	return a.issue(ctx, u, rt.FamilyID, rt.AMR)
+}
+
+// issue generates an access token for u and, when enabled, a refresh token
+// in the given family. Scopes are chosen afresh each time, so refreshing
+// after verifying the email yields the full set, and scopes granted to or
+// taken from the user apply from their next refresh. amr lists the methods
+// the signin that started the family used, and is kept across refreshes.
+func (a *AuthService) issue(ctx context.Context, u *model.User, familyID uuid.UUID, amr []string) (*Tokens, error) {
+	scopes := a.scopes
+	if !a.verified(u) {
+		scopes = a.verification.UnverifiedScopes
+	} else if len(u.Scopes) > 0 {
+		scopes = append(slices.Clone(a.scopes), u.Scopes...)
+	}
+	access, err := a.tokens.Generate(u.ID, u.Email,
+		token.WithSessionID(familyID.String()),
+		token.WithScopes(scopes...),
+		token.WithAMR(amr...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if a.refresh == nil {
+		return &Tokens{AccessToken: access}, nil
+	}
+
+	refresh, err := token.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	err = a.refresh.Create(ctx, &model.RefreshToken{
+		FamilyID:  familyID,
+		UserID:    u.ID,
+		TokenHash: token.HashRefreshToken(refresh),
+		ExpiresAt: time.Now().Add(a.refreshTTL),
+		AMR:       amr,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{AccessToken: access, RefreshToken: refresh}, nil
+}
+
+// Logout revokes the presented access token and the session it belongs to,
+// including the session's refresh token.
+func (a *AuthService) Logout(ctx context.Context, claims *token.Claims) error {
+	if err := a.tokens.Revoke(claims); err != nil {
+		return err
+	}
+	sid, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		// Tokens issued before sessions existed have nothing more to revoke.
+		return nil
+	}
+	return a.revokeSession(ctx, sid, time.Now())
+}
+
+// LogoutAll revokes every session of the user the claims belong to, and
+// every access token issued to them so far.
+func (a *AuthService) LogoutAll(ctx context.Context, claims *token.Claims) error {
+	if err := a.Logout(ctx, claims); err != nil {
+		return err
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	return a.signOutEverywhere(ctx, userID, time.Now())
+}
+
+// signOutEverywhere revokes every session of the user and every access
+// token issued to them so far. Unlike revokeUserSessions it also reaches
+// access tokens of sessions that are not tracked, such as all of them when
+// refresh tokens are disabled.
+func (a *AuthService) signOutEverywhere(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	if err := a.revokeUserSessions(ctx, userID, uuid.Nil, at); err != nil {
+		return err
+	}
+	return a.tokens.RevokeUser(userID.String())
+}
+
+// revokeUserSessions revokes every session of the user other than keep,
+// which may be uuid.Nil, that still has a live refresh token. Without
+// refresh tokens, sessions are not tracked and their access tokens run
+// until they expire; see signOutEverywhere.
+func (a *AuthService) revokeUserSessions(ctx context.Context, userID, keep uuid.UUID, at time.Time) error {
+	if a.refresh == nil {
+		return nil
+	}
+	families, err := a.refresh.RevokeUser(ctx, userID, keep, at)
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if err := a.tokens.RevokeSession(family.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeSession invalidates the refresh token family and any access tokens
+// issued from it.
+func (a *AuthService) revokeSession(ctx context.Context, sid uuid.UUID, at time.Time) error {
+	if a.refresh != nil {
+		if err := a.refresh.RevokeFamily(ctx, sid, at); err != nil {
+			return err
+		}
+	}
+	return a.tokens.RevokeSession(sid.String())
+}
+
+// Profile returns the live user with the given ID.
+func (a *AuthService) Profile(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	u, err := a.users.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	if !a.hasher.Compare(u.Password, password) {
-		return "", ErrInvalidCreds
+	if u == nil {
+		return nil, ErrUserNotFound
 	}
-	return a.tokens.Generate(u.ID, u.Email)
+	return u, nil
 }
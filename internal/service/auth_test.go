@@ -2,10 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/lockout"
+	"github.com/coinbase/identity-service/internal/mailer"
+	"github.com/coinbase/identity-service/internal/model"
 	"github.com/coinbase/identity-service/internal/store/memory"
 	"github.com/coinbase/identity-service/pkg/hash"
 	"github.com/coinbase/identity-service/pkg/token"
@@ -15,7 +23,8 @@ func setupAuthService() *AuthService {
 	userStore := memory.NewUserStore()
 	hasher := hash.Bcrypt{}
 	tokens := token.NewJWTManager("test-secret-key", 15*time.Minute)
-	return NewAuthService(userStore, hasher, tokens)
+	return NewAuthService(userStore, hasher, tokens,
+		WithRefreshTokens(memory.NewRefreshTokenStore(), time.Hour))
 }
 
 func TestAuthService_Signup(t *testing.T) {
@@ -25,19 +34,23 @@ func TestAuthService_Signup(t *testing.T) {
 	email := "test@example.com"
 	password := "password123"
 
-	token, err := auth.Signup(ctx, email, password)
+	tokens, err := auth.Signup(ctx, email, password)
 	if err != nil {
 		t.Fatalf("Signup() failed: %v", err)
 	}
 
-	if token == "" {
+	if tokens.AccessToken == "" {
 		t.Error("Signup() should return a token")
 	}
 
 	// Verify token is valid
-	if len(strings.Split(token, ".")) != 3 {
+	if len(strings.Split(tokens.AccessToken, ".")) != 3 {
 		t.Error("Token should be a valid JWT with 3 parts")
 	}
+
+	if tokens.RefreshToken == "" {
+		t.Error("Signup() should return a refresh token")
+	}
 }
 
 func TestAuthService_SignupDuplicateEmail(t *testing.T) {
@@ -60,6 +73,48 @@ func TestAuthService_SignupDuplicateEmail(t *testing.T) {
 	}
 }
 
+func TestAuthService_SignupConcurrentDuplicateEmail(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	const n = 10
+	passwords := make([]string, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			passwords[i] = "password" + string(rune('a'+i)) + "123"
+			_, errs[i] = auth.Signup(ctx, "race@example.com", passwords[i])
+		}(i)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch err {
+		case nil:
+			if winner != -1 {
+				t.Fatalf("Signup() succeeded twice for the same email (%d and %d)", winner, i)
+			}
+			winner = i
+		case ErrUserExists:
+		default:
+			t.Fatalf("Expected ErrUserExists, got %v", err)
+		}
+	}
+	if winner == -1 {
+		t.Fatal("Expected exactly one Signup() to succeed")
+	}
+
+	// The winning account must not have been clobbered by a losing signup
+	if _, err := auth.Signin(ctx, "race@example.com", passwords[winner]); err != nil {
+		t.Errorf("Signin() with winning password failed: %v", err)
+	}
+}
+
 func TestAuthService_Signin(t *testing.T) {
 	auth := setupAuthService()
 	ctx := context.Background()
@@ -74,24 +129,28 @@ func TestAuthService_Signin(t *testing.T) {
 	}
 
 	// Then signin
-	token, err := auth.Signin(ctx, email, password)
+	tokens, err := auth.Signin(ctx, email, password)
 	if err != nil {
 		t.Fatalf("Signin() failed: %v", err)
 	}
 
-	if token == "" {
+	if tokens.AccessToken == "" {
 		t.Error("Signin() should return a token")
 	}
+
+	if tokens.RefreshToken == "" {
+		t.Error("Signin() should return a refresh token")
+	}
 }
 
 func TestAuthService_SigninInvalidEmail(t *testing.T) {
 	auth := setupAuthService()
 	ctx := context.Background()
 
-	// Try to signin with non-existent email
+	// Unknown emails are indistinguishable from wrong passwords
 	_, err := auth.Signin(ctx, "nonexistent@example.com", "password123")
-	if err != ErrUserNotFound {
-		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	if err != ErrInvalidCreds {
+		t.Errorf("Expected ErrInvalidCreds, got %v", err)
 	}
 }
 
@@ -135,3 +194,750 @@ func TestAuthService_SigninEmptyPassword(t *testing.T) {
 		t.Errorf("Expected ErrInvalidCreds, got %v", err)
 	}
 }
+
+func TestAuthService_Profile(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	tok, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	claims, err := auth.tokens.Verify(tok.AccessToken)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	u, err := auth.Profile(ctx, uuid.MustParse(claims.UserID))
+	if err != nil {
+		t.Fatalf("Profile() failed: %v", err)
+	}
+	if u.Email != "test@example.com" {
+		t.Errorf("Expected email test@example.com, got %s", u.Email)
+	}
+
+	if _, err := auth.Profile(ctx, uuid.New()); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestAuthService_Refresh(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	first, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+
+	second, err := auth.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+	if second.AccessToken == "" || second.RefreshToken == "" {
+		t.Fatal("Refresh() should return a new token set")
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("Refresh() should rotate the refresh token")
+	}
+
+	claims, err := auth.tokens.Verify(second.AccessToken)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if claims.Email != "test@example.com" {
+		t.Errorf("Expected email test@example.com, got %s", claims.Email)
+	}
+
+	// The rotated token keeps working
+	if _, err := auth.Refresh(ctx, second.RefreshToken); err != nil {
+		t.Errorf("Refresh() with rotated token failed: %v", err)
+	}
+}
+
+func TestAuthService_RefreshReuseRevokesFamily(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	first, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	other, err := auth.Signin(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+
+	second, err := auth.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+
+	// Replaying the already-exchanged token is reuse
+	if _, err := auth.Refresh(ctx, first.RefreshToken); err != ErrRefreshTokenReused {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	// ...which revokes the descendant issued to the legitimate client
+	if _, err := auth.Refresh(ctx, second.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected ErrInvalidRefreshToken for revoked family, got %v", err)
+	}
+
+	// Sessions from other signins are unaffected
+	if _, err := auth.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Refresh() for unrelated session failed: %v", err)
+	}
+}
+
+func TestAuthService_RefreshInvalid(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	if _, err := auth.Refresh(ctx, "not-a-refresh-token"); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestAuthService_RefreshExpired(t *testing.T) {
+	tokens := token.NewJWTManager("test-secret-key", 15*time.Minute)
+	auth := NewAuthService(memory.NewUserStore(), hash.Bcrypt{}, tokens,
+		WithRefreshTokens(memory.NewRefreshTokenStore(), -time.Second))
+	ctx := context.Background()
+
+	issued, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+
+	if _, err := auth.Refresh(ctx, issued.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestAuthService_Logout(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	current, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	other, err := auth.Signin(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+
+	claims, err := auth.tokens.Verify(current.AccessToken)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if err := auth.Logout(ctx, claims); err != nil {
+		t.Fatalf("Logout() failed: %v", err)
+	}
+
+	if _, err := auth.tokens.Verify(current.AccessToken); err != token.ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked for logged out token, got %v", err)
+	}
+	if _, err := auth.Refresh(ctx, current.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected ErrInvalidRefreshToken for logged out session, got %v", err)
+	}
+
+	// Other sessions stay signed in
+	if _, err := auth.tokens.Verify(other.AccessToken); err != nil {
+		t.Errorf("Verify() of other session failed: %v", err)
+	}
+	if _, err := auth.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Refresh() of other session failed: %v", err)
+	}
+}
+
+func TestAuthService_LogoutAll(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	first, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	second, err := auth.Signin(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+	bystander, err := auth.Signup(ctx, "other@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+
+	claims, _ := auth.tokens.Verify(first.AccessToken)
+	if err := auth.LogoutAll(ctx, claims); err != nil {
+		t.Fatalf("LogoutAll() failed: %v", err)
+	}
+
+	for name, issued := range map[string]*Tokens{"first": first, "second": second} {
+		if _, err := auth.tokens.Verify(issued.AccessToken); err != token.ErrTokenRevoked {
+			t.Errorf("%s: expected ErrTokenRevoked, got %v", name, err)
+		}
+		if _, err := auth.Refresh(ctx, issued.RefreshToken); err != ErrInvalidRefreshToken {
+			t.Errorf("%s: expected ErrInvalidRefreshToken, got %v", name, err)
+		}
+	}
+
+	if _, err := auth.tokens.Verify(bystander.AccessToken); err != nil {
+		t.Errorf("other users must stay signed in, got %v", err)
+	}
+
+	// Signing in again after LogoutAll starts a fresh, valid session
+	fresh, err := auth.Signin(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+	if _, err := auth.tokens.Verify(fresh.AccessToken); err != nil {
+		t.Errorf("Verify() of new session failed: %v", err)
+	}
+}
+
+func TestAuthService_LogoutAllWithoutRefreshTokens(t *testing.T) {
+	auth := NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute))
+	ctx := context.Background()
+
+	first, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	second, err := auth.Signin(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+
+	claims, _ := auth.tokens.Verify(first.AccessToken)
+	if err := auth.LogoutAll(ctx, claims); err != nil {
+		t.Fatalf("LogoutAll() failed: %v", err)
+	}
+	// Without refresh tokens no session is tracked, yet the other one ends
+	if _, err := auth.tokens.Verify(second.AccessToken); err != token.ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+
+	fresh, err := auth.Signin(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+	if _, err := auth.tokens.Verify(fresh.AccessToken); err != nil {
+		t.Errorf("Verify() of new session failed: %v", err)
+	}
+}
+
+func TestAuthService_RefreshReuseRevokesAccessTokens(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	first, _ := auth.Signup(ctx, "test@example.com", "password123")
+	second, err := auth.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+
+	if _, err := auth.Refresh(ctx, first.RefreshToken); err != ErrRefreshTokenReused {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+
+	if _, err := auth.tokens.Verify(second.AccessToken); err != token.ErrTokenRevoked {
+		t.Errorf("access tokens of a compromised family should be revoked, got %v", err)
+	}
+}
+
+func TestAuthService_GrantsScopes(t *testing.T) {
+	tokens := token.NewJWTManager("test-secret-key", 15*time.Minute)
+	auth := NewAuthService(memory.NewUserStore(), hash.Bcrypt{}, tokens,
+		WithRefreshTokens(memory.NewRefreshTokenStore(), time.Hour),
+		WithScopes(ScopeProfile, "email"))
+	ctx := context.Background()
+
+	first, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	second, err := auth.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+
+	for _, access := range []string{first.AccessToken, second.AccessToken} {
+		if _, err := tokens.Verify(access, token.RequireScopes(ScopeProfile, "email")); err != nil {
+			t.Errorf("Verify() with granted scopes failed: %v", err)
+		}
+	}
+}
+
+func TestAuthService_GrantsUserScopes(t *testing.T) {
+	users := memory.NewUserStore()
+	tokens := token.NewJWTManager("test-secret-key", 15*time.Minute)
+	auth := NewAuthService(users, hash.Bcrypt{Cost: 4}, tokens,
+		WithRefreshTokens(memory.NewRefreshTokenStore(), time.Hour),
+		WithScopes(ScopeProfile))
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "user@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	first, err := auth.Signup(ctx, "ops@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	if _, err := tokens.Verify(first.AccessToken, token.RequireScopes(ScopeAdmin)); err == nil {
+		t.Fatal("admin should not be granted to every user")
+	}
+
+	ops, _ := users.GetByEmail(ctx, "ops@example.com")
+	ops.Scopes = []string{ScopeAdmin}
+	if err := users.Update(ctx, ops); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	second, err := auth.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+	if _, err := tokens.Verify(second.AccessToken, token.RequireScopes(ScopeProfile, ScopeAdmin)); err != nil {
+		t.Errorf("Verify() with the user's own scope failed: %v", err)
+	}
+
+	other, err := auth.Signin(ctx, "user@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+	if _, err := tokens.Verify(other.AccessToken, token.RequireScopes(ScopeAdmin)); err == nil {
+		t.Error("a scope granted to one user leaked to another")
+	}
+}
+
+func TestAuthService_SigninRehashesLegacyHash(t *testing.T) {
+	users := memory.NewUserStore()
+	ctx := context.Background()
+
+	legacy, _ := hash.Bcrypt{Cost: 4}.Hash("password123")
+	u := &model.User{Email: "test@example.com", Password: legacy}
+	if err := users.Create(ctx, u); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	current := hash.Argon2id{Memory: 1024, Time: 1, Parallelism: 1}
+	hasher := hash.Fallback{Primary: current, Legacy: []hash.Verifier{hash.Bcrypt{Cost: 4}}}
+	auth := NewAuthService(users, hasher, token.NewJWTManager("test-secret-key", 15*time.Minute))
+
+	total, legacyCount, err := auth.AuditPasswordHashes(ctx)
+	if err != nil {
+		t.Fatalf("AuditPasswordHashes() failed: %v", err)
+	}
+	if total != 1 || legacyCount != 1 {
+		t.Errorf("Expected 1 of 1 hashes legacy, got %d of %d", legacyCount, total)
+	}
+
+	rehashed := metricRehashed.Value()
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signin() with legacy hash failed: %v", err)
+	}
+	if metricRehashed.Value() != rehashed+1 {
+		t.Error("Expected password_rehash_total to increase")
+	}
+
+	stored, _ := users.GetByID(ctx, u.ID)
+	if current.NeedsRehash(stored.Password) {
+		t.Errorf("Expected hash upgraded to current policy, got %s", stored.Password)
+	}
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signin() with upgraded hash failed: %v", err)
+	}
+
+	if _, legacyCount, _ = auth.AuditPasswordHashes(ctx); legacyCount != 0 {
+		t.Errorf("Expected no legacy hashes after signin, got %d", legacyCount)
+	}
+}
+
+func TestAuthService_SigninWrongPasswordDoesNotRehash(t *testing.T) {
+	users := memory.NewUserStore()
+	ctx := context.Background()
+
+	legacy, _ := hash.Bcrypt{Cost: 4}.Hash("password123")
+	u := &model.User{Email: "test@example.com", Password: legacy}
+	_ = users.Create(ctx, u)
+	auth := NewAuthService(users, hash.Bcrypt{Cost: 5}, token.NewJWTManager("test-secret-key", 15*time.Minute))
+
+	if _, err := auth.Signin(ctx, "test@example.com", "wrongpassword1"); err != ErrInvalidCreds {
+		t.Fatalf("Expected ErrInvalidCreds, got %v", err)
+	}
+	stored, _ := users.GetByID(ctx, u.ID)
+	if stored.Password != legacy {
+		t.Error("Failed signin should leave the stored hash alone")
+	}
+}
+
+func TestAuthService_SigninUpgradesImportedHash(t *testing.T) {
+	users := memory.NewUserStore()
+	ctx := context.Background()
+
+	// PBKDF2-SHA256 of "password123", as exported by an older system
+	imported := "$pbkdf2-sha256$1000$bGVnYWN5LXNhbHQtMDAwMQ$GOzgi0210x3UfZ05aRLYlKB95IPl305r88lVM3aUUm8"
+	u := &model.User{Email: "test@example.com", Password: imported}
+	_ = users.Create(ctx, u)
+	hasher := hash.Fallback{Primary: hash.Bcrypt{Cost: 4}, Legacy: []hash.Verifier{hash.PBKDF2{}}}
+	auth := NewAuthService(users, hasher, token.NewJWTManager("test-secret-key", 15*time.Minute))
+
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signin() with imported hash failed: %v", err)
+	}
+	stored, _ := users.GetByID(ctx, u.ID)
+	if hash.Identify(stored.Password) != "bcrypt" {
+		t.Errorf("Expected imported hash upgraded to bcrypt, got %s", stored.Password)
+	}
+}
+
+func TestAuthService_SigninUpgradesPepperVersion(t *testing.T) {
+	users := memory.NewUserStore()
+	ctx := context.Background()
+
+	v1, _ := hash.NewPeppered(hash.Bcrypt{Cost: 4}, map[int][]byte{1: []byte("pepper-one-0123456789")})
+	old, _ := v1.Hash("password123")
+	u := &model.User{Email: "test@example.com", Password: old}
+	_ = users.Create(ctx, u)
+
+	v2, _ := hash.NewPeppered(hash.Bcrypt{Cost: 4}, map[int][]byte{
+		1: []byte("pepper-one-0123456789"),
+		2: []byte("pepper-two-0123456789"),
+	})
+	auth := NewAuthService(users, v2, token.NewJWTManager("test-secret-key", 15*time.Minute))
+
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signin() under previous pepper failed: %v", err)
+	}
+	stored, _ := users.GetByID(ctx, u.ID)
+	if !strings.HasPrefix(stored.Password, "$pepper$v=2$") {
+		t.Errorf("Expected hash re-peppered with version 2, got %s", stored.Password)
+	}
+}
+
+// countingHasher records how many password comparisons were made.
+type countingHasher struct {
+	hash.Hasher
+	compares atomic.Int32
+}
+
+func (c *countingHasher) Compare(hashed, plain string) bool {
+	c.compares.Add(1)
+	return c.Hasher.Compare(hashed, plain)
+}
+
+func TestAuthService_SigninUnknownUserComparesHash(t *testing.T) {
+	hasher := &countingHasher{Hasher: hash.Bcrypt{Cost: 4}}
+	auth := NewAuthService(memory.NewUserStore(), hasher, token.NewJWTManager("test-secret-key", 15*time.Minute))
+	ctx := context.Background()
+
+	if _, err := auth.Signin(ctx, "nobody@example.com", "password123"); err != ErrInvalidCreds {
+		t.Fatalf("Expected ErrInvalidCreds, got %v", err)
+	}
+	if hasher.compares.Load() != 1 {
+		t.Errorf("Expected a dummy hash comparison for an unknown user, got %d", hasher.compares.Load())
+	}
+}
+
+// recordingMailer collects sent messages.
+type recordingMailer struct {
+	sent chan mailer.Message
+}
+
+func newRecordingMailer() *recordingMailer {
+	return &recordingMailer{sent: make(chan mailer.Message, 10)}
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.sent <- msg
+	return nil
+}
+
+func TestAuthService_EnumerationSafeSignup(t *testing.T) {
+	mail := newRecordingMailer()
+	auth := NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute),
+		WithEnumerationSafeSignup(mail))
+	ctx := context.Background()
+
+	first, err := auth.Signup(ctx, "test@example.com", "password123")
+	if first != nil || err != nil {
+		t.Fatalf("Expected nil tokens and error for a new email, got %v, %v", first, err)
+	}
+	second, err := auth.Signup(ctx, "test@example.com", "otherpassword1")
+	if second != nil || err != nil {
+		t.Fatalf("Expected nil tokens and error for a taken email, got %v, %v", second, err)
+	}
+
+	select {
+	case msg := <-mail.sent:
+		if msg.To != "test@example.com" {
+			t.Errorf("Expected notice to the account owner, got %s", msg.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the existing owner to be notified")
+	}
+	select {
+	case msg := <-mail.sent:
+		t.Errorf("Expected a single notice, also got %+v", msg)
+	default:
+	}
+
+	// The account created by the first signup works with its own password
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Errorf("Signin() failed: %v", err)
+	}
+}
+
+func setupLockoutAuthService(maxFailures int) (*AuthService, *memory.UserStore) {
+	users := memory.NewUserStore()
+	limiter := lockout.NewLimiter(lockout.NewMemoryCounter(),
+		lockout.Policy{MaxFailures: maxFailures, LockoutDuration: time.Minute},
+		lockout.Policy{}, time.Hour)
+	auth := NewAuthService(users, hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute), WithLockout(limiter))
+	return auth, users
+}
+
+func TestAuthService_SigninLockout(t *testing.T) {
+	auth, users := setupLockoutAuthService(3)
+	ctx := WithClientIP(context.Background(), "192.0.2.1")
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := auth.Signin(ctx, "test@example.com", "wrongpassword1"); err != ErrInvalidCreds {
+			t.Fatalf("attempt %d: expected ErrInvalidCreds, got %v", i+1, err)
+		}
+	}
+
+	u, _ := users.GetByEmail(ctx, "test@example.com")
+	if u.LockedUntil == nil || time.Until(*u.LockedUntil) <= 0 {
+		t.Fatalf("Expected the user to be locked, LockedUntil = %v", u.LockedUntil)
+	}
+
+	// Even the right password is refused while locked
+	_, err := auth.Signin(ctx, "test@example.com", "password123")
+	var locked *LockedError
+	if !errors.As(err, &locked) || !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("Expected *LockedError, got %v", err)
+	}
+	if locked.RetryAfter <= 0 || locked.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %v, want within the lockout duration", locked.RetryAfter)
+	}
+
+	if err := auth.Unlock(ctx, u.ID); err != nil {
+		t.Fatalf("Unlock() failed: %v", err)
+	}
+	u, _ = users.GetByEmail(ctx, "test@example.com")
+	if u.LockedUntil != nil {
+		t.Errorf("Expected Unlock to clear LockedUntil, got %v", u.LockedUntil)
+	}
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Errorf("Signin() after Unlock failed: %v", err)
+	}
+}
+
+func TestAuthService_SigninLockoutUnknownEmail(t *testing.T) {
+	auth, _ := setupLockoutAuthService(2)
+	ctx := context.Background()
+
+	// Unknown emails lock exactly like registered ones
+	for i := 0; i < 2; i++ {
+		if _, err := auth.Signin(ctx, "nobody@example.com", "password123"); err != ErrInvalidCreds {
+			t.Fatalf("attempt %d: expected ErrInvalidCreds, got %v", i+1, err)
+		}
+	}
+	if _, err := auth.Signin(ctx, "nobody@example.com", "password123"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Expected ErrTooManyAttempts, got %v", err)
+	}
+}
+
+func TestAuthService_SigninStoredLockLooksLikeUnknownEmail(t *testing.T) {
+	auth, users := setupLockoutAuthService(2)
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	// A lock stored before a restart, with the counters since forgotten
+	u, _ := users.GetByEmail(ctx, "test@example.com")
+	until := time.Now().Add(time.Minute)
+	u.LockedUntil = &until
+	if err := users.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	// Even the right password gets the answers an unknown email would
+	for _, email := range []string{"test@example.com", "nobody@example.com"} {
+		for i := 0; i < 2; i++ {
+			if _, err := auth.Signin(ctx, email, "password123"); err != ErrInvalidCreds {
+				t.Fatalf("%s attempt %d: expected ErrInvalidCreds, got %v", email, i+1, err)
+			}
+		}
+		if _, err := auth.Signin(ctx, email, "password123"); !errors.Is(err, ErrTooManyAttempts) {
+			t.Errorf("%s: expected ErrTooManyAttempts, got %v", email, err)
+		}
+	}
+}
+
+func TestAuthService_SigninLockoutConcurrent(t *testing.T) {
+	const maxFailures = 5
+	hasher := &countingHasher{Hasher: hash.Bcrypt{Cost: 4}}
+	limiter := lockout.NewLimiter(lockout.NewMemoryCounter(),
+		lockout.Policy{MaxFailures: maxFailures, LockoutDuration: time.Minute},
+		lockout.Policy{}, time.Hour)
+	auth := NewAuthService(memory.NewUserStore(), hasher,
+		token.NewJWTManager("test-secret-key", 15*time.Minute), WithLockout(limiter))
+	ctx := context.Background()
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+
+	// Guesses sent all at once are counted before any of them is checked
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = auth.Signin(ctx, "test@example.com", "wrongpassword1")
+		}()
+	}
+	wg.Wait()
+	if got := hasher.compares.Load(); got > maxFailures {
+		t.Errorf("%d passwords were compared, want at most %d", got, maxFailures)
+	}
+}
+
+// cancelingHasher cancels a request's context while its password is being
+// compared, as when the client disconnects.
+type cancelingHasher struct {
+	hash.Hasher
+	cancel context.CancelFunc
+}
+
+func (c *cancelingHasher) Compare(hashed, plain string) bool {
+	c.cancel()
+	return c.Hasher.Compare(hashed, plain)
+}
+
+func TestAuthService_SigninCanceledIsNotCounted(t *testing.T) {
+	hasher := &cancelingHasher{Hasher: hash.Bcrypt{Cost: 4}, cancel: func() {}}
+	limiter := lockout.NewLimiter(lockout.NewMemoryCounter(),
+		lockout.Policy{MaxFailures: 2, LockoutDuration: time.Minute},
+		lockout.Policy{}, time.Hour)
+	auth := NewAuthService(memory.NewUserStore(), hasher,
+		token.NewJWTManager("test-secret-key", 15*time.Minute), WithLockout(limiter))
+	if _, err := auth.Signup(context.Background(), "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+
+	// Successful signins whose clients went away mid-check leave nothing
+	// counted against the account
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		hasher.cancel = cancel
+		_, _ = auth.Signin(ctx, "test@example.com", "password123")
+	}
+	hasher.cancel = func() {}
+	if _, err := auth.Signin(context.Background(), "test@example.com", "password123"); err != nil {
+		t.Errorf("Signin() after canceled requests failed: %v", err)
+	}
+}
+
+func TestAuthService_SigninSuccessResetsFailures(t *testing.T) {
+	auth, _ := setupLockoutAuthService(2)
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	for round := 0; round < 2; round++ {
+		if _, err := auth.Signin(ctx, "test@example.com", "wrongpassword1"); err != ErrInvalidCreds {
+			t.Fatalf("Expected ErrInvalidCreds, got %v", err)
+		}
+		if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+			t.Fatalf("round %d: Signin() failed: %v", round, err)
+		}
+	}
+}
+
+func TestAuthService_SigninClearsExpiredLock(t *testing.T) {
+	users := memory.NewUserStore()
+	auth := NewAuthService(users, hash.Bcrypt{Cost: 4}, token.NewJWTManager("test-secret-key", 15*time.Minute))
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	u, _ := users.GetByEmail(ctx, "test@example.com")
+	expired := time.Now().Add(-time.Minute)
+	u.LockedUntil = &expired
+	if err := users.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+	u, _ = users.GetByEmail(ctx, "test@example.com")
+	if u.LockedUntil != nil {
+		t.Errorf("Expected the expired lock to be cleared, got %v", u.LockedUntil)
+	}
+}
+
+func TestAuthService_UnlockUnknownUser(t *testing.T) {
+	auth, _ := setupLockoutAuthService(3)
+	if err := auth.Unlock(context.Background(), uuid.New()); err != ErrUserNotFound {
+		t.Errorf("Expected ErrUserNotFound, got %v", err)
+	}
+}
+
+// gatedHasher blocks Hash until the gate is opened.
+type gatedHasher struct {
+	hash.Hasher
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (g *gatedHasher) Hash(pw string) (string, error) {
+	g.entered <- struct{}{}
+	<-g.gate
+	return g.Hasher.Hash(pw)
+}
+
+func TestAuthService_ShedsWhenHashingSaturated(t *testing.T) {
+	hasher := &gatedHasher{Hasher: hash.Bcrypt{Cost: 4}, entered: make(chan struct{}, 1), gate: make(chan struct{})}
+	users := memory.NewUserStore()
+	auth := NewAuthService(users, hasher,
+		token.NewJWTManager("test-secret-key", 15*time.Minute), WithHashPool(1, 0, 0))
+	ctx := context.Background()
+
+	existing, _ := hash.Bcrypt{Cost: 4}.Hash("password123")
+	if err := users.Create(ctx, &model.User{Email: "existing@example.com", Password: existing}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := auth.Signup(ctx, "first@example.com", "password123")
+		done <- err
+	}()
+	<-hasher.entered
+
+	if _, err := auth.Signup(ctx, "second@example.com", "password123"); err != ErrOverloaded {
+		t.Errorf("Signup() expected ErrOverloaded, got %v", err)
+	}
+	if _, err := auth.Signin(ctx, "existing@example.com", "password123"); err != ErrOverloaded {
+		t.Errorf("Signin() expected ErrOverloaded, got %v", err)
+	}
+
+	close(hasher.gate)
+	if err := <-done; err != nil {
+		t.Fatalf("admitted Signup() failed: %v", err)
+	}
+	if _, err := auth.Signin(ctx, "existing@example.com", "password123"); err != nil {
+		t.Errorf("Signin() after load drained failed: %v", err)
+	}
+}
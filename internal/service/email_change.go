@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/mailer"
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+var (
+	ErrEmailChangeDisabled     = errors.New("email change is disabled")
+	ErrInvalidEmailChangeToken = errors.New("invalid email change token")
+	ErrSameEmail               = errors.New("new email matches the current one")
+	ErrEmailTaken              = errors.New("email is already in use")
+)
+
+// Purpose claims of the tokens mailed for an email change.
+const (
+	purposeConfirmEmailChange = "confirm-email-change"
+	purposeCancelEmailChange  = "cancel-email-change"
+)
+
+// EmailChange configures WithEmailChange.
+type EmailChange struct {
+	Mailer mailer.Mailer
+	Signer *token.ActionSigner
+	// TTL is how long the confirmation and cancel links stay valid.
+	TTL time.Duration
+	// ConfirmURL and CancelURL are the pages that submit the token to
+	// /email-change/confirm and /email-change/cancel; the token is appended.
+	ConfirmURL string
+	CancelURL  string
+}
+
+// WithEmailChange lets users move their account to a new email address once
+// they confirm it from the new inbox. The old address is told about the
+// request and can cancel it.
+func WithEmailChange(c EmailChange) Option {
+	return func(a *AuthService) { a.emailChange = &c }
+}
+
+// RequestEmailChange records email as the pending address of the user the
+// claims belong to, after checking their password, and mails a confirmation
+// link to it and a notice with a cancel link to the current address. When
+// email belongs to another account, that account is told instead and nil is
+// returned all the same, so the endpoint cannot be used to find out which
+// emails are registered. A later request replaces the pending address.
+func (a *AuthService) RequestEmailChange(ctx context.Context, claims *token.Claims, password, email string) error {
+	if a.emailChange == nil {
+		return ErrEmailChangeDisabled
+	}
+	u, err := a.claimsUser(ctx, claims)
+	if err != nil {
+		return err
+	}
+	if err := a.checkPassword(ctx, u, password); err != nil {
+		return err
+	}
+
+	existing, err := a.users.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID == u.ID {
+		return ErrSameEmail
+	}
+	if existing != nil {
+		a.sendAsync(ctx, a.emailChange.Mailer, mailer.Message{
+			To:      email,
+			Subject: "Email change attempt for your account",
+			Body: "Someone tried to move another account to this email address, " +
+				"which is already registered. No changes were made.",
+		})
+		return nil
+	}
+
+	u.PendingEmail = email
+	if err := a.users.Update(ctx, u); err != nil {
+		return err
+	}
+	confirm, err := a.emailChange.Signer.Sign(purposeConfirmEmailChange, u.ID, email, a.emailChange.TTL)
+	if err != nil {
+		return err
+	}
+	cancel, err := a.emailChange.Signer.Sign(purposeCancelEmailChange, u.ID, email, a.emailChange.TTL)
+	if err != nil {
+		return err
+	}
+	expires := "\n\nThe link expires in " + a.emailChange.TTL.String() + "."
+	a.sendAsync(ctx, a.emailChange.Mailer, mailer.Message{
+		To:      email,
+		Subject: "Confirm your new email address",
+		Body: "Confirm that you want to use this address for your account by " +
+			"opening the link below:\n\n" + a.emailChange.ConfirmURL + confirm + expires +
+			" If you did not ask for it, you can ignore this message.",
+	})
+	a.sendAsync(ctx, a.emailChange.Mailer, mailer.Message{
+		To:      u.Email,
+		Subject: "Email change requested for your account",
+		Body: "Someone asked to change the email address of your account to " + email +
+			". It changes once the new address is confirmed.\n\nIf this was not you, " +
+			"cancel the change with the link below and reset your password:\n\n" +
+			a.emailChange.CancelURL + cancel + expires,
+	})
+	return nil
+}
+
+// ConfirmEmailChange switches the account a confirmation token was issued
+// for to its pending address, which thereby counts as verified. A token only
+// works while that address is still pending, so it works once and not after
+// the change was cancelled or superseded. The old address is told, and
+// reset links mailed to it stop working.
+func (a *AuthService) ConfirmEmailChange(ctx context.Context, tok string) error {
+	if a.emailChange == nil {
+		return ErrEmailChangeDisabled
+	}
+	claims, err := a.emailChange.Signer.Verify(tok, purposeConfirmEmailChange)
+	if err != nil {
+		return ErrInvalidEmailChangeToken
+	}
+
+	now := time.Now()
+	var old string
+	u, err := a.updatePendingEmail(ctx, claims, func(u *model.User) {
+		old = u.Email
+		u.Email = u.PendingEmail
+		u.PendingEmail = ""
+		u.EmailVerifiedAt = &now
+	})
+	if errors.Is(err, store.ErrDuplicateEmail) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+	if a.reset != nil {
+		if err := a.reset.Store.MarkUserUsed(ctx, u.ID, now); err != nil {
+			return err
+		}
+	}
+	a.sendAsync(ctx, a.emailChange.Mailer, mailer.Message{
+		To:      old,
+		Subject: "Your email address was changed",
+		Body: "The email address of your account was changed to " + u.Email +
+			". This address will no longer receive mail about the account.",
+	})
+	return nil
+}
+
+// CancelEmailChange drops the pending address a cancel token was issued for.
+func (a *AuthService) CancelEmailChange(ctx context.Context, tok string) error {
+	if a.emailChange == nil {
+		return ErrEmailChangeDisabled
+	}
+	claims, err := a.emailChange.Signer.Verify(tok, purposeCancelEmailChange)
+	if err != nil {
+		return ErrInvalidEmailChangeToken
+	}
+	_, err = a.updatePendingEmail(ctx, claims, func(u *model.User) { u.PendingEmail = "" })
+	return err
+}
+
+// updatePendingEmail applies edit to the user the claims name, provided the
+// address in the claims is still pending for them. Concurrent updates to the
+// user are retried.
+func (a *AuthService) updatePendingEmail(ctx context.Context, claims *token.ActionClaims, edit func(*model.User)) (*model.User, error) {
+	id, _ := claims.UserID()
+	for attempt := 0; ; attempt++ {
+		u, err := a.users.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil || u.PendingEmail == "" || u.PendingEmail != claims.Email {
+			return nil, ErrInvalidEmailChangeToken
+		}
+		edit(u)
+		err = a.users.Update(ctx, u)
+		if errors.Is(err, store.ErrConflict) && attempt < 2 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+}
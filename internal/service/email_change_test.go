@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/mailer"
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+const (
+	testConfirmURL = "https://app.example.com/email/confirm?token="
+	testCancelURL  = "https://app.example.com/email/cancel?token="
+)
+
+func setupEmailChangeAuthService() (*AuthService, *memory.UserStore, *recordingMailer) {
+	users := memory.NewUserStore()
+	mail := newRecordingMailer()
+	auth := NewAuthService(users, hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute),
+		WithEmailChange(EmailChange{
+			Mailer:     mail,
+			Signer:     token.NewActionSigner([]byte("action-secret-key-for-tests-only")),
+			TTL:        time.Hour,
+			ConfirmURL: testConfirmURL,
+			CancelURL:  testCancelURL,
+		}))
+	return auth, users, mail
+}
+
+// collectMail waits for n mails, which are sent concurrently, and returns
+// them by recipient.
+func collectMail(t *testing.T, mail *recordingMailer, n int) map[string]mailer.Message {
+	t.Helper()
+	got := make(map[string]mailer.Message)
+	for i := 0; i < n; i++ {
+		select {
+		case msg := <-mail.sent:
+			got[msg.To] = msg
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %d mails, got %d", n, i)
+		}
+	}
+	return got
+}
+
+// linkToken extracts the token of the link starting with linkURL from msg.
+func linkToken(t *testing.T, msg mailer.Message, linkURL string) string {
+	t.Helper()
+	_, rest, ok := strings.Cut(msg.Body, linkURL)
+	if !ok {
+		t.Fatalf("Expected a link to %s in %q", linkURL, msg.Body)
+	}
+	tok, _, _ := strings.Cut(rest, "\n")
+	return tok
+}
+
+func TestAuthService_EmailChange(t *testing.T) {
+	auth, users, mail := setupEmailChangeAuthService()
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "old@example.com")
+
+	if err := auth.RequestEmailChange(ctx, claims, "password123", "new@example.com"); err != nil {
+		t.Fatalf("RequestEmailChange() failed: %v", err)
+	}
+	sent := collectMail(t, mail, 2)
+	confirm := linkToken(t, sent["new@example.com"], testConfirmURL)
+	linkToken(t, sent["old@example.com"], testCancelURL)
+
+	// Nothing changes before confirmation
+	u, _ := users.GetByEmail(ctx, "old@example.com")
+	if u == nil || u.PendingEmail != "new@example.com" {
+		t.Fatalf("Expected new@example.com pending on the old address, got %+v", u)
+	}
+
+	if err := auth.ConfirmEmailChange(ctx, confirm); err != nil {
+		t.Fatalf("ConfirmEmailChange() failed: %v", err)
+	}
+	expectMail(t, mail, "old@example.com")
+	if old, _ := users.GetByEmail(ctx, "old@example.com"); old != nil {
+		t.Error("Expected the old address to be released")
+	}
+	u, _ = users.GetByEmail(ctx, "new@example.com")
+	if u == nil || u.PendingEmail != "" || u.EmailVerifiedAt == nil {
+		t.Fatalf("Expected a verified account at new@example.com, got %+v", u)
+	}
+	if _, err := auth.Signin(ctx, "new@example.com", "password123"); err != nil {
+		t.Errorf("Signin() with the new address failed: %v", err)
+	}
+
+	if err := auth.ConfirmEmailChange(ctx, confirm); err != ErrInvalidEmailChangeToken {
+		t.Errorf("Second ConfirmEmailChange() expected ErrInvalidEmailChangeToken, got %v", err)
+	}
+}
+
+func TestAuthService_EmailChangeCancel(t *testing.T) {
+	auth, users, mail := setupEmailChangeAuthService()
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "old@example.com")
+
+	_ = auth.RequestEmailChange(ctx, claims, "password123", "new@example.com")
+	sent := collectMail(t, mail, 2)
+	confirm := linkToken(t, sent["new@example.com"], testConfirmURL)
+	cancel := linkToken(t, sent["old@example.com"], testCancelURL)
+
+	// Tokens are not interchangeable
+	if err := auth.CancelEmailChange(ctx, confirm); err != ErrInvalidEmailChangeToken {
+		t.Errorf("CancelEmailChange() with a confirm token expected ErrInvalidEmailChangeToken, got %v", err)
+	}
+	if err := auth.CancelEmailChange(ctx, cancel); err != nil {
+		t.Fatalf("CancelEmailChange() failed: %v", err)
+	}
+	if err := auth.ConfirmEmailChange(ctx, confirm); err != ErrInvalidEmailChangeToken {
+		t.Errorf("ConfirmEmailChange() after cancel expected ErrInvalidEmailChangeToken, got %v", err)
+	}
+	if u, _ := users.GetByEmail(ctx, "old@example.com"); u == nil || u.PendingEmail != "" {
+		t.Errorf("Expected the account to stay at old@example.com, got %+v", u)
+	}
+}
+
+func TestAuthService_EmailChangeSuperseded(t *testing.T) {
+	auth, _, mail := setupEmailChangeAuthService()
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "old@example.com")
+
+	_ = auth.RequestEmailChange(ctx, claims, "password123", "first@example.com")
+	first := linkToken(t, collectMail(t, mail, 2)["first@example.com"], testConfirmURL)
+	_ = auth.RequestEmailChange(ctx, claims, "password123", "second@example.com")
+	collectMail(t, mail, 2)
+
+	if err := auth.ConfirmEmailChange(ctx, first); err != ErrInvalidEmailChangeToken {
+		t.Errorf("Expected the superseded token to be refused, got %v", err)
+	}
+}
+
+func TestAuthService_EmailChangeTakenAddress(t *testing.T) {
+	auth, users, mail := setupEmailChangeAuthService()
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "old@example.com")
+	signupClaims(t, auth, "taken@example.com")
+
+	// The requester cannot tell a taken address from a free one
+	if err := auth.RequestEmailChange(ctx, claims, "password123", "taken@example.com"); err != nil {
+		t.Fatalf("RequestEmailChange() failed: %v", err)
+	}
+	msg := collectMail(t, mail, 1)["taken@example.com"]
+	if strings.Contains(msg.Body, testConfirmURL) {
+		t.Error("The owner of a taken address must not get a confirmation link")
+	}
+	if u, _ := users.GetByEmail(ctx, "old@example.com"); u.PendingEmail != "" {
+		t.Errorf("Expected no pending email, got %q", u.PendingEmail)
+	}
+
+	if err := auth.RequestEmailChange(ctx, claims, "password123", "OLD@example.com"); err != ErrSameEmail {
+		t.Errorf("Expected ErrSameEmail, got %v", err)
+	}
+}
+
+func TestAuthService_EmailChangeTakenBeforeConfirm(t *testing.T) {
+	auth, _, mail := setupEmailChangeAuthService()
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "old@example.com")
+
+	_ = auth.RequestEmailChange(ctx, claims, "password123", "new@example.com")
+	confirm := linkToken(t, collectMail(t, mail, 2)["new@example.com"], testConfirmURL)
+	signupClaims(t, auth, "new@example.com")
+
+	if err := auth.ConfirmEmailChange(ctx, confirm); err != ErrEmailTaken {
+		t.Errorf("Expected ErrEmailTaken, got %v", err)
+	}
+}
+
+func TestAuthService_EmailChangeWrongPassword(t *testing.T) {
+	auth, _, _ := setupEmailChangeAuthService()
+	_, claims := signupClaims(t, auth, "old@example.com")
+
+	err := auth.RequestEmailChange(context.Background(), claims, "wrongpassword1", "new@example.com")
+	if !errors.Is(err, ErrInvalidCreds) {
+		t.Errorf("Expected ErrInvalidCreds, got %v", err)
+	}
+}
+
+func TestAuthService_EmailChangeDisabled(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	if err := auth.RequestEmailChange(ctx, &token.Claims{}, "password123", "new@example.com"); err != ErrEmailChangeDisabled {
+		t.Errorf("RequestEmailChange() expected ErrEmailChangeDisabled, got %v", err)
+	}
+	if err := auth.ConfirmEmailChange(ctx, "token"); err != ErrEmailChangeDisabled {
+		t.Errorf("ConfirmEmailChange() expected ErrEmailChangeDisabled, got %v", err)
+	}
+	if err := auth.CancelEmailChange(ctx, "token"); err != ErrEmailChangeDisabled {
+		t.Errorf("CancelEmailChange() expected ErrEmailChangeDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package service
+
+import "expvar"
+
+// Password hash metrics, published through expvar.
+var (
+	metricRehashed     = expvar.NewInt("password_rehash_total")
+	metricRehashFailed = expvar.NewInt("password_rehash_failures_total")
+	metricHashesTotal  = expvar.NewInt("password_hashes")
+	metricHashesLegacy = expvar.NewInt("password_hashes_legacy")
+	metricHashShed     = expvar.NewInt("password_hash_shed_total")
+)
+
+// Signin throttling metrics.
+var metricLockouts = expvar.NewInt("signin_lockouts_total")
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/pkg/seal"
+	"github.com/coinbase/identity-service/pkg/token"
+	"github.com/coinbase/identity-service/pkg/totp"
+)
+
+var (
+	ErrMFADisabled       = errors.New("multi-factor authentication is disabled")
+	ErrMFARequired       = errors.New("multi-factor authentication required")
+	ErrMFAAlreadyEnabled = errors.New("totp is already enabled")
+	ErrTOTPNotEnrolled   = errors.New("totp enrollment not started")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrInvalidMFAToken   = errors.New("invalid mfa token")
+)
+
+// purposeMFAChallenge is the purpose claim of the tokens handed out by
+// Signin when a second factor is due.
+const purposeMFAChallenge = "mfa-challenge"
+
+// totpSkew is how many 30-second steps either side of the current one a
+// code is accepted from.
+const totpSkew = 1
+
+// MFA configures WithMFA.
+type MFA struct {
+	// Box encrypts TOTP secrets at rest.
+	Box    *seal.Box
+	Signer *token.ActionSigner
+	// Issuer names the service in authenticator apps.
+	Issuer string
+	// ChallengeTTL is how long the second step of a signin may take.
+	ChallengeTTL time.Duration
+	// RecoveryKey keys the HMACs that recovery codes are stored as.
+	RecoveryKey []byte
+}
+
+// WithMFA lets users protect their accounts with a TOTP authenticator app
+// on top of their password.
+func WithMFA(m MFA) Option {
+	return func(a *AuthService) { a.mfa = &m }
+}
+
+// MFARequiredError answers a signin with the right password for a user
+// with a second factor. Token is the challenge to pass to SigninMFA along
+// with a code. It matches ErrMFARequired.
+type MFARequiredError struct {
+	Token string
+}
+
+func (e *MFARequiredError) Error() string { return ErrMFARequired.Error() }
+func (e *MFARequiredError) Unwrap() error { return ErrMFARequired }
+
+// TOTPEnrollment is what a user needs to set up their authenticator app.
+type TOTPEnrollment struct {
+	// Secret is the shared secret in base32, for typing in by hand.
+	Secret string
+	// URI is the otpauth:// provisioning URI, usually shown as a QR code.
+	URI string
+	// RecoveryCodes each stand in for a TOTP code once, should the user
+	// lose their authenticator app. Only their HMACs are kept.
+	RecoveryCodes []string
+}
+
+// EnrollTOTP starts TOTP enrollment for the user the claims belong to,
+// after checking their password, and returns the secret to load into an
+// authenticator app, along with a fresh set of recovery codes. Signin is
+// unaffected until ConfirmTOTP proves the app works. Enrolling again before
+// confirming replaces the secret and the codes.
+func (a *AuthService) EnrollTOTP(ctx context.Context, claims *token.Claims, password string) (*TOTPEnrollment, error) {
+	if a.mfa == nil {
+		return nil, ErrMFADisabled
+	}
+	u, err := a.claimsUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkPassword(ctx, u, password); err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	// Binding the sealed secret to the user ID keeps it from working if
+	// copied onto another row.
+	sealed, err := a.mfa.Box.Seal(secret, u.ID[:])
+	if err != nil {
+		return nil, err
+	}
+	codes, macs, err := a.newRecoveryCodes(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	u.TOTPSecret = sealed
+	u.TOTPLastStep = 0
+	u.RecoveryCodes = macs
+	if err := a.users.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{
+		Secret:        totp.Encoding.EncodeToString(secret),
+		URI:           totp.URI(a.mfa.Issuer, u.Email, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// ConfirmTOTP completes TOTP enrollment with a code from the authenticator
+// app. From then on signin asks for a code after the password.
+func (a *AuthService) ConfirmTOTP(ctx context.Context, claims *token.Claims, code string) error {
+	if a.mfa == nil {
+		return ErrMFADisabled
+	}
+	id, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	_, err = a.acceptTOTP(ctx, id, code, func(u *model.User) error {
+		if u.TOTPEnabledAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+		if u.TOTPSecret == "" {
+			return ErrTOTPNotEnrolled
+		}
+		now := time.Now()
+		u.TOTPEnabledAt = &now
+		return nil
+	})
+	return err
+}
+
+// SigninMFA completes a signin that Signin answered with an
+// *MFARequiredError, given the challenge token and a code from the user's
+// authenticator app. Wrong codes count as failed signins, so lockout caps
+// how many can be tried.
+func (a *AuthService) SigninMFA(ctx context.Context, challenge, code string) (*Tokens, error) {
+	accept := func(id uuid.UUID, check func(*model.User) error) (*model.User, error) {
+		return a.acceptTOTP(ctx, id, code, check)
+	}
+	return a.completeMFA(ctx, challenge, accept, []string{token.AMRPassword, token.AMROTP, token.AMRMulti})
+}
+
+// secondFactor checks a second factor of the user with the given ID, once
+// check has passed, and spends it. It returns ErrInvalidMFACode, along with
+// the user, when the factor is wrong.
+type secondFactor func(id uuid.UUID, check func(*model.User) error) (*model.User, error)
+
+// completeMFA finishes a signin given its challenge token and the second
+// factor accept checks, issuing tokens carrying amr.
+func (a *AuthService) completeMFA(ctx context.Context, challenge string, accept secondFactor, amr []string) (*Tokens, error) {
+	if a.mfa == nil {
+		return nil, ErrMFADisabled
+	}
+	claims, err := a.mfa.Signer.Verify(challenge, purposeMFAChallenge)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	id, _ := claims.UserID()
+
+	attempt, err := a.beginAttempt(ctx, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	u, err := accept(id, func(u *model.User) error {
+		if u.Email != claims.Email || u.TOTPEnabledAt == nil {
+			return ErrInvalidMFAToken
+		}
+		if u.LockedUntil != nil {
+			if wait := time.Until(*u.LockedUntil); wait > 0 {
+				return &LockedError{RetryAfter: wait}
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidMFACode) {
+		_ = a.signinFailed(ctx, u, attempt)
+		return nil, ErrInvalidMFACode
+	}
+	if err != nil {
+		a.cancelAttempt(ctx, attempt)
+		return nil, err
+	}
+	a.signinSucceeded(ctx, u, attempt)
+	return a.issue(ctx, u, uuid.New(), amr)
+}
+
+// acceptTOTP checks code against the TOTP secret of the user with the
+// given ID, once check, which may edit the user, has passed, and records
+// the step it matched. A code from that step or an earlier one is refused
+// with ErrInvalidMFACode, so each code works once even under concurrent
+// requests: the store lets only one of them record the step. The user is
+// returned alongside ErrInvalidMFACode too, for counting the failure.
+func (a *AuthService) acceptTOTP(ctx context.Context, id uuid.UUID, code string, check func(*model.User) error) (*model.User, error) {
+	for attempt := 0; ; attempt++ {
+		u, err := a.users.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			return nil, ErrUserNotFound
+		}
+		if err := check(u); err != nil {
+			return nil, err
+		}
+		secret, err := a.mfa.Box.Open(u.TOTPSecret, u.ID[:])
+		if err != nil {
+			return nil, err
+		}
+		step, ok := totp.Validate(secret, code, time.Now(), totpSkew)
+		if !ok || step <= u.TOTPLastStep {
+			return u, ErrInvalidMFACode
+		}
+		u.TOTPLastStep = step
+		err = a.users.Update(ctx, u)
+		if errors.Is(err, store.ErrConflict) && attempt < 2 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+}
+
+// mfaRequired reports whether u must pass a second factor to sign in.
+// Users keep their enrollment while MFA is disabled, but are not asked for
+// codes, as their secrets cannot be read.
+func (a *AuthService) mfaRequired(u *model.User) bool {
+	return a.mfa != nil && u.TOTPEnabledAt != nil
+}
+
+// challengeMFA returns the error that asks the client for u's second
+// factor.
+func (a *AuthService) challengeMFA(u *model.User) error {
+	tok, err := a.mfa.Signer.Sign(purposeMFAChallenge, u.ID, u.Email, a.mfa.ChallengeTTL)
+	if err != nil {
+		return err
+	}
+	return &MFARequiredError{Token: tok}
+}
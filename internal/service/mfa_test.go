@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/lockout"
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/seal"
+	"github.com/coinbase/identity-service/pkg/token"
+	"github.com/coinbase/identity-service/pkg/totp"
+)
+
+func setupMFAAuthService(t *testing.T, opts ...Option) *AuthService {
+	t.Helper()
+	box, err := seal.New([]byte("mfa-encryption-key-for-tests-only"))
+	if err != nil {
+		t.Fatalf("seal.New() failed: %v", err)
+	}
+	opts = append([]Option{
+		WithRefreshTokens(memory.NewRefreshTokenStore(), time.Hour),
+		WithMFA(MFA{
+			Box:          box,
+			Signer:       token.NewActionSigner([]byte("action-secret-key-for-tests-only")),
+			Issuer:       "Identity Service",
+			ChallengeTTL: 5 * time.Minute,
+			RecoveryKey:  []byte("mfa-encryption-key-for-tests-only"),
+		}),
+	}, opts...)
+	return NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute), opts...)
+}
+
+// enrollTOTP signs up email with TOTP enabled and returns its claims,
+// secret and recovery codes. The code for the current step is spent on
+// confirming.
+func enrollTOTP(t *testing.T, auth *AuthService, email string) (*token.Claims, []byte, []string) {
+	t.Helper()
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, email)
+	enrollment, err := auth.EnrollTOTP(ctx, claims, "password123")
+	if err != nil {
+		t.Fatalf("EnrollTOTP() failed: %v", err)
+	}
+	secret, err := totp.Encoding.DecodeString(enrollment.Secret)
+	if err != nil {
+		t.Fatalf("Secret is not base32: %v", err)
+	}
+	if err := auth.ConfirmTOTP(ctx, claims, totp.Code(secret, totp.Counter(time.Now()))); err != nil {
+		t.Fatalf("ConfirmTOTP() failed: %v", err)
+	}
+	return claims, secret, enrollment.RecoveryCodes
+}
+
+// mfaChallenge signs in email and returns the MFA challenge token.
+func mfaChallenge(t *testing.T, auth *AuthService, email string) string {
+	t.Helper()
+	_, err := auth.Signin(context.Background(), email, "password123")
+	var required *MFARequiredError
+	if !errors.As(err, &required) {
+		t.Fatalf("Expected an *MFARequiredError, got %v", err)
+	}
+	return required.Token
+}
+
+func TestAuthService_EnrollTOTP(t *testing.T) {
+	auth := setupMFAAuthService(t)
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "test@example.com")
+
+	if _, err := auth.EnrollTOTP(ctx, claims, "wrongpassword1"); err != ErrInvalidCreds {
+		t.Errorf("Expected ErrInvalidCreds, got %v", err)
+	}
+	if err := auth.ConfirmTOTP(ctx, claims, "123456"); err != ErrTOTPNotEnrolled {
+		t.Errorf("ConfirmTOTP() before enrolling expected ErrTOTPNotEnrolled, got %v", err)
+	}
+
+	enrollment, err := auth.EnrollTOTP(ctx, claims, "password123")
+	if err != nil {
+		t.Fatalf("EnrollTOTP() failed: %v", err)
+	}
+	secret, _ := totp.Encoding.DecodeString(enrollment.Secret)
+	if enrollment.URI != totp.URI("Identity Service", "test@example.com", secret) {
+		t.Errorf("Unexpected provisioning URI %q", enrollment.URI)
+	}
+	u := mustUser(t, auth, "test@example.com")
+	if u.TOTPSecret == "" || u.TOTPSecret == enrollment.Secret {
+		t.Errorf("Expected the secret to be stored encrypted, got %q", u.TOTPSecret)
+	}
+	if len(enrollment.RecoveryCodes) != recoveryCodeCount || len(u.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("Expected %d recovery codes, got %d stored as %d", recoveryCodeCount,
+			len(enrollment.RecoveryCodes), len(u.RecoveryCodes))
+	}
+	for i, code := range enrollment.RecoveryCodes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("Unexpected recovery code format %q", code)
+		}
+		if slices.Contains(u.RecoveryCodes, code) || u.RecoveryCodes[i] != auth.recoveryCodeMAC(u.ID, normalizeRecoveryCode(code)) {
+			t.Errorf("Expected recovery code %d to be stored as a MAC", i)
+		}
+	}
+
+	// Unconfirmed enrollment does not change signin
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signin() before confirming failed: %v", err)
+	}
+
+	if err := auth.ConfirmTOTP(ctx, claims, "000000"); err != ErrInvalidMFACode {
+		t.Errorf("Expected ErrInvalidMFACode, got %v", err)
+	}
+	if err := auth.ConfirmTOTP(ctx, claims, totp.Code(secret, totp.Counter(time.Now()))); err != nil {
+		t.Fatalf("ConfirmTOTP() failed: %v", err)
+	}
+	if _, err := auth.EnrollTOTP(ctx, claims, "password123"); err != ErrMFAAlreadyEnabled {
+		t.Errorf("Expected ErrMFAAlreadyEnabled, got %v", err)
+	}
+}
+
+func TestAuthService_SigninMFA(t *testing.T) {
+	auth := setupMFAAuthService(t)
+	ctx := context.Background()
+	_, secret, _ := enrollTOTP(t, auth, "test@example.com")
+	challenge := mfaChallenge(t, auth, "test@example.com")
+
+	if _, err := auth.SigninMFA(ctx, "garbage", "123456"); err != ErrInvalidMFAToken {
+		t.Errorf("Expected ErrInvalidMFAToken, got %v", err)
+	}
+	// The code spent on confirming cannot be replayed
+	step := totp.Counter(time.Now())
+	if _, err := auth.SigninMFA(ctx, challenge, totp.Code(secret, step)); err != ErrInvalidMFACode {
+		t.Errorf("Expected a replayed code to be refused, got %v", err)
+	}
+
+	next := totp.Code(secret, step+1)
+	tokens, err := auth.SigninMFA(ctx, challenge, next)
+	if err != nil {
+		t.Fatalf("SigninMFA() failed: %v", err)
+	}
+	claims, _ := auth.tokens.Verify(tokens.AccessToken)
+	if !slices.Equal(claims.AMR, []string{"pwd", "otp", "mfa"}) {
+		t.Errorf("Expected amr [pwd otp mfa], got %v", claims.AMR)
+	}
+	if _, err := auth.SigninMFA(ctx, challenge, next); err != ErrInvalidMFACode {
+		t.Errorf("Expected a used code to be refused, got %v", err)
+	}
+
+	// Refreshed tokens keep the methods of the signin
+	refreshed, err := auth.Refresh(ctx, tokens.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() failed: %v", err)
+	}
+	claims, _ = auth.tokens.Verify(refreshed.AccessToken)
+	if !claims.HasAMR(token.AMRMulti) {
+		t.Errorf("Expected amr to survive refresh, got %v", claims.AMR)
+	}
+}
+
+func TestAuthService_SigninPasswordAMR(t *testing.T) {
+	auth := setupMFAAuthService(t)
+	signupClaims(t, auth, "test@example.com")
+
+	tokens, err := auth.Signin(context.Background(), "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+	claims, _ := auth.tokens.Verify(tokens.AccessToken)
+	if !slices.Equal(claims.AMR, []string{"pwd"}) {
+		t.Errorf("Expected amr [pwd], got %v", claims.AMR)
+	}
+}
+
+func TestAuthService_SigninMFALockout(t *testing.T) {
+	limiter := lockout.NewLimiter(lockout.NewMemoryCounter(),
+		lockout.Policy{MaxFailures: 3, LockoutDuration: time.Hour},
+		lockout.Policy{}, time.Hour)
+	auth := setupMFAAuthService(t, WithLockout(limiter))
+	ctx := context.Background()
+	enrollTOTP(t, auth, "test@example.com")
+
+	// A correct password does not reset the count while a code is due
+	for i := 0; i < 3; i++ {
+		challenge := mfaChallenge(t, auth, "test@example.com")
+		if _, err := auth.SigninMFA(ctx, challenge, "000000"); err != ErrInvalidMFACode {
+			t.Fatalf("Attempt %d: expected ErrInvalidMFACode, got %v", i+1, err)
+		}
+	}
+	var locked *LockedError
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); !errors.As(err, &locked) {
+		t.Errorf("Expected a *LockedError, got %v", err)
+	}
+}
+
+func TestAuthService_MFADisabled(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	if _, err := auth.EnrollTOTP(ctx, &token.Claims{}, "password123"); err != ErrMFADisabled {
+		t.Errorf("EnrollTOTP() expected ErrMFADisabled, got %v", err)
+	}
+	if err := auth.ConfirmTOTP(ctx, &token.Claims{}, "123456"); err != ErrMFADisabled {
+		t.Errorf("ConfirmTOTP() expected ErrMFADisabled, got %v", err)
+	}
+	if _, err := auth.SigninMFA(ctx, "token", "123456"); err != ErrMFADisabled {
+		t.Errorf("SigninMFA() expected ErrMFADisabled, got %v", err)
+	}
+	if _, err := auth.SigninRecoveryCode(ctx, "token", "abcde-fghij"); err != ErrMFADisabled {
+		t.Errorf("SigninRecoveryCode() expected ErrMFADisabled, got %v", err)
+	}
+	if _, err := auth.RegenerateRecoveryCodes(ctx, &token.Claims{}, "password123"); err != ErrMFADisabled {
+		t.Errorf("RegenerateRecoveryCodes() expected ErrMFADisabled, got %v", err)
+	}
+}
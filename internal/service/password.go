@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+// ErrPasswordReused rejects a new password equal to the current one or, with
+// password history enabled, to one of the user's recent passwords.
+var ErrPasswordReused = errors.New("password was used recently")
+
+// WithPasswordHistory remembers the last n passwords each user replaced in
+// hs and refuses them as new passwords.
+func WithPasswordHistory(hs store.PasswordHistoryStore, n int) Option {
+	return func(a *AuthService) {
+		a.history = hs
+		a.historySize = n
+	}
+}
+
+// ChangePassword replaces the password of the user the claims belong to
+// after checking their current one; see checkPassword. With revokeOthers,
+// every session but the one the claims come from is signed out.
+func (a *AuthService) ChangePassword(ctx context.Context, claims *token.Claims, current, password string, revokeOthers bool) error {
+	u, err := a.claimsUser(ctx, claims)
+	if err != nil {
+		return err
+	}
+	if err := a.checkPassword(ctx, u, current); err != nil {
+		return err
+	}
+	if err := a.checkReuse(ctx, u, password); err != nil {
+		return err
+	}
+	hashPw, err := a.hash(ctx, password)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if _, err := a.setPassword(ctx, u.ID, hashPw, now, nil); err != nil {
+		return err
+	}
+	if !revokeOthers {
+		return nil
+	}
+	// Tokens issued before sessions existed carry no session to keep.
+	keep, _ := uuid.Parse(claims.SessionID)
+	return a.revokeUserSessions(ctx, u.ID, keep, now)
+}
+
+// claimsUser returns the live user the claims belong to.
+func (a *AuthService) claimsUser(ctx context.Context, claims *token.Claims) (*model.User, error) {
+	id, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return a.Profile(ctx, id)
+}
+
+// checkPassword confirms that an already authenticated user knows their
+// password before a sensitive change. Attempts are throttled and counted
+// like signins, so a stolen access token cannot be used to guess it; a
+// wrong password yields ErrInvalidCreds.
+func (a *AuthService) checkPassword(ctx context.Context, u *model.User, password string) error {
+	attempt, err := a.beginAttempt(ctx, u.Email)
+	if err != nil {
+		return err
+	}
+	if u.LockedUntil != nil {
+		if wait := time.Until(*u.LockedUntil); wait > 0 {
+			a.cancelAttempt(ctx, attempt)
+			return &LockedError{RetryAfter: wait}
+		}
+	}
+	match, err := a.compare(ctx, u.Password, password)
+	if err != nil {
+		a.cancelAttempt(ctx, attempt)
+		return err
+	}
+	if !match {
+		return a.signinFailed(ctx, u, attempt)
+	}
+	a.signinSucceeded(ctx, u, attempt)
+	return nil
+}
+
+// checkReuse refuses password if it is u's current password or one of the
+// recent ones kept in the password history.
+func (a *AuthService) checkReuse(ctx context.Context, u *model.User, password string) error {
+	hashes := []string{u.Password}
+	if a.history != nil {
+		recent, err := a.history.Recent(ctx, u.ID, a.historySize)
+		if err != nil {
+			return err
+		}
+		hashes = append(hashes, recent...)
+	}
+	for _, h := range hashes {
+		match, err := a.compare(ctx, h, password)
+		if err != nil {
+			return err
+		}
+		if match {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
+
+// setPassword stores hashPw as the password of the user with the given ID,
+// applying edit, when set, in the same update. Concurrent updates to the
+// user are retried. The replaced hash goes into the password history, and
+// reset links mailed before the change stop working.
+func (a *AuthService) setPassword(ctx context.Context, id uuid.UUID, hashPw string, now time.Time, edit func(*model.User)) (*model.User, error) {
+	for attempt := 0; ; attempt++ {
+		u, err := a.users.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			return nil, ErrUserNotFound
+		}
+		old := u.Password
+		u.Password = hashPw
+		if edit != nil {
+			edit(u)
+		}
+		err = a.users.Update(ctx, u)
+		if errors.Is(err, store.ErrConflict) && attempt < 2 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if a.history != nil {
+			// Losing a history entry only weakens the reuse check.
+			if err := a.history.Add(ctx, u.ID, old, a.historySize); err != nil {
+				log.Printf("record password history of user %s: %v", u.ID, err)
+			}
+		}
+		if a.reset != nil {
+			if err := a.reset.Store.MarkUserUsed(ctx, u.ID, now); err != nil {
+				return nil, err
+			}
+		}
+		return u, nil
+	}
+}
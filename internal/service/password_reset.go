@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/mailer"
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+var (
+	ErrInvalidResetToken     = errors.New("invalid password reset token")
+	ErrPasswordResetDisabled = errors.New("password reset is disabled")
+)
+
+// PasswordReset configures WithPasswordReset.
+type PasswordReset struct {
+	Store  store.PasswordResetStore
+	Mailer mailer.Mailer
+	// TTL is how long a reset link stays valid.
+	TTL time.Duration
+	// LinkURL is the page that submits the token to /password/reset; the
+	// token is appended to it, e.g. "https://app.example.com/reset?token=".
+	LinkURL string
+}
+
+// WithPasswordReset lets users who forgot their password set a new one
+// through a single-use link mailed to their address.
+func WithPasswordReset(r PasswordReset) Option {
+	return func(a *AuthService) { a.reset = &r }
+}
+
+// ForgotPassword mails a password reset link to email if it belongs to an
+// account. It succeeds silently otherwise, so it cannot be used to find out
+// which emails are registered. The link is created, stored and mailed in
+// the background, so registered emails are answered as quickly as unknown
+// ones; failures are logged.
+func (a *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	if a.reset == nil {
+		return ErrPasswordResetDisabled
+	}
+	u, err := a.users.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	go func() {
+		defer cancel()
+		if err := a.sendResetLink(ctx, u); err != nil {
+			log.Printf("password reset for user %s: %v", u.ID, err)
+		}
+	}()
+	return nil
+}
+
+// sendResetLink stores a new reset token for u and mails it the link.
+func (a *AuthService) sendResetLink(ctx context.Context, u *model.User) error {
+	// Reset tokens have the same shape as refresh tokens: random, opaque,
+	// and stored only as a hash.
+	tok, err := token.NewRefreshToken()
+	if err != nil {
+		return err
+	}
+	err = a.reset.Store.Create(ctx, &model.PasswordResetToken{
+		UserID:    u.ID,
+		TokenHash: token.HashRefreshToken(tok),
+		ExpiresAt: time.Now().Add(a.reset.TTL),
+	})
+	if err != nil {
+		return err
+	}
+	return a.reset.Mailer.Send(ctx, mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: "Someone asked to reset the password of your account. To choose a new " +
+			"password, open the link below:\n\n" + a.reset.LinkURL + tok +
+			"\n\nThe link expires in " + a.reset.TTL.String() + " and works once. " +
+			"If you did not ask for it, you can ignore this message.",
+	})
+}
+
+// ResetPassword sets a new password for the account a reset token was
+// issued for. The password is held to the same reuse rules as
+// ChangePassword. The token is consumed, along with every other outstanding
+// token of the account, and all existing sessions are revoked so whoever
+// knew the old password is signed out. Following the link also proves the
+// user owns the address, so the email counts as verified and any lockout
+// is lifted.
+func (a *AuthService) ResetPassword(ctx context.Context, tok, password string) error {
+	if a.reset == nil {
+		return ErrPasswordResetDisabled
+	}
+	rt, err := a.reset.Store.GetByHash(ctx, token.HashRefreshToken(tok))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if rt == nil || rt.UsedAt != nil || now.After(rt.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	u, err := a.users.GetByID(ctx, rt.UserID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrInvalidResetToken
+	}
+
+	// Check and hash before consuming the token, so a rejected or shed
+	// request can be retried with the same link.
+	if err := a.checkReuse(ctx, u, password); err != nil {
+		return err
+	}
+	hashPw, err := a.hash(ctx, password)
+	if err != nil {
+		return err
+	}
+	if err := a.reset.Store.MarkUsed(ctx, rt.ID, now); err != nil {
+		if errors.Is(err, store.ErrResetTokenUsed) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+
+	u, err = a.setPassword(ctx, u.ID, hashPw, now, func(u *model.User) {
+		u.LockedUntil = nil
+		if u.EmailVerifiedAt == nil {
+			u.EmailVerifiedAt = &now
+		}
+	})
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if err := a.signOutEverywhere(ctx, u.ID, now); err != nil {
+		return err
+	}
+	if a.lockout != nil {
+		if err := a.lockout.Reset(ctx, u.Email); err != nil {
+			log.Printf("reset failed signins for user %s: %v", u.ID, err)
+		}
+	}
+	a.sendAsync(ctx, a.reset.Mailer, mailer.Message{
+		To:      u.Email,
+		Subject: "Your password was changed",
+		Body: "The password of your account was just reset and every device was " +
+			"signed out.\n\nIf you did not do this, reset your password again right away.",
+	})
+	return nil
+}
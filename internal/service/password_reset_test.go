@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/lockout"
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+const testResetURL = "https://app.example.com/reset?token="
+
+func setupResetAuthService(opts ...Option) (*AuthService, *memory.UserStore, *recordingMailer) {
+	users := memory.NewUserStore()
+	mail := newRecordingMailer()
+	opts = append([]Option{
+		WithRefreshTokens(memory.NewRefreshTokenStore(), time.Hour),
+		WithPasswordReset(PasswordReset{
+			Store:   memory.NewPasswordResetStore(),
+			Mailer:  mail,
+			TTL:     time.Hour,
+			LinkURL: testResetURL,
+		}),
+	}, opts...)
+	auth := NewAuthService(users, hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute), opts...)
+	return auth, users, mail
+}
+
+// expectMail waits for the next mail and checks who it went to.
+func expectMail(t *testing.T, mail *recordingMailer, to string) {
+	t.Helper()
+	select {
+	case msg := <-mail.sent:
+		if msg.To != to {
+			t.Errorf("Expected mail to %s, got %s", to, msg.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected a mail to %s", to)
+	}
+}
+
+func TestAuthService_ResetPassword(t *testing.T) {
+	auth, _, mail := setupResetAuthService()
+	ctx := context.Background()
+
+	old, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	if err := auth.ForgotPassword(ctx, "test@example.com"); err != nil {
+		t.Fatalf("ForgotPassword() failed: %v", err)
+	}
+	tok := mailedToken(t, mail, testResetURL)
+
+	if err := auth.ResetPassword(ctx, tok, "newpassword1"); err != nil {
+		t.Fatalf("ResetPassword() failed: %v", err)
+	}
+	expectMail(t, mail, "test@example.com")
+
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != ErrInvalidCreds {
+		t.Errorf("Expected the old password to be rejected, got %v", err)
+	}
+	if _, err := auth.Signin(ctx, "test@example.com", "newpassword1"); err != nil {
+		t.Errorf("Signin() with the new password failed: %v", err)
+	}
+
+	// Sessions from before the reset are gone
+	if _, err := auth.tokens.Verify(old.AccessToken); err == nil {
+		t.Error("Expected the old access token to be revoked")
+	}
+	if _, err := auth.Refresh(ctx, old.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected the old refresh token to be revoked, got %v", err)
+	}
+
+	// The token works once
+	if err := auth.ResetPassword(ctx, tok, "otherpassword1"); err != ErrInvalidResetToken {
+		t.Errorf("Second ResetPassword() expected ErrInvalidResetToken, got %v", err)
+	}
+}
+
+func TestAuthService_ResetPasswordWithoutRefreshTokens(t *testing.T) {
+	users := memory.NewUserStore()
+	mail := newRecordingMailer()
+	auth := NewAuthService(users, hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute),
+		WithPasswordReset(PasswordReset{
+			Store:   memory.NewPasswordResetStore(),
+			Mailer:  mail,
+			TTL:     time.Hour,
+			LinkURL: testResetURL,
+		}))
+	ctx := context.Background()
+
+	old, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	_ = auth.ForgotPassword(ctx, "test@example.com")
+	if err := auth.ResetPassword(ctx, mailedToken(t, mail, testResetURL), "newpassword1"); err != nil {
+		t.Fatalf("ResetPassword() failed: %v", err)
+	}
+
+	// Untracked sessions end too, and new signins work straight away
+	if _, err := auth.tokens.Verify(old.AccessToken); err != token.ErrTokenRevoked {
+		t.Errorf("Expected the old access token to be revoked, got %v", err)
+	}
+	tokens, err := auth.Signin(ctx, "test@example.com", "newpassword1")
+	if err != nil {
+		t.Fatalf("Signin() with the new password failed: %v", err)
+	}
+	if _, err := auth.tokens.Verify(tokens.AccessToken); err != nil {
+		t.Errorf("Verify() of the new access token failed: %v", err)
+	}
+}
+
+func TestAuthService_ResetPasswordSupersedesOtherTokens(t *testing.T) {
+	auth, _, mail := setupResetAuthService()
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	_ = auth.ForgotPassword(ctx, "test@example.com")
+	first := mailedToken(t, mail, testResetURL)
+	_ = auth.ForgotPassword(ctx, "test@example.com")
+	second := mailedToken(t, mail, testResetURL)
+
+	if err := auth.ResetPassword(ctx, second, "newpassword1"); err != nil {
+		t.Fatalf("ResetPassword() failed: %v", err)
+	}
+	if err := auth.ResetPassword(ctx, first, "otherpassword1"); err != ErrInvalidResetToken {
+		t.Errorf("Expected the earlier token to be spent, got %v", err)
+	}
+}
+
+func TestAuthService_ResetPasswordInvalidToken(t *testing.T) {
+	auth, _, _ := setupResetAuthService()
+	ctx := context.Background()
+
+	if err := auth.ResetPassword(ctx, "not-a-token", "newpassword1"); err != ErrInvalidResetToken {
+		t.Errorf("Expected ErrInvalidResetToken, got %v", err)
+	}
+}
+
+func TestAuthService_ResetPasswordExpiredToken(t *testing.T) {
+	auth, _, mail := setupResetAuthService()
+	auth.reset.TTL = -time.Minute
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	_ = auth.ForgotPassword(ctx, "test@example.com")
+	if err := auth.ResetPassword(ctx, mailedToken(t, mail, testResetURL), "newpassword1"); err != ErrInvalidResetToken {
+		t.Errorf("Expected ErrInvalidResetToken, got %v", err)
+	}
+}
+
+func TestAuthService_ForgotPasswordUnknownEmail(t *testing.T) {
+	auth, _, mail := setupResetAuthService()
+
+	if err := auth.ForgotPassword(context.Background(), "nobody@example.com"); err != nil {
+		t.Errorf("ForgotPassword() for an unknown email failed: %v", err)
+	}
+	select {
+	case msg := <-mail.sent:
+		t.Errorf("Expected no mail for an unknown email, got %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// blockingResetStore holds every Create until release is closed.
+type blockingResetStore struct {
+	store.PasswordResetStore
+	release chan struct{}
+}
+
+func (s *blockingResetStore) Create(ctx context.Context, t *model.PasswordResetToken) error {
+	<-s.release
+	return s.PasswordResetStore.Create(ctx, t)
+}
+
+func TestAuthService_ForgotPasswordDoesNotWaitForTheToken(t *testing.T) {
+	resets := &blockingResetStore{PasswordResetStore: memory.NewPasswordResetStore(), release: make(chan struct{})}
+	auth, _, mail := setupResetAuthService()
+	auth.reset.Store = resets
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+
+	// A registered email returns before its token is stored, like an
+	// unknown one
+	done := make(chan error, 1)
+	go func() { done <- auth.ForgotPassword(ctx, "test@example.com") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ForgotPassword() failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ForgotPassword() waited for the reset token to be stored")
+	}
+
+	close(resets.release)
+	if err := auth.ResetPassword(ctx, mailedToken(t, mail, testResetURL), "newpassword1"); err != nil {
+		t.Errorf("ResetPassword() failed: %v", err)
+	}
+}
+
+func TestAuthService_ResetPasswordLiftsLockout(t *testing.T) {
+	limiter := lockout.NewLimiter(lockout.NewMemoryCounter(),
+		lockout.Policy{MaxFailures: 1, LockoutDuration: time.Hour},
+		lockout.Policy{}, time.Hour)
+	auth, _, mail := setupResetAuthService(WithLockout(limiter))
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	_, _ = auth.Signin(ctx, "test@example.com", "wrongpassword1")
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err == nil {
+		t.Fatal("Expected the account to be locked")
+	}
+
+	_ = auth.ForgotPassword(ctx, "test@example.com")
+	if err := auth.ResetPassword(ctx, mailedToken(t, mail, testResetURL), "newpassword1"); err != nil {
+		t.Fatalf("ResetPassword() failed: %v", err)
+	}
+	if _, err := auth.Signin(ctx, "test@example.com", "newpassword1"); err != nil {
+		t.Errorf("Signin() after reset failed: %v", err)
+	}
+}
+
+func TestAuthService_PasswordResetDisabled(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	if err := auth.ForgotPassword(ctx, "test@example.com"); err != ErrPasswordResetDisabled {
+		t.Errorf("ForgotPassword() expected ErrPasswordResetDisabled, got %v", err)
+	}
+	if err := auth.ResetPassword(ctx, "token", "newpassword1"); err != ErrPasswordResetDisabled {
+		t.Errorf("ResetPassword() expected ErrPasswordResetDisabled, got %v", err)
+	}
+}
+
+func TestAuthService_ResetPasswordRejectsReuse(t *testing.T) {
+	auth, _, mail := setupResetAuthService()
+	ctx := context.Background()
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	_ = auth.ForgotPassword(ctx, "test@example.com")
+	tok := mailedToken(t, mail, testResetURL)
+
+	if err := auth.ResetPassword(ctx, tok, "password123"); err != ErrPasswordReused {
+		t.Fatalf("Expected ErrPasswordReused, got %v", err)
+	}
+	// A refused password leaves the link usable
+	if err := auth.ResetPassword(ctx, tok, "newpassword1"); err != nil {
+		t.Errorf("ResetPassword() after a refused password failed: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/lockout"
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+func setupPasswordAuthService(history int, opts ...Option) *AuthService {
+	opts = append([]Option{
+		WithRefreshTokens(memory.NewRefreshTokenStore(), time.Hour),
+		WithPasswordHistory(memory.NewPasswordHistoryStore(), history),
+	}, opts...)
+	return NewAuthService(memory.NewUserStore(), hash.Bcrypt{Cost: 4},
+		token.NewJWTManager("test-secret-key", 15*time.Minute), opts...)
+}
+
+// signupClaims signs up email and returns its tokens with their claims.
+func signupClaims(t *testing.T, auth *AuthService, email string) (*Tokens, *token.Claims) {
+	t.Helper()
+	tokens, err := auth.Signup(context.Background(), email, "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	claims, err := auth.tokens.Verify(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	return tokens, claims
+}
+
+func TestAuthService_ChangePassword(t *testing.T) {
+	auth := setupPasswordAuthService(5)
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "test@example.com")
+
+	if err := auth.ChangePassword(ctx, claims, "password123", "newpassword1", false); err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != ErrInvalidCreds {
+		t.Errorf("Expected the old password to be rejected, got %v", err)
+	}
+	if _, err := auth.Signin(ctx, "test@example.com", "newpassword1"); err != nil {
+		t.Errorf("Signin() with the new password failed: %v", err)
+	}
+}
+
+func TestAuthService_ChangePasswordWrongCurrent(t *testing.T) {
+	limiter := lockout.NewLimiter(lockout.NewMemoryCounter(),
+		lockout.Policy{MaxFailures: 2, LockoutDuration: time.Hour},
+		lockout.Policy{}, time.Hour)
+	auth := setupPasswordAuthService(5, WithLockout(limiter))
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "test@example.com")
+
+	for i := 0; i < 2; i++ {
+		if err := auth.ChangePassword(ctx, claims, "wrongpassword1", "newpassword1", false); err != ErrInvalidCreds {
+			t.Fatalf("Attempt %d: expected ErrInvalidCreds, got %v", i+1, err)
+		}
+	}
+	// Guesses count against the account like failed signins
+	var locked *LockedError
+	if err := auth.ChangePassword(ctx, claims, "password123", "newpassword1", false); !errors.As(err, &locked) {
+		t.Errorf("Expected a *LockedError, got %v", err)
+	}
+}
+
+func TestAuthService_ChangePasswordRejectsReuse(t *testing.T) {
+	auth := setupPasswordAuthService(2)
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "test@example.com")
+
+	if err := auth.ChangePassword(ctx, claims, "password123", "password123", false); err != ErrPasswordReused {
+		t.Errorf("Expected the current password to be refused, got %v", err)
+	}
+
+	current := "password123"
+	for _, next := range []string{"password234", "password345", "password456"} {
+		if err := auth.ChangePassword(ctx, claims, current, next, false); err != nil {
+			t.Fatalf("ChangePassword(%s) failed: %v", next, err)
+		}
+		current = next
+	}
+	// The last two replaced passwords are remembered; older ones are not
+	for pw, want := range map[string]error{
+		"password345": ErrPasswordReused,
+		"password234": ErrPasswordReused,
+		"password123": nil,
+	} {
+		if err := auth.checkReuse(ctx, mustUser(t, auth, "test@example.com"), pw); err != want {
+			t.Errorf("checkReuse(%s) = %v, want %v", pw, err, want)
+		}
+	}
+}
+
+func mustUser(t *testing.T, auth *AuthService, email string) *model.User {
+	t.Helper()
+	u, err := auth.users.GetByEmail(context.Background(), email)
+	if err != nil || u == nil {
+		t.Fatalf("GetByEmail(%s) = %v, %v", email, u, err)
+	}
+	return u
+}
+
+func TestAuthService_ChangePasswordRevokesOtherSessions(t *testing.T) {
+	auth := setupPasswordAuthService(5)
+	ctx := context.Background()
+	current, claims := signupClaims(t, auth, "test@example.com")
+	other, err := auth.Signin(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+
+	if err := auth.ChangePassword(ctx, claims, "password123", "newpassword1", true); err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+	if _, err := auth.tokens.Verify(other.AccessToken); err == nil {
+		t.Error("Expected the other session's access token to be revoked")
+	}
+	if _, err := auth.Refresh(ctx, other.RefreshToken); err != ErrInvalidRefreshToken {
+		t.Errorf("Expected the other session's refresh token to be revoked, got %v", err)
+	}
+	if _, err := auth.tokens.Verify(current.AccessToken); err != nil {
+		t.Errorf("Expected the current access token to stay valid, got %v", err)
+	}
+	if _, err := auth.Refresh(ctx, current.RefreshToken); err != nil {
+		t.Errorf("Expected the current refresh token to stay valid, got %v", err)
+	}
+}
+
+func TestAuthService_ChangePasswordKeepsSessions(t *testing.T) {
+	auth := setupPasswordAuthService(5)
+	ctx := context.Background()
+	_, claims := signupClaims(t, auth, "test@example.com")
+	other, _ := auth.Signin(ctx, "test@example.com", "password123")
+
+	if err := auth.ChangePassword(ctx, claims, "password123", "newpassword1", false); err != nil {
+		t.Fatalf("ChangePassword() failed: %v", err)
+	}
+	if _, err := auth.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Expected other sessions to stay signed in, got %v", err)
+	}
+}
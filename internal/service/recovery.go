@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/audit"
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/pkg/token"
+	"github.com/coinbase/identity-service/pkg/totp"
+)
+
+// ErrMFANotEnabled rejects managing recovery codes for a user without
+// confirmed TOTP.
+var ErrMFANotEnabled = errors.New("totp is not enabled")
+
+const (
+	// recoveryCodeCount is how many recovery codes a set holds.
+	recoveryCodeCount = 10
+	// recoveryCodeBytes of randomness make 10 base32 characters per code.
+	recoveryCodeBytes = 6
+)
+
+// SigninRecoveryCode completes a signin that Signin answered with an
+// *MFARequiredError using one of the user's recovery codes instead of a
+// TOTP code. Each code works once; wrong ones count as failed signins, like
+// wrong TOTP codes, and each use goes into the audit trail.
+func (a *AuthService) SigninRecoveryCode(ctx context.Context, challenge, code string) (*Tokens, error) {
+	accept := func(id uuid.UUID, check func(*model.User) error) (*model.User, error) {
+		return a.acceptRecoveryCode(ctx, id, code, check)
+	}
+	return a.completeMFA(ctx, challenge, accept, []string{token.AMRPassword, token.AMRMulti})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user the claims
+// belong to with a fresh set, after checking their password, and returns
+// the new codes. Codes from earlier sets stop working.
+func (a *AuthService) RegenerateRecoveryCodes(ctx context.Context, claims *token.Claims, password string) ([]string, error) {
+	if a.mfa == nil {
+		return nil, ErrMFADisabled
+	}
+	u, err := a.claimsUser(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.checkPassword(ctx, u, password); err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+	codes, macs, err := a.newRecoveryCodes(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	u.RecoveryCodes = macs
+	if err := a.users.Update(ctx, u); err != nil {
+		return nil, err
+	}
+	a.record(ctx, audit.ActionRecoveryCodesRegenerated, u, "")
+	return codes, nil
+}
+
+// newRecoveryCodes returns a set of recovery codes for the user with the
+// given ID, formatted for display, and their MACs for storage.
+func (a *AuthService) newRecoveryCodes(id uuid.UUID) (codes, macs []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(totp.Encoding.EncodeToString(b))
+		codes = append(codes, code[:5]+"-"+code[5:])
+		macs = append(macs, a.recoveryCodeMAC(id, code))
+	}
+	return codes, macs, nil
+}
+
+// recoveryCodeMAC returns the stored form of a normalized recovery code.
+// Codes carry only 48 bits, so a plain or salted digest could be reversed
+// offline from a database dump; keying the MAC with a secret outside the
+// database prevents that, and being cheap it costs a signin attempt next to
+// nothing to check a whole set. Binding the user ID keeps a MAC from
+// working if copied onto another row.
+func (a *AuthService) recoveryCodeMAC(id uuid.UUID, code string) string {
+	m := hmac.New(sha256.New, a.mfa.RecoveryKey)
+	m.Write([]byte("recovery-code\x00"))
+	m.Write(id[:])
+	m.Write([]byte(code))
+	return base64.RawStdEncoding.EncodeToString(m.Sum(nil))
+}
+
+// normalizeRecoveryCode undoes the display formatting of a recovery code,
+// so codes are accepted in any case and with or without the dash.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// acceptRecoveryCode removes code from the recovery codes of the user with
+// the given ID, once check has passed; see secondFactor. Only one of
+// several concurrent requests with the same code can remove it.
+func (a *AuthService) acceptRecoveryCode(ctx context.Context, id uuid.UUID, code string, check func(*model.User) error) (*model.User, error) {
+	code = normalizeRecoveryCode(code)
+	// matched is the stored MAC code was found to match; a retry after a
+	// conflict only has to look for it again.
+	var matched string
+	for attempt := 0; ; attempt++ {
+		u, err := a.users.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			return nil, ErrUserNotFound
+		}
+		if err := check(u); err != nil {
+			return nil, err
+		}
+		if matched == "" {
+			matched = a.matchRecoveryCode(id, u.RecoveryCodes, code)
+		}
+		i := slices.Index(u.RecoveryCodes, matched)
+		if matched == "" || i < 0 {
+			return u, ErrInvalidMFACode
+		}
+		u.RecoveryCodes = slices.Delete(u.RecoveryCodes, i, i+1)
+		err = a.users.Update(ctx, u)
+		if errors.Is(err, store.ErrConflict) && attempt < 2 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		a.record(ctx, audit.ActionRecoveryCodeUsed, u, fmt.Sprintf("%d remaining", len(u.RecoveryCodes)))
+		return u, nil
+	}
+}
+
+// matchRecoveryCode returns the MAC among macs that code matches, or ""
+// when there is none. Every MAC is compared in constant time, so the time
+// taken says nothing about which, if any, matched.
+func (a *AuthService) matchRecoveryCode(id uuid.UUID, macs []string, code string) string {
+	if code == "" {
+		return ""
+	}
+	want := a.recoveryCodeMAC(id, code)
+	matched := ""
+	for _, m := range macs {
+		if hmac.Equal([]byte(m), []byte(want)) {
+			matched = m
+		}
+	}
+	return matched
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/coinbase/identity-service/internal/audit"
+)
+
+func TestAuthService_SigninRecoveryCode(t *testing.T) {
+	rec := &audit.MemoryRecorder{}
+	auth := setupMFAAuthService(t, WithAudit(rec))
+	ctx := WithClientIP(context.Background(), "192.0.2.1")
+	_, _, codes := enrollTOTP(t, auth, "test@example.com")
+	challenge := mfaChallenge(t, auth, "test@example.com")
+
+	if _, err := auth.SigninRecoveryCode(ctx, challenge, "aaaaa-aaaaa"); err != ErrInvalidMFACode {
+		t.Errorf("Expected ErrInvalidMFACode, got %v", err)
+	}
+
+	// Codes are accepted in any case, with or without the dash
+	tokens, err := auth.SigninRecoveryCode(ctx, challenge, strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")))
+	if err != nil {
+		t.Fatalf("SigninRecoveryCode() failed: %v", err)
+	}
+	claims, _ := auth.tokens.Verify(tokens.AccessToken)
+	if !slices.Equal(claims.AMR, []string{"pwd", "mfa"}) {
+		t.Errorf("Expected amr [pwd mfa], got %v", claims.AMR)
+	}
+	if _, err := auth.SigninRecoveryCode(ctx, challenge, codes[0]); err != ErrInvalidMFACode {
+		t.Errorf("Expected a used code to be refused, got %v", err)
+	}
+	if _, err := auth.SigninRecoveryCode(ctx, challenge, codes[1]); err != nil {
+		t.Fatalf("SigninRecoveryCode() with another code failed: %v", err)
+	}
+	if u := mustUser(t, auth, "test@example.com"); len(u.RecoveryCodes) != recoveryCodeCount-2 {
+		t.Errorf("Expected %d codes left, got %d", recoveryCodeCount-2, len(u.RecoveryCodes))
+	}
+
+	events := rec.Events()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 audit events, got %+v", events)
+	}
+	u := mustUser(t, auth, "test@example.com")
+	e := events[1]
+	if e.Action != audit.ActionRecoveryCodeUsed || e.UserID != u.ID || e.IP != "192.0.2.1" || e.Detail != "8 remaining" {
+		t.Errorf("Unexpected audit event %+v", e)
+	}
+}
+
+func TestAuthService_RegenerateRecoveryCodes(t *testing.T) {
+	rec := &audit.MemoryRecorder{}
+	auth := setupMFAAuthService(t, WithAudit(rec))
+	ctx := context.Background()
+
+	_, plain := signupClaims(t, auth, "plain@example.com")
+	if _, err := auth.RegenerateRecoveryCodes(ctx, plain, "password123"); err != ErrMFANotEnabled {
+		t.Errorf("Expected ErrMFANotEnabled without TOTP, got %v", err)
+	}
+
+	claims, _, old := enrollTOTP(t, auth, "test@example.com")
+	if _, err := auth.RegenerateRecoveryCodes(ctx, claims, "wrongpassword1"); err != ErrInvalidCreds {
+		t.Errorf("Expected ErrInvalidCreds, got %v", err)
+	}
+	codes, err := auth.RegenerateRecoveryCodes(ctx, claims, "password123")
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes() failed: %v", err)
+	}
+	if len(codes) != recoveryCodeCount || slices.Contains(codes, old[0]) {
+		t.Fatalf("Expected %d new codes, got %v", recoveryCodeCount, codes)
+	}
+
+	challenge := mfaChallenge(t, auth, "test@example.com")
+	if _, err := auth.SigninRecoveryCode(ctx, challenge, old[0]); err != ErrInvalidMFACode {
+		t.Errorf("Expected a replaced code to be refused, got %v", err)
+	}
+	if _, err := auth.SigninRecoveryCode(ctx, challenge, codes[0]); err != nil {
+		t.Fatalf("SigninRecoveryCode() with a new code failed: %v", err)
+	}
+
+	events := rec.Events()
+	if len(events) == 0 || events[0].Action != audit.ActionRecoveryCodesRegenerated {
+		t.Errorf("Expected the regeneration to be audited first, got %+v", events)
+	}
+}
+
+func TestAuthService_RecoveryCodesAreBound(t *testing.T) {
+	auth := setupMFAAuthService(t)
+	ctx := context.Background()
+	_, _, codes := enrollTOTP(t, auth, "alice@example.com")
+	enrollTOTP(t, auth, "bob@example.com")
+
+	// A MAC copied onto another user's row doesn't carry the code with it
+	alice := mustUser(t, auth, "alice@example.com")
+	bob := mustUser(t, auth, "bob@example.com")
+	bob.RecoveryCodes = alice.RecoveryCodes
+	if err := auth.users.Update(ctx, bob); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	challenge := mfaChallenge(t, auth, "bob@example.com")
+	if _, err := auth.SigninRecoveryCode(ctx, challenge, codes[0]); err != ErrInvalidMFACode {
+		t.Errorf("Expected another user's code to be refused, got %v", err)
+	}
+
+	// Neither does one checked under another key
+	auth.mfa.RecoveryKey = []byte("another-mfa-encryption-key-for-tests")
+	challenge = mfaChallenge(t, auth, "alice@example.com")
+	if _, err := auth.SigninRecoveryCode(ctx, challenge, codes[0]); err != ErrInvalidMFACode {
+		t.Errorf("Expected a code to be refused under another key, got %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/mailer"
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+var (
+	ErrEmailNotVerified         = errors.New("email not verified")
+	ErrEmailAlreadyVerified     = errors.New("email already verified")
+	ErrInvalidVerificationToken = errors.New("invalid verification token")
+	ErrVerificationDisabled     = errors.New("email verification is disabled")
+)
+
+// purposeVerifyEmail is the purpose claim of email verification tokens.
+const purposeVerifyEmail = "verify-email"
+
+// EmailVerification configures WithEmailVerification.
+type EmailVerification struct {
+	Mailer mailer.Mailer
+	Signer *token.ActionSigner
+	// TTL is how long a verification link stays valid.
+	TTL time.Duration
+	// LinkURL is the page that submits the token to /verify-email; the
+	// token is appended to it, e.g. "https://app.example.com/verify?token=".
+	LinkURL string
+	// BlockSignin refuses tokens to unverified users altogether. Otherwise
+	// they sign in with UnverifiedScopes instead of the usual scopes.
+	BlockSignin      bool
+	UnverifiedScopes []string
+}
+
+// WithEmailVerification mails new users a link proving they own their
+// address, and limits what they can do until they follow it.
+func WithEmailVerification(v EmailVerification) Option {
+	return func(a *AuthService) { a.verification = &v }
+}
+
+// VerifyEmail marks the account a verification token was issued for as
+// verified. A token only works once, and only while the account still has
+// the address it was sent to.
+func (a *AuthService) VerifyEmail(ctx context.Context, tok string) error {
+	if a.verification == nil {
+		return ErrVerificationDisabled
+	}
+	claims, err := a.verification.Signer.Verify(tok, purposeVerifyEmail)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+	id, _ := claims.UserID()
+
+	// A concurrent update to the user is retried; a concurrent use of a
+	// token for the same user then finds the email already verified.
+	for attempt := 0; ; attempt++ {
+		u, err := a.users.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if u == nil || u.Email != claims.Email {
+			return ErrInvalidVerificationToken
+		}
+		if u.EmailVerifiedAt != nil {
+			return ErrEmailAlreadyVerified
+		}
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+		err = a.users.Update(ctx, u)
+		if errors.Is(err, store.ErrConflict) && attempt < 2 {
+			continue
+		}
+		return err
+	}
+}
+
+// ResendVerification mails a new verification link to email if it belongs
+// to an unverified account. It succeeds silently otherwise, so it cannot be
+// used to find out which emails are registered.
+func (a *AuthService) ResendVerification(ctx context.Context, email string) error {
+	if a.verification == nil {
+		return ErrVerificationDisabled
+	}
+	u, err := a.users.GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if u == nil || u.EmailVerifiedAt != nil {
+		return nil
+	}
+	return a.sendVerification(ctx, u)
+}
+
+// sendVerification mails u a link to verify its current email.
+func (a *AuthService) sendVerification(ctx context.Context, u *model.User) error {
+	tok, err := a.verification.Signer.Sign(purposeVerifyEmail, u.ID, u.Email, a.verification.TTL)
+	if err != nil {
+		return err
+	}
+	a.sendAsync(ctx, a.verification.Mailer, mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: "Confirm that this is your email address by opening the link below:\n\n" +
+			a.verification.LinkURL + tok + "\n\nThe link expires in " + a.verification.TTL.String() +
+			". If you did not create an account, you can ignore this message.",
+	})
+	return nil
+}
+
+// verified reports whether u may use the account without restriction.
+func (a *AuthService) verified(u *model.User) bool {
+	return a.verification == nil || u.EmailVerifiedAt != nil
+}
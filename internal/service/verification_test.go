@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/pkg/hash"
+	"github.com/coinbase/identity-service/pkg/token"
+)
+
+const testLinkURL = "https://app.example.com/verify?token="
+
+func setupVerifyingAuthService(block bool) (*AuthService, *memory.UserStore, *recordingMailer) {
+	users := memory.NewUserStore()
+	mail := newRecordingMailer()
+	tokens := token.NewJWTManager("test-secret-key", 15*time.Minute)
+	auth := NewAuthService(users, hash.Bcrypt{Cost: 4}, tokens,
+		WithScopes(ScopeProfile),
+		WithEmailVerification(EmailVerification{
+			Mailer:      mail,
+			Signer:      token.NewActionSigner([]byte("action-secret-key-for-tests-only")),
+			TTL:         time.Hour,
+			LinkURL:     testLinkURL,
+			BlockSignin: block,
+		}))
+	return auth, users, mail
+}
+
+// verificationToken waits for the next mail and extracts its token.
+func verificationToken(t *testing.T, mail *recordingMailer) string {
+	t.Helper()
+	return mailedToken(t, mail, testLinkURL)
+}
+
+// mailedToken waits for the next mail and extracts the token of the link
+// starting with linkURL.
+func mailedToken(t *testing.T, mail *recordingMailer, linkURL string) string {
+	t.Helper()
+	select {
+	case msg := <-mail.sent:
+		_, rest, ok := strings.Cut(msg.Body, linkURL)
+		if !ok {
+			t.Fatalf("Expected a link to %s in %q", linkURL, msg.Body)
+		}
+		tok, _, _ := strings.Cut(rest, "\n")
+		return tok
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a mail with a link")
+		return ""
+	}
+}
+
+func TestAuthService_VerifyEmailRestrictsScopes(t *testing.T) {
+	auth, _, mail := setupVerifyingAuthService(false)
+	ctx := context.Background()
+
+	tokens, err := auth.Signup(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	claims, _ := auth.tokens.Verify(tokens.AccessToken)
+	if claims.HasScope(ScopeProfile) {
+		t.Error("Expected an unverified user's token to lack the profile scope")
+	}
+
+	tok := verificationToken(t, mail)
+	if err := auth.VerifyEmail(ctx, tok); err != nil {
+		t.Fatalf("VerifyEmail() failed: %v", err)
+	}
+	if err := auth.VerifyEmail(ctx, tok); err != ErrEmailAlreadyVerified {
+		t.Errorf("Second VerifyEmail() expected ErrEmailAlreadyVerified, got %v", err)
+	}
+
+	tokens, err = auth.Signin(ctx, "test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Signin() failed: %v", err)
+	}
+	claims, _ = auth.tokens.Verify(tokens.AccessToken)
+	if !claims.HasScope(ScopeProfile) {
+		t.Error("Expected a verified user's token to carry the profile scope")
+	}
+}
+
+func TestAuthService_VerifyEmailBlocksSignin(t *testing.T) {
+	auth, _, mail := setupVerifyingAuthService(true)
+	ctx := context.Background()
+
+	tokens, err := auth.Signup(ctx, "test@example.com", "password123")
+	if tokens != nil || err != nil {
+		t.Fatalf("Expected nil tokens and error from Signup(), got %v, %v", tokens, err)
+	}
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != ErrEmailNotVerified {
+		t.Fatalf("Expected ErrEmailNotVerified, got %v", err)
+	}
+	// A wrong password still looks like any other wrong password
+	if _, err := auth.Signin(ctx, "test@example.com", "wrongpassword1"); err != ErrInvalidCreds {
+		t.Errorf("Expected ErrInvalidCreds, got %v", err)
+	}
+
+	if err := auth.VerifyEmail(ctx, verificationToken(t, mail)); err != nil {
+		t.Fatalf("VerifyEmail() failed: %v", err)
+	}
+	if _, err := auth.Signin(ctx, "test@example.com", "password123"); err != nil {
+		t.Errorf("Signin() after verification failed: %v", err)
+	}
+}
+
+func TestAuthService_VerifyEmailInvalidToken(t *testing.T) {
+	auth, users, mail := setupVerifyingAuthService(false)
+	ctx := context.Background()
+
+	if err := auth.VerifyEmail(ctx, "not-a-token"); err != ErrInvalidVerificationToken {
+		t.Errorf("Expected ErrInvalidVerificationToken, got %v", err)
+	}
+
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	tok := verificationToken(t, mail)
+
+	// The token is bound to the address it was sent to
+	u, _ := users.GetByEmail(ctx, "test@example.com")
+	u.Email = "other@example.com"
+	if err := users.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if err := auth.VerifyEmail(ctx, tok); err != ErrInvalidVerificationToken {
+		t.Errorf("Expected ErrInvalidVerificationToken after an email change, got %v", err)
+	}
+}
+
+func TestAuthService_ResendVerification(t *testing.T) {
+	auth, _, mail := setupVerifyingAuthService(false)
+	ctx := context.Background()
+
+	if err := auth.ResendVerification(ctx, "nobody@example.com"); err != nil {
+		t.Errorf("ResendVerification() for an unknown email failed: %v", err)
+	}
+	if _, err := auth.Signup(ctx, "test@example.com", "password123"); err != nil {
+		t.Fatalf("Signup() failed: %v", err)
+	}
+	first := verificationToken(t, mail)
+
+	if err := auth.ResendVerification(ctx, "test@example.com"); err != nil {
+		t.Fatalf("ResendVerification() failed: %v", err)
+	}
+	if err := auth.VerifyEmail(ctx, verificationToken(t, mail)); err != nil {
+		t.Fatalf("VerifyEmail() with the resent token failed: %v", err)
+	}
+	if err := auth.VerifyEmail(ctx, first); err != ErrEmailAlreadyVerified {
+		t.Errorf("Expected the first token to be spent, got %v", err)
+	}
+
+	if err := auth.ResendVerification(ctx, "test@example.com"); err != nil {
+		t.Errorf("ResendVerification() for a verified email failed: %v", err)
+	}
+	select {
+	case msg := <-mail.sent:
+		t.Errorf("Expected no mail for unknown or verified emails, got %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAuthService_VerificationDisabled(t *testing.T) {
+	auth := setupAuthService()
+	ctx := context.Background()
+
+	if err := auth.VerifyEmail(ctx, "token"); err != ErrVerificationDisabled {
+		t.Errorf("VerifyEmail() expected ErrVerificationDisabled, got %v", err)
+	}
+	if err := auth.ResendVerification(ctx, "test@example.com"); err != ErrVerificationDisabled {
+		t.Errorf("ResendVerification() expected ErrVerificationDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Package backend builds the stores selected by configuration, so every
+// command shares one definition of what each STORE_DRIVER means.
+package backend
+
+import (
+	"context"
+
+	"github.com/coinbase/identity-service/internal/config"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/internal/store/memory"
+	"github.com/coinbase/identity-service/internal/store/postgres"
+	"github.com/coinbase/identity-service/internal/store/sqlite"
+)
+
+// Stores groups the stores of one backend.
+type Stores struct {
+	Users   store.UserStore
+	Refresh store.RefreshTokenStore
+	Resets  store.PasswordResetStore
+	History store.PasswordHistoryStore
+}
+
+// Open builds the stores selected by cfg.StoreDriver. The returned func
+// releases any underlying resources.
+func Open(ctx context.Context, cfg config.Config) (*Stores, func(), error) {
+	switch cfg.StoreDriver {
+	case "postgres":
+		db, err := postgres.Open(ctx, cfg.DatabaseURL, postgres.PoolConfig{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+			ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := postgres.Migrate(ctx, db); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return &Stores{
+			Users:   postgres.NewUserStore(db),
+			Refresh: postgres.NewRefreshTokenStore(db),
+			Resets:  postgres.NewPasswordResetStore(db),
+			History: postgres.NewPasswordHistoryStore(db),
+		}, func() { db.Close() }, nil
+	case "sqlite":
+		db, err := sqlite.Open(ctx, cfg.SQLitePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &Stores{
+			Users:   sqlite.NewUserStore(db),
+			Refresh: sqlite.NewRefreshTokenStore(db),
+			Resets:  sqlite.NewPasswordResetStore(db),
+			History: sqlite.NewPasswordHistoryStore(db),
+		}, func() { db.Close() }, nil
+	default:
+		return &Stores{
+			Users:   memory.NewUserStore(),
+			Refresh: memory.NewRefreshTokenStore(),
+			Resets:  memory.NewPasswordResetStore(),
+			History: memory.NewPasswordHistoryStore(),
+		}, func() {}, nil
+	}
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type PasswordHistoryStore struct {
+	mu     sync.Mutex
+	hashes map[uuid.UUID][]string // newest last
+}
+
+func NewPasswordHistoryStore() *PasswordHistoryStore {
+	return &PasswordHistoryStore{hashes: make(map[uuid.UUID][]string)}
+}
+
+func (s *PasswordHistoryStore) Add(ctx context.Context, userID uuid.UUID, hash string, keep int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hashes := append(s.hashes[userID], hash)
+	if len(hashes) > keep {
+		hashes = append([]string(nil), hashes[len(hashes)-keep:]...)
+	}
+	s.hashes[userID] = hashes
+	return nil
+}
+
+func (s *PasswordHistoryStore) Recent(ctx context.Context, userID uuid.UUID, n int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hashes := s.hashes[userID]
+	recent := make([]string, 0, min(n, len(hashes)))
+	for i := len(hashes) - 1; i >= 0 && len(recent) < n; i-- {
+		recent = append(recent, hashes[i])
+	}
+	return recent, nil
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/internal/store/storetest"
+)
+
+func TestPasswordHistoryStore(t *testing.T) {
+	storetest.RunPasswordHistoryStoreSuite(t, func(t *testing.T) store.PasswordHistoryStore {
+		return NewPasswordHistoryStore()
+	})
+}
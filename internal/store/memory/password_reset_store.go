@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/google/uuid"
+)
+
+type PasswordResetStore struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*model.PasswordResetToken
+	byHash map[string]uuid.UUID
+}
+
+func NewPasswordResetStore() *PasswordResetStore {
+	return &PasswordResetStore{
+		tokens: make(map[uuid.UUID]*model.PasswordResetToken),
+		byHash: make(map[string]uuid.UUID),
+	}
+}
+
+func (s *PasswordResetStore) Create(ctx context.Context, t *model.PasswordResetToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now()
+	stored := *t
+	s.tokens[t.ID] = &stored
+	s.byHash[t.TokenHash] = t.ID
+	return nil
+}
+
+func (s *PasswordResetStore) GetByHash(ctx context.Context, hash string) (*model.PasswordResetToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.byHash[hash]; ok {
+		found := *s.tokens[id]
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (s *PasswordResetStore) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if t.UsedAt != nil {
+		return store.ErrResetTokenUsed
+	}
+	t.UsedAt = &at
+	return nil
+}
+
+func (s *PasswordResetStore) MarkUserUsed(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			t.UsedAt = &at
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/google/uuid"
+)
+
+type RefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[uuid.UUID]*model.RefreshToken
+	byHash map[string]uuid.UUID
+}
+
+func NewRefreshTokenStore() *RefreshTokenStore {
+	return &RefreshTokenStore{
+		tokens: make(map[uuid.UUID]*model.RefreshToken),
+		byHash: make(map[string]uuid.UUID),
+	}
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, t *model.RefreshToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now()
+	stored := *t
+	stored.AMR = slices.Clone(t.AMR)
+	s.tokens[t.ID] = &stored
+	s.byHash[t.TokenHash] = t.ID
+	return nil
+}
+
+func (s *RefreshTokenStore) GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.byHash[hash]; ok {
+		found := *s.tokens[id]
+		found.AMR = slices.Clone(found.AMR)
+		return &found, nil
+	}
+	return nil, nil
+}
+
+func (s *RefreshTokenStore) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if t.UsedAt != nil {
+		return store.ErrRefreshTokenUsed
+	}
+	t.UsedAt = &at
+	return nil
+}
+
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (s *RefreshTokenStore) RevokeUser(ctx context.Context, userID, keep uuid.UUID, at time.Time) ([]uuid.UUID, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[uuid.UUID]bool)
+	var families []uuid.UUID
+	for _, t := range s.tokens {
+		if t.UserID != userID || t.FamilyID == keep || t.RevokedAt != nil {
+			continue
+		}
+		t.RevokedAt = &at
+		if !seen[t.FamilyID] {
+			seen[t.FamilyID] = true
+			families = append(families, t.FamilyID)
+		}
+	}
+	return families, nil
+}
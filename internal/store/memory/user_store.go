@@ -2,39 +2,191 @@ package memory
 
 import (
 	"context"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
 	"github.com/google/uuid"
 )
 
 type UserStore struct {
-	mu    sync.RWMutex
-	users map[string]*model.User
+	mu      sync.RWMutex
+	users   map[uuid.UUID]*model.User
+	byEmail map[string]uuid.UUID // lower-cased email of live users
 }
 
 func NewUserStore() *UserStore {
-	return &UserStore{users: make(map[string]*model.User)}
+	return &UserStore{
+		users:   make(map[uuid.UUID]*model.User),
+		byEmail: make(map[string]uuid.UUID),
+	}
 }
 
-func (s *UserStore) Create(_ context.Context, u *model.User) error {
+func (s *UserStore) Create(ctx context.Context, u *model.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	key := strings.ToLower(u.Email)
+	if _, exists := s.byEmail[key]; exists {
+		return store.ErrDuplicateEmail
+	}
+
 	now := time.Now()
 	u.ID = uuid.New()
+	u.Version = 1
 	u.CreatedAt = now
 	u.UpdatedAt = now
-	s.users[u.Email] = u
+	u.DeletedAt = nil
+	s.users[u.ID] = clone(u)
+	s.byEmail[key] = u.ID
 	return nil
 }
 
-func (s *UserStore) GetByEmail(_ context.Context, email string) (*model.User, error) {
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if id, ok := s.byEmail[strings.ToLower(email)]; ok {
+		return clone(s.users[id]), nil
+	}
+	return nil, nil
+}
+
+func (s *UserStore) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	if u, ok := s.users[email]; ok {
-		return u, nil
+	if u, ok := s.users[id]; ok && u.DeletedAt == nil {
+		return clone(u), nil
 	}
 	return nil, nil
 }
+
+func (s *UserStore) Update(ctx context.Context, u *model.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.users[u.ID]
+	if !ok || current.DeletedAt != nil {
+		return store.ErrNotFound
+	}
+	if current.Version != u.Version {
+		return store.ErrConflict
+	}
+
+	oldKey, newKey := strings.ToLower(current.Email), strings.ToLower(u.Email)
+	if newKey != oldKey {
+		if _, taken := s.byEmail[newKey]; taken {
+			return store.ErrDuplicateEmail
+		}
+		delete(s.byEmail, oldKey)
+		s.byEmail[newKey] = u.ID
+	}
+
+	u.Version++
+	u.CreatedAt = current.CreatedAt
+	u.UpdatedAt = time.Now()
+	u.DeletedAt = nil
+	s.users[u.ID] = clone(u)
+	return nil
+}
+
+func (s *UserStore) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok || u.DeletedAt != nil {
+		return store.ErrNotFound
+	}
+
+	now := time.Now()
+	deleted := clone(u)
+	deleted.Version++
+	deleted.UpdatedAt = now
+	deleted.DeletedAt = &now
+	s.users[id] = deleted
+	delete(s.byEmail, strings.ToLower(u.Email))
+	return nil
+}
+
+func (s *UserStore) List(ctx context.Context, opts store.ListOptions) (*store.UserPage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	after, err := store.DecodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.ToLower(opts.EmailPrefix)
+	limit := opts.PageSize()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.byEmail))
+	for key := range s.byEmail {
+		if key > after && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	page := &store.UserPage{}
+	if len(keys) > limit {
+		keys = keys[:limit]
+		page.NextCursor = store.EncodeCursor(keys[limit-1])
+	}
+	page.Users = make([]*model.User, 0, len(keys))
+	for _, key := range keys {
+		page.Users = append(page.Users, clone(s.users[s.byEmail[key]]))
+	}
+	return page, nil
+}
+
+// clone copies u, including the values behind its pointer fields, so callers
+// and the store never share mutable state.
+func clone(u *model.User) *model.User {
+	c := *u
+	if u.DeletedAt != nil {
+		t := *u.DeletedAt
+		c.DeletedAt = &t
+	}
+	if u.LockedUntil != nil {
+		t := *u.LockedUntil
+		c.LockedUntil = &t
+	}
+	if u.EmailVerifiedAt != nil {
+		t := *u.EmailVerifiedAt
+		c.EmailVerifiedAt = &t
+	}
+	if u.TOTPEnabledAt != nil {
+		t := *u.TOTPEnabledAt
+		c.TOTPEnabledAt = &t
+	}
+	c.RecoveryCodes = slices.Clone(u.RecoveryCodes)
+	c.Scopes = slices.Clone(u.Scopes)
+	return &c
+}
@@ -0,0 +1,19 @@
+package store
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// PasswordHistoryStore remembers the hashes of passwords a user has
+// replaced, so they can be refused when chosen again.
+type PasswordHistoryStore interface {
+	// Add records hash as the user's most recent former password and forgets
+	// all but the keep most recent ones.
+	Add(ctx context.Context, userID uuid.UUID, hash string, keep int) error
+
+	// Recent returns up to n of the user's former password hashes, newest
+	// first.
+	Recent(ctx context.Context, userID uuid.UUID, n int) ([]string, error)
+}
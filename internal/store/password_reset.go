@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+)
+
+var ErrResetTokenUsed = errors.New("password reset token already used")
+
+// PasswordResetStore persists hashed password reset tokens. GetByHash
+// returns (nil, nil) when no token matches.
+type PasswordResetStore interface {
+	Create(ctx context.Context, token *model.PasswordResetToken) error
+	GetByHash(ctx context.Context, hash string) (*model.PasswordResetToken, error)
+
+	// MarkUsed consumes the token. Only one caller can win: every later call
+	// for the same token returns ErrResetTokenUsed.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+
+	// MarkUserUsed consumes every outstanding token of the user, so links
+	// mailed before a password change stop working.
+	MarkUserUsed(ctx context.Context, userID uuid.UUID, at time.Time) error
+}
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Soft-deleted users release their email, so uniqueness only applies to
+-- live rows. Byte-order collation keeps list pagination stable and lets the
+-- same index serve prefix filtering.
+DROP INDEX IF EXISTS users_email_lower_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_live_email_lower_key
+    ON users ((lower(email) COLLATE "C")) WHERE deleted_at IS NULL;
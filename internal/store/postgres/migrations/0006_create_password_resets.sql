@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+    id         UUID PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS password_resets_user_id_idx ON password_resets (user_id);
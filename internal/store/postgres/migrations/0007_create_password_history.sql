@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_history (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    UUID NOT NULL,
+    password   TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS password_history_user_id_idx ON password_history (user_id, id);
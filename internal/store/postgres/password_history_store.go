@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+type PasswordHistoryStore struct {
+	db *sql.DB
+}
+
+func NewPasswordHistoryStore(db *sql.DB) *PasswordHistoryStore {
+	return &PasswordHistoryStore{db: db}
+}
+
+func (s *PasswordHistoryStore) Add(ctx context.Context, userID uuid.UUID, hash string, keep int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO password_history (user_id, password, created_at) VALUES ($1, $2, $3)`,
+		userID, hash, now(),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM password_history
+		 WHERE user_id = $1 AND id NOT IN (
+		     SELECT id FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2)`,
+		userID, keep,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PasswordHistoryStore) Recent(ctx context.Context, userID uuid.UUID, n int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT password FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2`,
+		userID, n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var h string
+		if err := rows.Scan(&h); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, h)
+	}
+	return hashes, rows.Err()
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+)
+
+type PasswordResetStore struct {
+	db *sql.DB
+}
+
+func NewPasswordResetStore(db *sql.DB) *PasswordResetStore {
+	return &PasswordResetStore{db: db}
+}
+
+func (s *PasswordResetStore) Create(ctx context.Context, t *model.PasswordResetToken) error {
+	ts := now()
+	id := uuid.New()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO password_resets (id, user_id, token_hash, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		id, t.UserID, t.TokenHash, t.ExpiresAt.UTC(), ts,
+	)
+	if err != nil {
+		return err
+	}
+
+	t.ID = id
+	t.CreatedAt = ts
+	return nil
+}
+
+func (s *PasswordResetStore) GetByHash(ctx context.Context, hash string) (*model.PasswordResetToken, error) {
+	var t model.PasswordResetToken
+	var usedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, user_id, token_hash, expires_at, created_at, used_at
+		 FROM password_resets WHERE token_hash = $1`,
+		hash,
+	).Scan(&t.ID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.UsedAt = timePtr(usedAt)
+	return &t, nil
+}
+
+func (s *PasswordResetStore) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE password_resets SET used_at = $1 WHERE id = $2 AND used_at IS NULL`,
+		at.UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrResetTokenUsed
+	}
+	return nil
+}
+
+func (s *PasswordResetStore) MarkUserUsed(ctx context.Context, userID uuid.UUID, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE password_resets SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`,
+		at.UTC(), userID,
+	)
+	return err
+}
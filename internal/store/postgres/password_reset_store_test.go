@@ -0,0 +1,14 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/internal/store/storetest"
+)
+
+func TestPasswordResetStore(t *testing.T) {
+	storetest.RunPasswordResetStoreSuite(t, func(t *testing.T) store.PasswordResetStore {
+		return NewPasswordResetStore(newTestStore(t).db)
+	})
+}
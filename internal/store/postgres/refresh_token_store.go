@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+)
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenStore(db *sql.DB) *RefreshTokenStore {
+	return &RefreshTokenStore{db: db}
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, t *model.RefreshToken) error {
+	ts := now()
+	id := uuid.New()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (id, family_id, user_id, token_hash, expires_at, created_at, amr)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		id, t.FamilyID, t.UserID, t.TokenHash, t.ExpiresAt.UTC(), ts, strings.Join(t.AMR, " "),
+	)
+	if err != nil {
+		return err
+	}
+
+	t.ID = id
+	t.CreatedAt = ts
+	return nil
+}
+
+func (s *RefreshTokenStore) GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	var t model.RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	var amr string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, family_id, user_id, token_hash, expires_at, created_at, used_at, revoked_at, amr
+		 FROM refresh_tokens WHERE token_hash = $1`,
+		hash,
+	).Scan(&t.ID, &t.FamilyID, &t.UserID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &usedAt, &revokedAt, &amr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.UsedAt = timePtr(usedAt)
+	t.RevokedAt = timePtr(revokedAt)
+	t.AMR = strings.Fields(amr)
+	return &t, nil
+}
+
+func (s *RefreshTokenStore) MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error {
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`,
+		at.UTC(), id,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrRefreshTokenUsed
+	}
+	return nil
+}
+
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`,
+		at.UTC(), familyID,
+	)
+	return err
+}
+
+func (s *RefreshTokenStore) RevokeUser(ctx context.Context, userID, keep uuid.UUID, at time.Time) ([]uuid.UUID, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = $1
+		 WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL
+		 RETURNING family_id`,
+		at.UTC(), userID, keep,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[uuid.UUID]bool)
+	var families []uuid.UUID
+	for rows.Next() {
+		var family uuid.UUID
+		if err := rows.Scan(&family); err != nil {
+			return nil, err
+		}
+		if !seen[family] {
+			seen[family] = true
+			families = append(families, family)
+		}
+	}
+	return families, rows.Err()
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+)
+
+var ErrRefreshTokenUsed = errors.New("refresh token already used")
+
+// RefreshTokenStore persists hashed refresh tokens. GetByHash returns
+// (nil, nil) when no token matches.
+type RefreshTokenStore interface {
+	Create(ctx context.Context, token *model.RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*model.RefreshToken, error)
+
+	// MarkUsed flags the token as exchanged. Only one caller can win: every
+	// later call for the same token returns ErrRefreshTokenUsed.
+	MarkUsed(ctx context.Context, id uuid.UUID, at time.Time) error
+
+	// RevokeFamily revokes every token descended from the same signin.
+	RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) error
+
+	// RevokeUser revokes every live token of the user outside the family
+	// keep, which may be uuid.Nil, and returns the families that were still
+	// active.
+	RevokeUser(ctx context.Context, userID, keep uuid.UUID, at time.Time) ([]uuid.UUID, error)
+}
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+    id         TEXT PRIMARY KEY,
+    email      TEXT NOT NULL,
+    password   TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+-- Emails are compared case-insensitively, so uniqueness is enforced on the
+-- normalized form rather than the stored value.
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_key ON users (lower(email));
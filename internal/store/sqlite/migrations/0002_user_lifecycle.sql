@@ -0,0 +1,8 @@
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;
+
+-- Soft-deleted users release their email, so uniqueness only applies to
+-- live rows. The same index serves ordered, prefix-filtered listing.
+DROP INDEX IF EXISTS users_email_lower_key;
+CREATE UNIQUE INDEX IF NOT EXISTS users_live_email_lower_key
+    ON users (lower(email)) WHERE deleted_at IS NULL;
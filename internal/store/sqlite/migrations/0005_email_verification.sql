@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME;
+
+-- Accounts created before verification existed are grandfathered in.
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
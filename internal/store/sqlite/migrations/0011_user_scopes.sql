@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/internal/store/storetest"
+)
+
+func TestRefreshTokenStore(t *testing.T) {
+	storetest.RunRefreshTokenStoreSuite(t, func(t *testing.T) store.RefreshTokenStore {
+		return NewRefreshTokenStore(newTestStore(t).db)
+	})
+}
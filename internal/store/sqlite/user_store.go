@@ -0,0 +1,215 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+)
+
+const userColumns = `id, email, password, version, created_at, updated_at, locked_until, email_verified_at, pending_email,
+	totp_secret, totp_enabled_at, totp_last_step, recovery_codes, scopes`
+
+type UserStore struct {
+	db *sql.DB
+}
+
+func NewUserStore(db *sql.DB) *UserStore {
+	return &UserStore{db: db}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row scanner) (*model.User, error) {
+	var u model.User
+	var lockedUntil, emailVerifiedAt, totpEnabledAt sql.NullTime
+	var recoveryCodes, scopes string
+	err := row.Scan(&u.ID, &u.Email, &u.Password, &u.Version, &u.CreatedAt, &u.UpdatedAt, &lockedUntil, &emailVerifiedAt, &u.PendingEmail,
+		&u.TOTPSecret, &totpEnabledAt, &u.TOTPLastStep, &recoveryCodes, &scopes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.LockedUntil = timePtr(lockedUntil)
+	u.EmailVerifiedAt = timePtr(emailVerifiedAt)
+	u.TOTPEnabledAt = timePtr(totpEnabledAt)
+	u.RecoveryCodes = strings.Fields(recoveryCodes)
+	u.Scopes = strings.Fields(scopes)
+	return &u, nil
+}
+
+func mapError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return store.ErrDuplicateEmail
+	}
+	return err
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func (s *UserStore) Create(ctx context.Context, u *model.User) error {
+	ts := now()
+	id := uuid.New()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO users (id, email, password, version, created_at, updated_at, locked_until, email_verified_at, pending_email,
+		 totp_secret, totp_enabled_at, totp_last_step, recovery_codes, scopes)
+		 VALUES (?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.String(), u.Email, u.Password, ts, ts, nullTime(u.LockedUntil), nullTime(u.EmailVerifiedAt), u.PendingEmail,
+		u.TOTPSecret, nullTime(u.TOTPEnabledAt), u.TOTPLastStep, strings.Join(u.RecoveryCodes, " "), strings.Join(u.Scopes, " "),
+	)
+	if err != nil {
+		return mapError(err)
+	}
+
+	u.ID = id
+	u.Version = 1
+	u.CreatedAt = ts
+	u.UpdatedAt = ts
+	u.DeletedAt = nil
+	return nil
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users
+		 WHERE lower(email) = lower(?) AND deleted_at IS NULL`,
+		email,
+	))
+}
+
+func (s *UserStore) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	return scanUser(s.db.QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users
+		 WHERE id = ? AND deleted_at IS NULL`,
+		id.String(),
+	))
+}
+
+func (s *UserStore) Update(ctx context.Context, u *model.User) error {
+	ts := now()
+
+	var createdAt time.Time
+	err := s.db.QueryRowContext(ctx,
+		`UPDATE users SET email = ?, password = ?, locked_until = ?, email_verified_at = ?,
+		 pending_email = ?, totp_secret = ?, totp_enabled_at = ?, totp_last_step = ?,
+		 recovery_codes = ?, scopes = ?, version = version + 1, updated_at = ?
+		 WHERE id = ? AND version = ? AND deleted_at IS NULL
+		 RETURNING created_at`,
+		u.Email, u.Password, nullTime(u.LockedUntil), nullTime(u.EmailVerifiedAt), u.PendingEmail,
+		u.TOTPSecret, nullTime(u.TOTPEnabledAt), u.TOTPLastStep, strings.Join(u.RecoveryCodes, " "), strings.Join(u.Scopes, " "), ts, u.ID.String(), u.Version,
+	).Scan(&createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.missOrConflict(ctx, u.ID)
+	}
+	if err != nil {
+		return mapError(err)
+	}
+
+	u.Version++
+	u.CreatedAt = createdAt
+	u.UpdatedAt = ts
+	return nil
+}
+
+// nullTime converts an optional timestamp to a query argument.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// missOrConflict explains why a versioned write matched no rows.
+func (s *UserStore) missOrConflict(ctx context.Context, id uuid.UUID) error {
+	existing, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return store.ErrNotFound
+	}
+	return store.ErrConflict
+}
+
+func (s *UserStore) Delete(ctx context.Context, id uuid.UUID) error {
+	ts := now()
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE users SET deleted_at = ?, updated_at = ?, version = version + 1
+		 WHERE id = ? AND deleted_at IS NULL`,
+		ts, ts, id.String(),
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *UserStore) List(ctx context.Context, opts store.ListOptions) (*store.UserPage, error) {
+	after, err := store.DecodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := opts.PageSize()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+userColumns+` FROM users
+		 WHERE deleted_at IS NULL
+		   AND lower(email) > ?
+		   AND lower(email) LIKE ? ESCAPE '\'
+		 ORDER BY lower(email)
+		 LIMIT ?`,
+		after, likePrefix(opts.EmailPrefix), limit+1,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &store.UserPage{Users: make([]*model.User, 0, limit)}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Users = append(page.Users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Users) > limit {
+		page.Users = page.Users[:limit]
+		page.NextCursor = store.EncodeCursor(strings.ToLower(page.Users[limit-1].Email))
+	}
+	return page, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// likePrefix builds a LIKE pattern matching values that start with prefix.
+func likePrefix(prefix string) string {
+	return likeEscaper.Replace(strings.ToLower(prefix)) + "%"
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+	"github.com/coinbase/identity-service/internal/store/storetest"
+)
+
+func newTestStore(t *testing.T) *UserStore {
+	t.Helper()
+
+	db, err := Open(context.Background(), filepath.Join(t.TempDir(), "identity.db"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewUserStore(db)
+}
+
+func TestUserStore(t *testing.T) {
+	storetest.RunUserStoreSuite(t, func(t *testing.T) store.UserStore {
+		return newTestStore(t)
+	})
+}
+
+func TestOpen_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity.db")
+	ctx := context.Background()
+
+	db, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if err := NewUserStore(db).Create(ctx, &model.User{Email: "test@example.com", Password: "hashedpassword"}); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	db.Close()
+
+	// Reopening re-runs migrations, which must be a no-op on an existing schema.
+	db, err = Open(ctx, path)
+	if err != nil {
+		t.Fatalf("second Open() failed: %v", err)
+	}
+	defer db.Close()
+
+	var mode string
+	if err := db.QueryRowContext(ctx, `PRAGMA journal_mode`).Scan(&mode); err != nil {
+		t.Fatalf("PRAGMA journal_mode failed: %v", err)
+	}
+	if mode != "wal" {
+		t.Errorf("Expected journal_mode wal, got %s", mode)
+	}
+
+	user, err := NewUserStore(db).GetByEmail(ctx, "test@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	if user == nil {
+		t.Fatal("user should survive reopening the database")
+	}
+}
@@ -2,11 +2,79 @@ package store
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
+
+	"github.com/google/uuid"
 
 	"github.com/coinbase/identity-service/internal/model"
 )
 
+var (
+	ErrDuplicateEmail = errors.New("email already registered")
+	ErrNotFound       = errors.New("user not found")
+	ErrConflict       = errors.New("user was modified concurrently")
+	ErrInvalidCursor  = errors.New("invalid cursor")
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 200
+)
+
+// UserStore persists users. Lookups return (nil, nil) when no live user
+// matches; soft-deleted users are invisible to every method.
 type UserStore interface {
 	Create(ctx context.Context, user *model.User) error
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
+
+	// Update saves user if user.Version still matches the stored version,
+	// then bumps Version and UpdatedAt on user. It returns ErrConflict when
+	// the user changed since it was read and ErrNotFound when it is gone.
+	Update(ctx context.Context, user *model.User) error
+
+	// Delete soft-deletes the user, releasing its email for reuse.
+	Delete(ctx context.Context, id uuid.UUID) error
+
+	// List returns live users ordered by email, one page at a time.
+	List(ctx context.Context, opts ListOptions) (*UserPage, error)
+}
+
+type ListOptions struct {
+	EmailPrefix string // case-insensitive
+	Cursor      string // NextCursor of the previous page, empty for the first
+	Limit       int    // DefaultListLimit when zero, capped at MaxListLimit
+}
+
+type UserPage struct {
+	Users      []*model.User
+	NextCursor string // empty on the last page
+}
+
+// PageSize returns the effective page size for opts.
+func (o ListOptions) PageSize() int {
+	switch {
+	case o.Limit <= 0:
+		return DefaultListLimit
+	case o.Limit > MaxListLimit:
+		return MaxListLimit
+	default:
+		return o.Limit
+	}
+}
+
+// EncodeCursor returns an opaque cursor positioned after the given
+// lower-cased email.
+func EncodeCursor(email string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(email))
+}
+
+// DecodeCursor reverses EncodeCursor. An empty cursor decodes to "".
+func DecodeCursor(cursor string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	return string(b), nil
 }
@@ -0,0 +1,92 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/store"
+)
+
+// PasswordHistoryFactory returns an empty store for a single subtest.
+type PasswordHistoryFactory func(t *testing.T) store.PasswordHistoryStore
+
+// RunPasswordHistoryStoreSuite runs the PasswordHistoryStore contract
+// against stores produced by newStore, one fresh store per subtest.
+func RunPasswordHistoryStoreSuite(t *testing.T, newStore PasswordHistoryFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.PasswordHistoryStore)
+	}{
+		{"RecentNewestFirst", testHistoryRecentNewestFirst},
+		{"AddKeepsLimit", testHistoryAddKeepsLimit},
+		{"RecentEmpty", testHistoryRecentEmpty},
+		{"CanceledContext", testHistoryCanceledContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func mustAddHistory(t *testing.T, s store.PasswordHistoryStore, userID uuid.UUID, keep int, hashes ...string) {
+	t.Helper()
+	for _, h := range hashes {
+		if err := s.Add(context.Background(), userID, h, keep); err != nil {
+			t.Fatalf("Add(%s) failed: %v", h, err)
+		}
+	}
+}
+
+func testHistoryRecentNewestFirst(t *testing.T, s store.PasswordHistoryStore) {
+	userID, other := uuid.New(), uuid.New()
+	mustAddHistory(t, s, userID, 10, "hash-1", "hash-2", "hash-3")
+	mustAddHistory(t, s, other, 10, "other-1")
+
+	got, err := s.Recent(context.Background(), userID, 2)
+	if err != nil {
+		t.Fatalf("Recent() failed: %v", err)
+	}
+	if want := []string{"hash-3", "hash-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Recent() = %v, want %v", got, want)
+	}
+}
+
+func testHistoryAddKeepsLimit(t *testing.T, s store.PasswordHistoryStore) {
+	userID := uuid.New()
+	mustAddHistory(t, s, userID, 2, "hash-1", "hash-2", "hash-3")
+
+	got, err := s.Recent(context.Background(), userID, 10)
+	if err != nil {
+		t.Fatalf("Recent() failed: %v", err)
+	}
+	if want := []string{"hash-3", "hash-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Recent() = %v, want %v", got, want)
+	}
+}
+
+func testHistoryRecentEmpty(t *testing.T, s store.PasswordHistoryStore) {
+	got, err := s.Recent(context.Background(), uuid.New(), 5)
+	if err != nil {
+		t.Fatalf("Recent() failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Recent() = %v, want none", got)
+	}
+}
+
+func testHistoryCanceledContext(t *testing.T, s store.PasswordHistoryStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Add(ctx, uuid.New(), "hash-1", 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("Add() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.Recent(ctx, uuid.New(), 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("Recent() with canceled context: expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+)
+
+// PasswordResetFactory returns an empty store for a single subtest.
+type PasswordResetFactory func(t *testing.T) store.PasswordResetStore
+
+// RunPasswordResetStoreSuite runs the PasswordResetStore contract against
+// stores produced by newStore, one fresh store per subtest.
+func RunPasswordResetStoreSuite(t *testing.T, newStore PasswordResetFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.PasswordResetStore)
+	}{
+		{"CreateAndGetByHash", testResetCreateAndGet},
+		{"GetByHashNotFound", testResetGetNotFound},
+		{"MarkUsedOnce", testResetMarkUsedOnce},
+		{"ConcurrentMarkUsed", testResetConcurrentMarkUsed},
+		{"MarkUserUsed", testResetMarkUserUsed},
+		{"CanceledContext", testResetCanceledContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func mustCreateReset(t *testing.T, s store.PasswordResetStore, userID uuid.UUID, hash string) *model.PasswordResetToken {
+	t.Helper()
+	rt := &model.PasswordResetToken{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: now().Add(time.Hour),
+	}
+	if err := s.Create(context.Background(), rt); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	return rt
+}
+
+func testResetCreateAndGet(t *testing.T, s store.PasswordResetStore) {
+	rt := mustCreateReset(t, s, uuid.New(), "hash-1")
+	if rt.ID == uuid.Nil {
+		t.Error("Create() should set ID")
+	}
+	if rt.CreatedAt.IsZero() {
+		t.Error("Create() should set CreatedAt")
+	}
+
+	got, err := s.GetByHash(context.Background(), "hash-1")
+	if err != nil {
+		t.Fatalf("GetByHash() failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("GetByHash() returned nil")
+	}
+	if got.ID != rt.ID || got.UserID != rt.UserID {
+		t.Errorf("GetByHash() = %+v, want %+v", got, rt)
+	}
+	if !got.ExpiresAt.Equal(rt.ExpiresAt) {
+		t.Errorf("Expected ExpiresAt %v, got %v", rt.ExpiresAt, got.ExpiresAt)
+	}
+	if got.UsedAt != nil {
+		t.Error("new token should not be used")
+	}
+}
+
+func testResetGetNotFound(t *testing.T, s store.PasswordResetStore) {
+	got, err := s.GetByHash(context.Background(), "missing")
+	if err != nil {
+		t.Fatalf("GetByHash() failed: %v", err)
+	}
+	if got != nil {
+		t.Error("GetByHash() should return nil for unknown hash")
+	}
+}
+
+func testResetMarkUsedOnce(t *testing.T, s store.PasswordResetStore) {
+	ctx := context.Background()
+	rt := mustCreateReset(t, s, uuid.New(), "hash-1")
+	at := now()
+
+	if err := s.MarkUsed(ctx, rt.ID, at); err != nil {
+		t.Fatalf("MarkUsed() failed: %v", err)
+	}
+	if err := s.MarkUsed(ctx, rt.ID, at); !errors.Is(err, store.ErrResetTokenUsed) {
+		t.Fatalf("second MarkUsed(): expected ErrResetTokenUsed, got %v", err)
+	}
+
+	got, _ := s.GetByHash(ctx, "hash-1")
+	if got.UsedAt == nil || !got.UsedAt.Equal(at) {
+		t.Errorf("Expected UsedAt %v, got %v", at, got.UsedAt)
+	}
+}
+
+func testResetConcurrentMarkUsed(t *testing.T, s store.PasswordResetStore) {
+	rt := mustCreateReset(t, s, uuid.New(), "hash-1")
+
+	const n = 20
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.MarkUsed(context.Background(), rt.ID, now())
+		}(i)
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case errors.Is(err, store.ErrResetTokenUsed):
+		default:
+			t.Errorf("unexpected MarkUsed() error: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("Expected exactly one MarkUsed() to succeed, got %d", won)
+	}
+}
+
+func testResetMarkUserUsed(t *testing.T, s store.PasswordResetStore) {
+	ctx := context.Background()
+	userID, otherUser := uuid.New(), uuid.New()
+	used := mustCreateReset(t, s, userID, "hash-1")
+	mustCreateReset(t, s, userID, "hash-2")
+	mustCreateReset(t, s, otherUser, "hash-3")
+
+	earlier := now().Add(-time.Minute)
+	if err := s.MarkUsed(ctx, used.ID, earlier); err != nil {
+		t.Fatalf("MarkUsed() failed: %v", err)
+	}
+	if err := s.MarkUserUsed(ctx, userID, now()); err != nil {
+		t.Fatalf("MarkUserUsed() failed: %v", err)
+	}
+
+	for hash, wantUsed := range map[string]bool{"hash-1": true, "hash-2": true, "hash-3": false} {
+		got, err := s.GetByHash(ctx, hash)
+		if err != nil {
+			t.Fatalf("GetByHash() failed: %v", err)
+		}
+		if (got.UsedAt != nil) != wantUsed {
+			t.Errorf("%s: used = %v, want %v", hash, got.UsedAt != nil, wantUsed)
+		}
+	}
+	got, _ := s.GetByHash(ctx, "hash-1")
+	if !got.UsedAt.Equal(earlier) {
+		t.Errorf("MarkUserUsed() overwrote UsedAt of a used token: %v", got.UsedAt)
+	}
+}
+
+func testResetCanceledContext(t *testing.T, s store.PasswordResetStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rt := &model.PasswordResetToken{UserID: uuid.New(), TokenHash: "hash-1", ExpiresAt: now()}
+	if err := s.Create(ctx, rt); !errors.Is(err, context.Canceled) {
+		t.Errorf("Create() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.GetByHash(ctx, "hash-1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetByHash() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if err := s.MarkUsed(ctx, uuid.New(), now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("MarkUsed() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if err := s.MarkUserUsed(ctx, uuid.New(), now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("MarkUserUsed() with canceled context: expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+)
+
+// RefreshTokenFactory returns an empty store for a single subtest.
+type RefreshTokenFactory func(t *testing.T) store.RefreshTokenStore
+
+// RunRefreshTokenStoreSuite runs the RefreshTokenStore contract against
+// stores produced by newStore, one fresh store per subtest.
+func RunRefreshTokenStoreSuite(t *testing.T, newStore RefreshTokenFactory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.RefreshTokenStore)
+	}{
+		{"CreateAndGetByHash", testRefreshCreateAndGet},
+		{"AMR", testRefreshAMR},
+		{"GetByHashNotFound", testRefreshGetNotFound},
+		{"MarkUsedOnce", testRefreshMarkUsedOnce},
+		{"ConcurrentMarkUsed", testRefreshConcurrentMarkUsed},
+		{"RevokeFamily", testRefreshRevokeFamily},
+		{"RevokeUser", testRefreshRevokeUser},
+		{"RevokeUserKeepsFamily", testRefreshRevokeUserKeep},
+		{"CanceledContext", testRefreshCanceledContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+// now returns a timestamp every store can round-trip exactly.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func mustCreateRefresh(t *testing.T, s store.RefreshTokenStore, familyID uuid.UUID, hash string) *model.RefreshToken {
+	t.Helper()
+	rt := &model.RefreshToken{
+		FamilyID:  familyID,
+		UserID:    uuid.New(),
+		TokenHash: hash,
+		ExpiresAt: now().Add(time.Hour),
+	}
+	if err := s.Create(context.Background(), rt); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	return rt
+}
+
+func testRefreshCreateAndGet(t *testing.T, s store.RefreshTokenStore) {
+	rt := mustCreateRefresh(t, s, uuid.New(), "hash-1")
+	if rt.ID == uuid.Nil {
+		t.Error("Create() should set ID")
+	}
+	if rt.CreatedAt.IsZero() {
+		t.Error("Create() should set CreatedAt")
+	}
+
+	got, err := s.GetByHash(context.Background(), "hash-1")
+	if err != nil {
+		t.Fatalf("GetByHash() failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("GetByHash() returned nil")
+	}
+	if got.ID != rt.ID || got.FamilyID != rt.FamilyID || got.UserID != rt.UserID {
+		t.Errorf("GetByHash() = %+v, want %+v", got, rt)
+	}
+	if !got.ExpiresAt.Equal(rt.ExpiresAt) {
+		t.Errorf("Expected ExpiresAt %v, got %v", rt.ExpiresAt, got.ExpiresAt)
+	}
+	if got.UsedAt != nil || got.RevokedAt != nil {
+		t.Error("new token should be neither used nor revoked")
+	}
+}
+
+func testRefreshAMR(t *testing.T, s store.RefreshTokenStore) {
+	ctx := context.Background()
+	rt := &model.RefreshToken{
+		FamilyID:  uuid.New(),
+		UserID:    uuid.New(),
+		TokenHash: "hash-amr",
+		ExpiresAt: now().Add(time.Hour),
+		AMR:       []string{"pwd", "otp", "mfa"},
+	}
+	if err := s.Create(ctx, rt); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	got, _ := s.GetByHash(ctx, "hash-amr")
+	if !slices.Equal(got.AMR, rt.AMR) {
+		t.Errorf("Expected AMR %v, got %v", rt.AMR, got.AMR)
+	}
+
+	mustCreateRefresh(t, s, uuid.New(), "hash-none")
+	if got, _ := s.GetByHash(ctx, "hash-none"); len(got.AMR) != 0 {
+		t.Errorf("Expected no AMR, got %v", got.AMR)
+	}
+}
+
+func testRefreshGetNotFound(t *testing.T, s store.RefreshTokenStore) {
+	got, err := s.GetByHash(context.Background(), "missing")
+	if err != nil {
+		t.Fatalf("GetByHash() failed: %v", err)
+	}
+	if got != nil {
+		t.Error("GetByHash() should return nil for unknown hash")
+	}
+}
+
+func testRefreshMarkUsedOnce(t *testing.T, s store.RefreshTokenStore) {
+	ctx := context.Background()
+	rt := mustCreateRefresh(t, s, uuid.New(), "hash-1")
+	at := now()
+
+	if err := s.MarkUsed(ctx, rt.ID, at); err != nil {
+		t.Fatalf("MarkUsed() failed: %v", err)
+	}
+	if err := s.MarkUsed(ctx, rt.ID, at); !errors.Is(err, store.ErrRefreshTokenUsed) {
+		t.Fatalf("second MarkUsed(): expected ErrRefreshTokenUsed, got %v", err)
+	}
+
+	got, _ := s.GetByHash(ctx, "hash-1")
+	if got.UsedAt == nil || !got.UsedAt.Equal(at) {
+		t.Errorf("Expected UsedAt %v, got %v", at, got.UsedAt)
+	}
+}
+
+func testRefreshConcurrentMarkUsed(t *testing.T, s store.RefreshTokenStore) {
+	rt := mustCreateRefresh(t, s, uuid.New(), "hash-1")
+
+	const n = 20
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.MarkUsed(context.Background(), rt.ID, now())
+		}(i)
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case errors.Is(err, store.ErrRefreshTokenUsed):
+		default:
+			t.Errorf("unexpected MarkUsed() error: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Errorf("Expected exactly one MarkUsed() to succeed, got %d", won)
+	}
+}
+
+func testRefreshRevokeFamily(t *testing.T, s store.RefreshTokenStore) {
+	ctx := context.Background()
+	family, other := uuid.New(), uuid.New()
+	mustCreateRefresh(t, s, family, "hash-1")
+	mustCreateRefresh(t, s, family, "hash-2")
+	mustCreateRefresh(t, s, other, "hash-3")
+
+	if err := s.RevokeFamily(ctx, family, now()); err != nil {
+		t.Fatalf("RevokeFamily() failed: %v", err)
+	}
+
+	for hash, wantRevoked := range map[string]bool{"hash-1": true, "hash-2": true, "hash-3": false} {
+		got, err := s.GetByHash(ctx, hash)
+		if err != nil {
+			t.Fatalf("GetByHash() failed: %v", err)
+		}
+		if (got.RevokedAt != nil) != wantRevoked {
+			t.Errorf("%s: revoked = %v, want %v", hash, got.RevokedAt != nil, wantRevoked)
+		}
+	}
+}
+
+func testRefreshRevokeUser(t *testing.T, s store.RefreshTokenStore) {
+	ctx := context.Background()
+	userID, otherUser := uuid.New(), uuid.New()
+	familyA, familyB, revokedFamily := uuid.New(), uuid.New(), uuid.New()
+
+	for i, rt := range []*model.RefreshToken{
+		{FamilyID: familyA, UserID: userID, TokenHash: "hash-1"},
+		{FamilyID: familyA, UserID: userID, TokenHash: "hash-2"},
+		{FamilyID: familyB, UserID: userID, TokenHash: "hash-3"},
+		{FamilyID: revokedFamily, UserID: userID, TokenHash: "hash-4"},
+		{FamilyID: uuid.New(), UserID: otherUser, TokenHash: "hash-5"},
+	} {
+		rt.ExpiresAt = now().Add(time.Hour)
+		if err := s.Create(ctx, rt); err != nil {
+			t.Fatalf("Create(%d) failed: %v", i, err)
+		}
+	}
+	if err := s.RevokeFamily(ctx, revokedFamily, now()); err != nil {
+		t.Fatalf("RevokeFamily() failed: %v", err)
+	}
+
+	families, err := s.RevokeUser(ctx, userID, uuid.Nil, now())
+	if err != nil {
+		t.Fatalf("RevokeUser() failed: %v", err)
+	}
+	got := map[uuid.UUID]bool{}
+	for _, f := range families {
+		if got[f] {
+			t.Errorf("RevokeUser() returned family %s twice", f)
+		}
+		got[f] = true
+	}
+	if len(got) != 2 || !got[familyA] || !got[familyB] {
+		t.Errorf("RevokeUser() = %v, want [%s %s]", families, familyA, familyB)
+	}
+
+	for hash, wantRevoked := range map[string]bool{"hash-1": true, "hash-2": true, "hash-3": true, "hash-5": false} {
+		rt, _ := s.GetByHash(ctx, hash)
+		if (rt.RevokedAt != nil) != wantRevoked {
+			t.Errorf("%s: revoked = %v, want %v", hash, rt.RevokedAt != nil, wantRevoked)
+		}
+	}
+}
+
+func testRefreshRevokeUserKeep(t *testing.T, s store.RefreshTokenStore) {
+	ctx := context.Background()
+	userID := uuid.New()
+	keep, other := uuid.New(), uuid.New()
+	for i, rt := range []*model.RefreshToken{
+		{FamilyID: keep, UserID: userID, TokenHash: "hash-1"},
+		{FamilyID: other, UserID: userID, TokenHash: "hash-2"},
+	} {
+		rt.ExpiresAt = now().Add(time.Hour)
+		if err := s.Create(ctx, rt); err != nil {
+			t.Fatalf("Create(%d) failed: %v", i, err)
+		}
+	}
+
+	families, err := s.RevokeUser(ctx, userID, keep, now())
+	if err != nil {
+		t.Fatalf("RevokeUser() failed: %v", err)
+	}
+	if len(families) != 1 || families[0] != other {
+		t.Errorf("RevokeUser() = %v, want [%s]", families, other)
+	}
+	for hash, wantRevoked := range map[string]bool{"hash-1": false, "hash-2": true} {
+		rt, _ := s.GetByHash(ctx, hash)
+		if (rt.RevokedAt != nil) != wantRevoked {
+			t.Errorf("%s: revoked = %v, want %v", hash, rt.RevokedAt != nil, wantRevoked)
+		}
+	}
+}
+
+func testRefreshCanceledContext(t *testing.T, s store.RefreshTokenStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rt := &model.RefreshToken{FamilyID: uuid.New(), UserID: uuid.New(), TokenHash: "hash-1", ExpiresAt: now()}
+	if err := s.Create(ctx, rt); !errors.Is(err, context.Canceled) {
+		t.Errorf("Create() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.GetByHash(ctx, "hash-1"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetByHash() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if err := s.MarkUsed(ctx, uuid.New(), now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("MarkUsed() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if err := s.RevokeFamily(ctx, uuid.New(), now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("RevokeFamily() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.RevokeUser(ctx, uuid.New(), uuid.Nil, now()); !errors.Is(err, context.Canceled) {
+		t.Errorf("RevokeUser() with canceled context: expected context.Canceled, got %v", err)
+	}
+}
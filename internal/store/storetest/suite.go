@@ -0,0 +1,752 @@
+// Package storetest provides conformance tests shared by every
+// store.UserStore implementation.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/coinbase/identity-service/internal/model"
+	"github.com/coinbase/identity-service/internal/store"
+)
+
+// Factory returns an empty store for a single subtest. Implementations
+// should register any cleanup with t.Cleanup.
+type Factory func(t *testing.T) store.UserStore
+
+// RunUserStoreSuite runs the UserStore contract against stores produced by
+// newStore, one fresh store per subtest.
+func RunUserStoreSuite(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.UserStore)
+	}{
+		{"CreateAssignsIDAndTimestamps", testCreateAssignsIDAndTimestamps},
+		{"GetByEmail", testGetByEmail},
+		{"GetByEmailCaseInsensitive", testGetByEmailCaseInsensitive},
+		{"GetByEmailNotFound", testGetByEmailNotFound},
+		{"GetByEmailReturnsCopy", testGetByEmailReturnsCopy},
+		{"CreateDuplicate", testCreateDuplicate},
+		{"CreateDuplicateDifferentCase", testCreateDuplicateDifferentCase},
+		{"GetByID", testGetByID},
+		{"GetByIDNotFound", testGetByIDNotFound},
+		{"Update", testUpdate},
+		{"UpdateStaleVersion", testUpdateStaleVersion},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"UpdateDuplicateEmail", testUpdateDuplicateEmail},
+		{"UpdateLockedUntil", testUpdateLockedUntil},
+		{"UpdateEmailVerifiedAt", testUpdateEmailVerifiedAt},
+		{"UpdatePendingEmail", testUpdatePendingEmail},
+		{"ConcurrentEmailChange", testConcurrentEmailChange},
+		{"UpdateTOTP", testUpdateTOTP},
+		{"UpdateRecoveryCodes", testUpdateRecoveryCodes},
+		{"UpdateScopes", testUpdateScopes},
+		{"Delete", testDelete},
+		{"DeleteNotFound", testDeleteNotFound},
+		{"ListPagination", testListPagination},
+		{"ListEmailPrefix", testListEmailPrefix},
+		{"ListInvalidCursor", testListInvalidCursor},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ConcurrentDuplicateCreates", testConcurrentDuplicateCreates},
+		{"CanceledContext", testCanceledContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func mustCreate(t *testing.T, s store.UserStore, email, password string) *model.User {
+	t.Helper()
+	u := &model.User{Email: email, Password: password}
+	if err := s.Create(context.Background(), u); err != nil {
+		t.Fatalf("Create(%s) failed: %v", email, err)
+	}
+	return u
+}
+
+func testCreateAssignsIDAndTimestamps(t *testing.T, s store.UserStore) {
+	u1 := mustCreate(t, s, "one@example.com", "hashedpassword")
+	u2 := mustCreate(t, s, "two@example.com", "hashedpassword")
+
+	if u1.ID == uuid.Nil {
+		t.Error("Create() should set user ID")
+	}
+	if u1.ID == u2.ID {
+		t.Error("Create() should assign distinct IDs")
+	}
+	if u1.Version != 1 {
+		t.Errorf("Create() should set Version 1, got %d", u1.Version)
+	}
+	if u1.CreatedAt.IsZero() {
+		t.Error("Create() should set CreatedAt")
+	}
+	if u1.UpdatedAt.IsZero() {
+		t.Error("Create() should set UpdatedAt")
+	}
+	if !u1.UpdatedAt.Equal(u1.CreatedAt) {
+		t.Errorf("UpdatedAt %v should equal CreatedAt %v on create", u1.UpdatedAt, u1.CreatedAt)
+	}
+}
+
+func testGetByEmail(t *testing.T, s store.UserStore) {
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+
+	got, err := s.GetByEmail(context.Background(), u.Email)
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("GetByEmail() returned nil user")
+	}
+	if got.ID != u.ID {
+		t.Errorf("Expected ID %s, got %s", u.ID, got.ID)
+	}
+	if got.Email != u.Email {
+		t.Errorf("Expected email %s, got %s", u.Email, got.Email)
+	}
+	if got.Password != u.Password {
+		t.Errorf("Expected password %s, got %s", u.Password, got.Password)
+	}
+	if got.Version != u.Version {
+		t.Errorf("Expected Version %d, got %d", u.Version, got.Version)
+	}
+	if !got.CreatedAt.Equal(u.CreatedAt) {
+		t.Errorf("Expected CreatedAt %v, got %v", u.CreatedAt, got.CreatedAt)
+	}
+	if !got.UpdatedAt.Equal(u.UpdatedAt) {
+		t.Errorf("Expected UpdatedAt %v, got %v", u.UpdatedAt, got.UpdatedAt)
+	}
+}
+
+func testGetByEmailCaseInsensitive(t *testing.T, s store.UserStore) {
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+
+	got, err := s.GetByEmail(context.Background(), "Test@Example.COM")
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	if got == nil || got.ID != u.ID {
+		t.Fatalf("GetByEmail() should match regardless of case, got %+v", got)
+	}
+}
+
+func testGetByEmailNotFound(t *testing.T, s store.UserStore) {
+	got, err := s.GetByEmail(context.Background(), "nonexistent@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	if got != nil {
+		t.Error("GetByEmail() should return nil for non-existent user")
+	}
+}
+
+func testGetByEmailReturnsCopy(t *testing.T, s store.UserStore) {
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+	u.Password = "mutated-after-create"
+
+	got, err := s.GetByEmail(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	got.Password = "mutated-after-get"
+
+	again, err := s.GetByEmail(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	if again.Password != "hashedpassword" {
+		t.Errorf("mutating returned users must not change stored state, got password %q", again.Password)
+	}
+}
+
+func testCreateDuplicate(t *testing.T, s store.UserStore) {
+	first := mustCreate(t, s, "test@example.com", "hashedpassword1")
+
+	err := s.Create(context.Background(), &model.User{Email: "test@example.com", Password: "hashedpassword2"})
+	if !errors.Is(err, store.ErrDuplicateEmail) {
+		t.Fatalf("Expected ErrDuplicateEmail, got %v", err)
+	}
+
+	got, err := s.GetByEmail(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	if got.ID != first.ID || got.Password != "hashedpassword1" {
+		t.Error("Duplicate create should not overwrite the existing user")
+	}
+}
+
+func testCreateDuplicateDifferentCase(t *testing.T, s store.UserStore) {
+	mustCreate(t, s, "test@example.com", "hashedpassword1")
+
+	err := s.Create(context.Background(), &model.User{Email: "TEST@example.com", Password: "hashedpassword2"})
+	if !errors.Is(err, store.ErrDuplicateEmail) {
+		t.Fatalf("Expected ErrDuplicateEmail, got %v", err)
+	}
+}
+
+func testGetByID(t *testing.T, s store.UserStore) {
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+
+	got, err := s.GetByID(context.Background(), u.ID)
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if got == nil {
+		t.Fatal("GetByID() returned nil user")
+	}
+	if got.Email != u.Email || got.Password != u.Password || got.Version != u.Version {
+		t.Errorf("GetByID() = %+v, want %+v", got, u)
+	}
+	if !got.CreatedAt.Equal(u.CreatedAt) {
+		t.Errorf("Expected CreatedAt %v, got %v", u.CreatedAt, got.CreatedAt)
+	}
+}
+
+func testGetByIDNotFound(t *testing.T, s store.UserStore) {
+	got, err := s.GetByID(context.Background(), uuid.New())
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if got != nil {
+		t.Error("GetByID() should return nil for non-existent user")
+	}
+}
+
+func testUpdate(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "old@example.com", "hashedpassword1")
+	created := u.CreatedAt
+
+	u.Email = "new@example.com"
+	u.Password = "hashedpassword2"
+	if err := s.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if u.Version != 2 {
+		t.Errorf("Update() should bump Version to 2, got %d", u.Version)
+	}
+	if u.UpdatedAt.Before(created) {
+		t.Errorf("UpdatedAt %v should not be before CreatedAt %v", u.UpdatedAt, created)
+	}
+
+	got, err := s.GetByID(ctx, u.ID)
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if got.Email != "new@example.com" || got.Password != "hashedpassword2" || got.Version != 2 {
+		t.Errorf("GetByID() after Update() = %+v", got)
+	}
+	if !got.CreatedAt.Equal(created) {
+		t.Errorf("Update() must not change CreatedAt: got %v, want %v", got.CreatedAt, created)
+	}
+	if !got.UpdatedAt.Equal(u.UpdatedAt) {
+		t.Errorf("Expected UpdatedAt %v, got %v", u.UpdatedAt, got.UpdatedAt)
+	}
+
+	if old, _ := s.GetByEmail(ctx, "old@example.com"); old != nil {
+		t.Error("old email should no longer resolve after Update()")
+	}
+	if byNew, _ := s.GetByEmail(ctx, "new@example.com"); byNew == nil || byNew.ID != u.ID {
+		t.Error("new email should resolve to the updated user")
+	}
+	mustCreate(t, s, "old@example.com", "hashedpassword3")
+}
+
+func testUpdateLockedUntil(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+	if u.LockedUntil != nil {
+		t.Fatalf("new users should not be locked, got %v", u.LockedUntil)
+	}
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	u.LockedUntil = &until
+	if err := s.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	got, _ := s.GetByEmail(ctx, "test@example.com")
+	if got.LockedUntil == nil || !got.LockedUntil.Equal(until) {
+		t.Fatalf("Expected LockedUntil %v, got %v", until, got.LockedUntil)
+	}
+
+	// Mutating the returned copy must not affect the stored user
+	*got.LockedUntil = until.Add(time.Hour)
+	if again, _ := s.GetByID(ctx, u.ID); !again.LockedUntil.Equal(until) {
+		t.Errorf("stored LockedUntil changed through a returned copy: %v", again.LockedUntil)
+	}
+
+	got.LockedUntil = nil
+	if err := s.Update(ctx, got); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if cleared, _ := s.GetByID(ctx, u.ID); cleared.LockedUntil != nil {
+		t.Errorf("Expected LockedUntil cleared, got %v", cleared.LockedUntil)
+	}
+}
+
+func testUpdateEmailVerifiedAt(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+	if u.EmailVerifiedAt != nil {
+		t.Fatalf("new users should not be verified, got %v", u.EmailVerifiedAt)
+	}
+
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	u.EmailVerifiedAt = &at
+	if err := s.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	got, _ := s.GetByID(ctx, u.ID)
+	if got.EmailVerifiedAt == nil || !got.EmailVerifiedAt.Equal(at) {
+		t.Fatalf("Expected EmailVerifiedAt %v, got %v", at, got.EmailVerifiedAt)
+	}
+
+	// Mutating the returned copy must not affect the stored user
+	*got.EmailVerifiedAt = at.Add(-time.Hour)
+	if again, _ := s.GetByID(ctx, u.ID); !again.EmailVerifiedAt.Equal(at) {
+		t.Errorf("stored EmailVerifiedAt changed through a returned copy: %v", again.EmailVerifiedAt)
+	}
+}
+
+func testUpdatePendingEmail(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+	if u.PendingEmail != "" {
+		t.Fatalf("new users should have no pending email, got %q", u.PendingEmail)
+	}
+
+	// A pending address is not reserved: another user can still take it
+	u.PendingEmail = "next@example.com"
+	if err := s.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	got, _ := s.GetByID(ctx, u.ID)
+	if got.PendingEmail != "next@example.com" {
+		t.Fatalf("Expected PendingEmail next@example.com, got %q", got.PendingEmail)
+	}
+	if byPending, _ := s.GetByEmail(ctx, "next@example.com"); byPending != nil {
+		t.Error("a pending email should not resolve to the user")
+	}
+	mustCreate(t, s, "next@example.com", "hashedpassword")
+
+	got.PendingEmail = ""
+	if err := s.Update(ctx, got); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if again, _ := s.GetByID(ctx, u.ID); again.PendingEmail != "" {
+		t.Errorf("Expected PendingEmail to be cleared, got %q", again.PendingEmail)
+	}
+}
+
+func testUpdateTOTP(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+	if u.TOTPSecret != "" || u.TOTPEnabledAt != nil || u.TOTPLastStep != 0 {
+		t.Fatalf("new users should have no TOTP, got %+v", u)
+	}
+
+	at := time.Now().UTC().Truncate(time.Microsecond)
+	u.TOTPSecret = "sealed-secret"
+	u.TOTPEnabledAt = &at
+	u.TOTPLastStep = 57000000
+	if err := s.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	got, _ := s.GetByID(ctx, u.ID)
+	if got.TOTPSecret != "sealed-secret" || got.TOTPLastStep != 57000000 {
+		t.Errorf("Expected TOTP secret and step to round-trip, got %q, %d", got.TOTPSecret, got.TOTPLastStep)
+	}
+	if got.TOTPEnabledAt == nil || !got.TOTPEnabledAt.Equal(at) {
+		t.Fatalf("Expected TOTPEnabledAt %v, got %v", at, got.TOTPEnabledAt)
+	}
+
+	// Mutating the returned copy must not affect the stored user
+	*got.TOTPEnabledAt = at.Add(-time.Hour)
+	if again, _ := s.GetByID(ctx, u.ID); !again.TOTPEnabledAt.Equal(at) {
+		t.Errorf("stored TOTPEnabledAt changed through a returned copy: %v", again.TOTPEnabledAt)
+	}
+}
+
+func testUpdateRecoveryCodes(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+	if len(u.RecoveryCodes) != 0 {
+		t.Fatalf("new users should have no recovery codes, got %v", u.RecoveryCodes)
+	}
+
+	u.RecoveryCodes = []string{"$2a$04$first", "$2a$04$second"}
+	if err := s.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	got, _ := s.GetByID(ctx, u.ID)
+	if !slices.Equal(got.RecoveryCodes, u.RecoveryCodes) {
+		t.Fatalf("Expected recovery codes %v, got %v", u.RecoveryCodes, got.RecoveryCodes)
+	}
+
+	// Mutating the returned copy must not affect the stored user
+	got.RecoveryCodes[0] = "changed"
+	if again, _ := s.GetByID(ctx, u.ID); again.RecoveryCodes[0] != "$2a$04$first" {
+		t.Errorf("stored recovery codes changed through a returned copy: %v", again.RecoveryCodes)
+	}
+
+	got.RecoveryCodes = nil
+	if err := s.Update(ctx, got); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if again, _ := s.GetByID(ctx, u.ID); len(again.RecoveryCodes) != 0 {
+		t.Errorf("Expected recovery codes to be cleared, got %v", again.RecoveryCodes)
+	}
+}
+
+func testUpdateScopes(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+	if len(u.Scopes) != 0 {
+		t.Fatalf("new users should have no scopes of their own, got %v", u.Scopes)
+	}
+
+	u.Scopes = []string{"admin", "billing"}
+	if err := s.Update(ctx, u); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	got, _ := s.GetByEmail(ctx, "test@example.com")
+	if !slices.Equal(got.Scopes, u.Scopes) {
+		t.Fatalf("Expected scopes %v, got %v", u.Scopes, got.Scopes)
+	}
+
+	// Mutating the returned copy must not affect the stored user
+	got.Scopes[0] = "changed"
+	if again, _ := s.GetByID(ctx, u.ID); again.Scopes[0] != "admin" {
+		t.Errorf("stored scopes changed through a returned copy: %v", again.Scopes)
+	}
+
+	got.Scopes = nil
+	if err := s.Update(ctx, got); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if again, _ := s.GetByID(ctx, u.ID); len(again.Scopes) != 0 {
+		t.Errorf("Expected scopes to be cleared, got %v", again.Scopes)
+	}
+}
+
+func testConcurrentEmailChange(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	const n = 10
+	users := make([]*model.User, n)
+	for i := range users {
+		users[i] = mustCreate(t, s, fmt.Sprintf("user%d@example.com", i), "hashedpassword")
+	}
+
+	// Every user switches to the same address; the store must let exactly
+	// one of them have it and leave the others on their old address.
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i, u := range users {
+		wg.Add(1)
+		go func(i int, u *model.User) {
+			defer wg.Done()
+			u.Email = "wanted@example.com"
+			errs[i] = s.Update(ctx, u)
+		}(i, u)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			if winner >= 0 {
+				t.Fatalf("users %d and %d both got the address", winner, i)
+			}
+			winner = i
+		case !errors.Is(err, store.ErrDuplicateEmail):
+			t.Errorf("unexpected Update() error: %v", err)
+		}
+	}
+	if winner < 0 {
+		t.Fatal("Expected one Update() to succeed")
+	}
+	got, _ := s.GetByEmail(ctx, "wanted@example.com")
+	if got == nil || got.ID != users[winner].ID {
+		t.Errorf("wanted@example.com should resolve to user %d, got %+v", winner, got)
+	}
+	for i := range users {
+		old := fmt.Sprintf("user%d@example.com", i)
+		byOld, _ := s.GetByEmail(ctx, old)
+		if (byOld != nil) == (i == winner) {
+			t.Errorf("%s: resolves = %v, want %v", old, byOld != nil, i != winner)
+		}
+	}
+}
+
+func testUpdateStaleVersion(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+
+	first, _ := s.GetByID(ctx, u.ID)
+	second, _ := s.GetByID(ctx, u.ID)
+
+	first.Password = "from-first"
+	if err := s.Update(ctx, first); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	second.Password = "from-second"
+	if err := s.Update(ctx, second); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("Expected ErrConflict, got %v", err)
+	}
+
+	got, _ := s.GetByID(ctx, u.ID)
+	if got.Password != "from-first" {
+		t.Errorf("stale Update() must not be applied, got password %q", got.Password)
+	}
+}
+
+func testUpdateNotFound(t *testing.T, s store.UserStore) {
+	err := s.Update(context.Background(), &model.User{ID: uuid.New(), Email: "ghost@example.com", Version: 1})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func testUpdateDuplicateEmail(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	mustCreate(t, s, "taken@example.com", "hashedpassword")
+	u := mustCreate(t, s, "mine@example.com", "hashedpassword")
+
+	u.Email = "Taken@example.com"
+	if err := s.Update(ctx, u); !errors.Is(err, store.ErrDuplicateEmail) {
+		t.Fatalf("Expected ErrDuplicateEmail, got %v", err)
+	}
+
+	got, _ := s.GetByEmail(ctx, "mine@example.com")
+	if got == nil || got.Version != 1 {
+		t.Errorf("rejected Update() must leave the user unchanged, got %+v", got)
+	}
+}
+
+func testDelete(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	u := mustCreate(t, s, "test@example.com", "hashedpassword")
+
+	if err := s.Delete(ctx, u.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+
+	if got, err := s.GetByID(ctx, u.ID); err != nil || got != nil {
+		t.Errorf("GetByID() after Delete() = %+v, %v; want nil, nil", got, err)
+	}
+	if got, err := s.GetByEmail(ctx, u.Email); err != nil || got != nil {
+		t.Errorf("GetByEmail() after Delete() = %+v, %v; want nil, nil", got, err)
+	}
+	page, err := s.List(ctx, store.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if len(page.Users) != 0 {
+		t.Errorf("List() should hide deleted users, got %d", len(page.Users))
+	}
+	if err := s.Update(ctx, u); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Update() after Delete(): expected ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(ctx, u.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Delete(): expected ErrNotFound, got %v", err)
+	}
+
+	// The email is released for a new account
+	again := mustCreate(t, s, "test@example.com", "hashedpassword")
+	if again.ID == u.ID {
+		t.Error("re-registered email should get a new ID")
+	}
+}
+
+func testDeleteNotFound(t *testing.T, s store.UserStore) {
+	if err := s.Delete(context.Background(), uuid.New()); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func testListPagination(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	for _, email := range []string{"e@example.com", "c@example.com", "a@example.com", "D@example.com", "b@example.com"} {
+		mustCreate(t, s, email, "hashedpassword")
+	}
+
+	var got []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("List() did not terminate")
+		}
+		page, err := s.List(ctx, store.ListOptions{Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatalf("List() failed: %v", err)
+		}
+		if len(page.Users) > 2 {
+			t.Fatalf("List() returned %d users, limit was 2", len(page.Users))
+		}
+		for _, u := range page.Users {
+			got = append(got, u.Email)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	want := []string{"a@example.com", "b@example.com", "c@example.com", "D@example.com", "e@example.com"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("List() pages = %v, want %v", got, want)
+	}
+}
+
+func testListEmailPrefix(t *testing.T, s store.UserStore) {
+	ctx := context.Background()
+	for _, email := range []string{"alice@example.com", "Alan@example.com", "bob@example.com", "a_b@example.com", "axb@example.com"} {
+		mustCreate(t, s, email, "hashedpassword")
+	}
+
+	cases := []struct {
+		prefix string
+		want   []string
+	}{
+		{"al", []string{"Alan@example.com", "alice@example.com"}},
+		{"AL", []string{"Alan@example.com", "alice@example.com"}},
+		{"a_", []string{"a_b@example.com"}},
+		{"zed", nil},
+	}
+	for _, c := range cases {
+		page, err := s.List(ctx, store.ListOptions{EmailPrefix: c.prefix})
+		if err != nil {
+			t.Fatalf("List(%q) failed: %v", c.prefix, err)
+		}
+		var got []string
+		for _, u := range page.Users {
+			got = append(got, u.Email)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("List(%q) = %v, want %v", c.prefix, got, c.want)
+		}
+		if page.NextCursor != "" {
+			t.Errorf("List(%q) should fit in one page", c.prefix)
+		}
+	}
+}
+
+func testListInvalidCursor(t *testing.T, s store.UserStore) {
+	_, err := s.List(context.Background(), store.ListOptions{Cursor: "not a cursor!"})
+	if !errors.Is(err, store.ErrInvalidCursor) {
+		t.Fatalf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, s store.UserStore) {
+	const n = 20
+	users := make([]*model.User, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			users[i] = &model.User{Email: fmt.Sprintf("user%d@example.com", i), Password: "hashedpassword"}
+			errs[i] = s.Create(context.Background(), users[i])
+		}(i)
+	}
+	wg.Wait()
+
+	ids := make(map[uuid.UUID]bool, n)
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("Create(%s) failed: %v", users[i].Email, errs[i])
+		}
+		if ids[users[i].ID] {
+			t.Errorf("duplicate ID %s assigned", users[i].ID)
+		}
+		ids[users[i].ID] = true
+
+		got, err := s.GetByEmail(context.Background(), users[i].Email)
+		if err != nil || got == nil || got.ID != users[i].ID {
+			t.Errorf("GetByEmail(%s) = %+v, %v", users[i].Email, got, err)
+		}
+	}
+}
+
+func testConcurrentDuplicateCreates(t *testing.T, s store.UserStore) {
+	const n = 20
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.Create(context.Background(), &model.User{
+				Email:    "race@example.com",
+				Password: fmt.Sprintf("hashedpassword%d", i),
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, store.ErrDuplicateEmail):
+		default:
+			t.Errorf("unexpected Create() error: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected exactly one successful create, got %d", created)
+	}
+}
+
+func testCanceledContext(t *testing.T, s store.UserStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.Create(ctx, &model.User{Email: "test@example.com", Password: "hashedpassword"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Create() with canceled context: expected context.Canceled, got %v", err)
+	}
+
+	if _, err := s.GetByEmail(ctx, "test@example.com"); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetByEmail() with canceled context: expected context.Canceled, got %v", err)
+	}
+
+	existing := mustCreate(t, s, "existing@example.com", "hashedpassword")
+	if _, err := s.GetByID(ctx, existing.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetByID() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if err := s.Update(ctx, existing); !errors.Is(err, context.Canceled) {
+		t.Errorf("Update() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if err := s.Delete(ctx, existing.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("Delete() with canceled context: expected context.Canceled, got %v", err)
+	}
+	if _, err := s.List(ctx, store.ListOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("List() with canceled context: expected context.Canceled, got %v", err)
+	}
+
+	got, err := s.GetByEmail(context.Background(), "test@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	if got != nil {
+		t.Error("Create() with canceled context must not persist the user")
+	}
+}
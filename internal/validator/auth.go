@@ -12,6 +12,16 @@ var (
 	ErrPasswordRequired = errors.New("password is required")
 	ErrPasswordTooShort = errors.New("password must be at least 8 characters")
 	ErrPasswordTooWeak  = errors.New("password must contain letters and numbers")
+	ErrPasswordTooLong  = errors.New("password must be at most 72 bytes")
+
+	ErrRefreshTokenRequired = errors.New("refresh_token is required")
+	ErrTokenRequired        = errors.New("token is required")
+
+	ErrCurrentPasswordRequired = errors.New("current_password is required")
+
+	ErrCodeRequired     = errors.New("code is required")
+	ErrMFATokenRequired = errors.New("mfa_token is required")
+	ErrCodeAmbiguous    = errors.New("only one of code and recovery_code may be given")
 )
 
 // emailRegex is a basic email validation regex
@@ -24,30 +34,210 @@ type AuthRequest struct {
 	Password string `json:"password"`
 }
 
+// NormalizeEmail trims and lower-cases email and checks its format.
+func NormalizeEmail(email string) (string, error) {
+	if strings.TrimSpace(email) == "" {
+		return "", ErrEmailRequired
+	}
+	email = strings.TrimSpace(strings.ToLower(email))
+	if !emailRegex.MatchString(email) {
+		return "", ErrEmailInvalid
+	}
+	return email, nil
+}
+
 func (a *AuthRequest) Validate() error {
 	// Email validation
-	if strings.TrimSpace(a.Email) == "" {
-		return ErrEmailRequired
+	email, err := NormalizeEmail(a.Email)
+	if err != nil {
+		return err
 	}
+	a.Email = email
 
-	a.Email = strings.TrimSpace(strings.ToLower(a.Email))
+	return ValidatePassword(a.Password)
+}
 
-	if !emailRegex.MatchString(a.Email) {
-		return ErrEmailInvalid
-	}
+// MaxPasswordBytes is the longest password accepted, in bytes: bcrypt
+// cannot hash more, and the limit holds whichever hasher is configured so
+// switching PASSWORD_HASH_ALG never strands an account.
+const MaxPasswordBytes = 72
 
-	// Password validation
-	if a.Password == "" {
+// ValidatePassword checks password against the rules every new password
+// must meet.
+func ValidatePassword(password string) error {
+	if password == "" {
 		return ErrPasswordRequired
 	}
 
-	if len(a.Password) < 8 {
+	if len(password) < 8 {
 		return ErrPasswordTooShort
 	}
 
-	if !hasLetter.MatchString(a.Password) || !hasNumber.MatchString(a.Password) {
+	if len(password) > MaxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+
+	if !hasLetter.MatchString(password) || !hasNumber.MatchString(password) {
 		return ErrPasswordTooWeak
 	}
 
 	return nil
 }
+
+// SigninRequest carries signin credentials. Unlike AuthRequest it does not
+// hold the password to the rules for new passwords: accounts imported with
+// legacy hashes may have passwords that break them and must still be able
+// to sign in and have their hash upgraded.
+type SigninRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (r *SigninRequest) Validate() error {
+	email, err := NormalizeEmail(r.Email)
+	if err != nil {
+		return err
+	}
+	r.Email = email
+	if r.Password == "" {
+		return ErrPasswordRequired
+	}
+	return nil
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *RefreshRequest) Validate() error {
+	r.RefreshToken = strings.TrimSpace(r.RefreshToken)
+	if r.RefreshToken == "" {
+		return ErrRefreshTokenRequired
+	}
+	return nil
+}
+
+// TokenRequest carries a one-time token, such as an email verification
+// token.
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+func (r *TokenRequest) Validate() error {
+	r.Token = strings.TrimSpace(r.Token)
+	if r.Token == "" {
+		return ErrTokenRequired
+	}
+	return nil
+}
+
+// EmailRequest names an account by email alone.
+type EmailRequest struct {
+	Email string `json:"email"`
+}
+
+func (r *EmailRequest) Validate() error {
+	email, err := NormalizeEmail(r.Email)
+	if err != nil {
+		return err
+	}
+	r.Email = email
+	return nil
+}
+
+// ResetPasswordRequest sets a new password with a password reset token.
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (r *ResetPasswordRequest) Validate() error {
+	r.Token = strings.TrimSpace(r.Token)
+	if r.Token == "" {
+		return ErrTokenRequired
+	}
+	return ValidatePassword(r.Password)
+}
+
+// ChangePasswordRequest replaces the caller's password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+	// RevokeOtherSessions signs out every session but the caller's.
+	RevokeOtherSessions bool `json:"revoke_other_sessions"`
+}
+
+func (r *ChangePasswordRequest) Validate() error {
+	if r.CurrentPassword == "" {
+		return ErrCurrentPasswordRequired
+	}
+	return ValidatePassword(r.NewPassword)
+}
+
+// ChangeEmailRequest moves the caller's account to a new email address.
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email"`
+	CurrentPassword string `json:"current_password"`
+}
+
+func (r *ChangeEmailRequest) Validate() error {
+	email, err := NormalizeEmail(r.NewEmail)
+	if err != nil {
+		return err
+	}
+	if r.CurrentPassword == "" {
+		return ErrCurrentPasswordRequired
+	}
+	r.NewEmail = email
+	return nil
+}
+
+// PasswordConfirmRequest re-authenticates the caller before a sensitive
+// change, such as enrolling a second factor.
+type PasswordConfirmRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+func (r *PasswordConfirmRequest) Validate() error {
+	if r.CurrentPassword == "" {
+		return ErrCurrentPasswordRequired
+	}
+	return nil
+}
+
+// CodeRequest carries a one-time code from an authenticator app.
+type CodeRequest struct {
+	Code string `json:"code"`
+}
+
+func (r *CodeRequest) Validate() error {
+	r.Code = strings.TrimSpace(r.Code)
+	if r.Code == "" {
+		return ErrCodeRequired
+	}
+	return nil
+}
+
+// MFASigninRequest completes a signin with the challenge token /signin
+// returned and either a code from the authenticator app or a recovery code.
+type MFASigninRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (r *MFASigninRequest) Validate() error {
+	r.MFAToken = strings.TrimSpace(r.MFAToken)
+	if r.MFAToken == "" {
+		return ErrMFATokenRequired
+	}
+	r.Code = strings.TrimSpace(r.Code)
+	r.RecoveryCode = strings.TrimSpace(r.RecoveryCode)
+	if r.Code == "" && r.RecoveryCode == "" {
+		return ErrCodeRequired
+	}
+	if r.Code != "" && r.RecoveryCode != "" {
+		return ErrCodeAmbiguous
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -58,6 +59,30 @@ func TestAuthRequest_Validate(t *testing.T) {
 			},
 			wantErr: ErrPasswordTooShort,
 		},
+		{
+			name: "password at the length limit",
+			req: AuthRequest{
+				Email:    "test@coinbase.com",
+				Password: "password1" + strings.Repeat("x", MaxPasswordBytes-9),
+			},
+			wantErr: nil,
+		},
+		{
+			name: "password too long",
+			req: AuthRequest{
+				Email:    "test@coinbase.com",
+				Password: "password1" + strings.Repeat("x", MaxPasswordBytes-8),
+			},
+			wantErr: ErrPasswordTooLong,
+		},
+		{
+			name: "password too long in bytes, not characters",
+			req: AuthRequest{
+				Email:    "test@coinbase.com",
+				Password: "password1" + strings.Repeat("é", 32),
+			},
+			wantErr: ErrPasswordTooLong,
+		},
 		{
 			name: "password without numbers",
 			req: AuthRequest{
@@ -97,3 +122,190 @@ func TestAuthRequest_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     RefreshRequest
+		wantErr error
+	}{
+		{"valid", RefreshRequest{RefreshToken: "abc123"}, nil},
+		{"empty", RefreshRequest{RefreshToken: ""}, ErrRefreshTokenRequired},
+		{"whitespace", RefreshRequest{RefreshToken: "   "}, ErrRefreshTokenRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     TokenRequest
+		wantErr error
+	}{
+		{"valid", TokenRequest{Token: " abc.def.ghi "}, nil},
+		{"empty", TokenRequest{Token: ""}, ErrTokenRequired},
+		{"whitespace", TokenRequest{Token: "   "}, ErrTokenRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSigninRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     SigninRequest
+		wantErr error
+	}{
+		{"valid", SigninRequest{Email: " Test@Coinbase.com ", Password: "password123"}, nil},
+		{"short password", SigninRequest{Email: "test@coinbase.com", Password: "abc"}, nil},
+		{"letters only", SigninRequest{Email: "test@coinbase.com", Password: "onlyletters"}, nil},
+		{"long password", SigninRequest{Email: "test@coinbase.com", Password: strings.Repeat("a1", MaxPasswordBytes)}, nil},
+		{"empty password", SigninRequest{Email: "test@coinbase.com"}, ErrPasswordRequired},
+		{"invalid email", SigninRequest{Email: "not-an-email", Password: "password123"}, ErrEmailInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && tt.req.Email != "test@coinbase.com" {
+				t.Errorf("Email not normalized: got %s", tt.req.Email)
+			}
+		})
+	}
+}
+
+func TestEmailRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       EmailRequest
+		wantErr   error
+		wantEmail string
+	}{
+		{"valid", EmailRequest{Email: " Test@Coinbase.com "}, nil, "test@coinbase.com"},
+		{"empty", EmailRequest{Email: ""}, ErrEmailRequired, ""},
+		{"invalid", EmailRequest{Email: "not-an-email"}, ErrEmailInvalid, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && tt.req.Email != tt.wantEmail {
+				t.Errorf("Email not normalized: got %s, want %s", tt.req.Email, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestResetPasswordRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ResetPasswordRequest
+		wantErr error
+	}{
+		{"valid", ResetPasswordRequest{Token: " tok ", Password: "password123"}, nil},
+		{"missing token", ResetPasswordRequest{Token: " ", Password: "password123"}, ErrTokenRequired},
+		{"missing password", ResetPasswordRequest{Token: "tok"}, ErrPasswordRequired},
+		{"short password", ResetPasswordRequest{Token: "tok", Password: "pass1"}, ErrPasswordTooShort},
+		{"weak password", ResetPasswordRequest{Token: "tok", Password: "password"}, ErrPasswordTooWeak},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && tt.req.Token != "tok" {
+				t.Errorf("Token not trimmed: got %q", tt.req.Token)
+			}
+		})
+	}
+}
+
+func TestChangePasswordRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ChangePasswordRequest
+		wantErr error
+	}{
+		{"valid", ChangePasswordRequest{CurrentPassword: "x", NewPassword: "password123"}, nil},
+		{"missing current", ChangePasswordRequest{NewPassword: "password123"}, ErrCurrentPasswordRequired},
+		{"missing new", ChangePasswordRequest{CurrentPassword: "x"}, ErrPasswordRequired},
+		{"short new", ChangePasswordRequest{CurrentPassword: "x", NewPassword: "pass1"}, ErrPasswordTooShort},
+		{"weak new", ChangePasswordRequest{CurrentPassword: "x", NewPassword: "password"}, ErrPasswordTooWeak},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChangeEmailRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ChangeEmailRequest
+		wantErr error
+	}{
+		{"valid", ChangeEmailRequest{NewEmail: " New@Example.com ", CurrentPassword: "x"}, nil},
+		{"missing email", ChangeEmailRequest{CurrentPassword: "x"}, ErrEmailRequired},
+		{"invalid email", ChangeEmailRequest{NewEmail: "invalid", CurrentPassword: "x"}, ErrEmailInvalid},
+		{"missing current", ChangeEmailRequest{NewEmail: "new@example.com"}, ErrCurrentPasswordRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && tt.req.NewEmail != "new@example.com" {
+				t.Errorf("Email not normalized: got %q", tt.req.NewEmail)
+			}
+		})
+	}
+}
+
+func TestMFASigninRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     MFASigninRequest
+		wantErr error
+	}{
+		{"valid", MFASigninRequest{MFAToken: " tok ", Code: " 123456 "}, nil},
+		{"missing token", MFASigninRequest{Code: "123456"}, ErrMFATokenRequired},
+		{"missing code", MFASigninRequest{MFAToken: "tok"}, ErrCodeRequired},
+		{"whitespace code", MFASigninRequest{MFAToken: "tok", Code: "  "}, ErrCodeRequired},
+		{"recovery code", MFASigninRequest{MFAToken: "tok", RecoveryCode: " abcde-fghij "}, nil},
+		{"both codes", MFASigninRequest{MFAToken: "tok", Code: "123456", RecoveryCode: "abcde-fghij"}, ErrCodeAmbiguous},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (tt.req.MFAToken != "tok" || strings.Contains(tt.req.Code+tt.req.RecoveryCode, " ")) {
+				t.Errorf("Fields not trimmed: got %+v", tt.req)
+			}
+		})
+	}
+}
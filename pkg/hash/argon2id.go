@@ -0,0 +1,104 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrInvalidHash = errors.New("hash: invalid encoded hash")
+
+// DefaultArgon2id follows the RFC 9106 second recommended option:
+// 64 MiB of memory, three passes.
+var DefaultArgon2id = Argon2id{Memory: 64 * 1024, Time: 3, Parallelism: 4}
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+
+	// Upper bounds on decoded parameters: 1 GiB, 16 passes, 16 lanes.
+	argon2MaxMemory      = 1 << 20
+	argon2MaxTime        = 16
+	argon2MaxParallelism = 16
+)
+
+// Argon2id hashes passwords with Argon2id and encodes the result in PHC
+// string format:
+//
+//	$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>
+type Argon2id struct {
+	// Memory is the memory cost in KiB.
+	Memory uint32
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Parallelism is the number of lanes.
+	Parallelism uint8
+}
+
+func (a Argon2id) Hash(pw string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(pw), salt, a.Time, a.Memory, a.Parallelism, argon2KeyLen)
+	return encodeArgon2id(a, salt, key), nil
+}
+
+func (Argon2id) Compare(hashed, plain string) bool {
+	params, salt, key, err := decodeArgon2id(hashed)
+	if err != nil {
+		return false
+	}
+	got := argon2.IDKey([]byte(plain), salt, params.Time, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(got, key) == 1
+}
+
+// NeedsRehash reports whether hashed is not an Argon2id hash or was made
+// with different parameters.
+func (a Argon2id) NeedsRehash(hashed string) bool {
+	params, _, key, err := decodeArgon2id(hashed)
+	return err != nil || params != a || len(key) != argon2KeyLen
+}
+
+func encodeArgon2id(a Argon2id, salt, key []byte) string {
+	b64 := base64.RawStdEncoding.EncodeToString
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Time, a.Parallelism, b64(salt), b64(key))
+}
+
+// decodeArgon2id parses a PHC string produced by encodeArgon2id.
+func decodeArgon2id(hashed string) (Argon2id, []byte, []byte, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+	var a Argon2id
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &a.Memory, &a.Time, &a.Parallelism); err != nil {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+	// Refuse parameters that would let a crafted hash exhaust memory.
+	if a.Memory == 0 || a.Memory > argon2MaxMemory || a.Time == 0 || a.Time > argon2MaxTime ||
+		a.Parallelism == 0 || a.Parallelism > argon2MaxParallelism {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+	return a, salt, key, nil
+}
@@ -0,0 +1,76 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2id keeps tests fast; production parameters come from config.
+var testArgon2id = Argon2id{Memory: 1024, Time: 1, Parallelism: 1}
+
+func TestArgon2id_HashAndCompare(t *testing.T) {
+	password := "testpassword123"
+
+	hash, err := testArgon2id.Hash(password)
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("Expected PHC string with parameters, got %s", hash)
+	}
+
+	if !testArgon2id.Compare(hash, password) {
+		t.Error("Compare() failed for correct password")
+	}
+	if testArgon2id.Compare(hash, "wrongpassword") {
+		t.Error("Compare() should fail for wrong password")
+	}
+
+	again, _ := testArgon2id.Hash(password)
+	if again == hash {
+		t.Error("Hash() should use a random salt")
+	}
+}
+
+func TestArgon2id_CompareUsesEncodedParameters(t *testing.T) {
+	hash, _ := testArgon2id.Hash("testpassword123")
+
+	// A hasher configured differently still verifies older hashes
+	if !(Argon2id{Memory: 2048, Time: 2, Parallelism: 2}).Compare(hash, "testpassword123") {
+		t.Error("Compare() should read parameters from the hash")
+	}
+}
+
+func TestArgon2id_LongPassword(t *testing.T) {
+	// Unlike bcrypt, nothing past 72 bytes is ignored
+	long := strings.Repeat("a", 100) + "1"
+	hash, err := testArgon2id.Hash(long)
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	if testArgon2id.Compare(hash, strings.Repeat("a", 100)+"2") {
+		t.Error("Compare() should consider the whole password")
+	}
+}
+
+func TestArgon2id_CompareInvalidHash(t *testing.T) {
+	bcryptHash, _ := Bcrypt{}.Hash("testpassword123")
+
+	tests := []string{
+		"",
+		bcryptHash,
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1000000,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=255$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA$",
+	}
+	for _, hashed := range tests {
+		if testArgon2id.Compare(hashed, "testpassword123") {
+			t.Errorf("Compare() should fail for %q", hashed)
+		}
+	}
+}
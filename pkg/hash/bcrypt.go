@@ -4,14 +4,34 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-type Bcrypt struct{}
+// Bcrypt hashes passwords with bcrypt. Hash returns
+// bcrypt.ErrPasswordTooLong for passwords longer than 72 bytes rather than
+// silently truncating them, so callers should refuse those up front.
+type Bcrypt struct {
+	// Cost is the bcrypt work factor; zero means bcrypt.DefaultCost.
+	Cost int
+}
+
+func (b Bcrypt) cost() int {
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
+}
 
 // Hash returns bcrypt hash of the password.
-func (Bcrypt) Hash(pw string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+func (b Bcrypt) Hash(pw string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(pw), b.cost())
 	return string(bytes), err
 }
 
+// NeedsRehash reports whether hashed is not a bcrypt hash or uses a
+// different cost.
+func (b Bcrypt) NeedsRehash(hashed string) bool {
+	cost, err := bcrypt.Cost([]byte(hashed))
+	return err != nil || cost != b.cost()
+}
+
 // Compare verifies a bcrypt‑hashed password with its possible plaintext equivalent.
 func (Bcrypt) Compare(hashed, plain string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(plain)) == nil
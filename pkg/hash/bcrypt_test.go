@@ -1,7 +1,10 @@
 package hash
 
 import (
+	"strings"
 	"testing"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestBcrypt_Hash(t *testing.T) {
@@ -47,3 +50,24 @@ func TestBcrypt_Compare(t *testing.T) {
 		t.Error("Compare() should fail for empty password")
 	}
 }
+
+func TestBcrypt_Cost(t *testing.T) {
+	hash, err := Bcrypt{Cost: 5}.Hash("testpassword123")
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		t.Fatalf("bcrypt.Cost() failed: %v", err)
+	}
+	if cost != 5 {
+		t.Errorf("Expected cost 5, got %d", cost)
+	}
+}
+
+func TestBcrypt_RejectsLongPassword(t *testing.T) {
+	// bcrypt only looks at the first 72 bytes; refuse rather than truncate
+	if _, err := (Bcrypt{}).Hash(strings.Repeat("a", 73)); err == nil {
+		t.Error("Hash() should reject passwords over 72 bytes")
+	}
+}
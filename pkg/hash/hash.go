@@ -0,0 +1,49 @@
+package hash
+
+// Hasher hashes passwords for storage and verifies them later. Hashes are
+// self-describing, so Compare reads the parameters from the stored hash
+// rather than from the Hasher's current configuration.
+type Hasher interface {
+	Verifier
+	Hash(pw string) (string, error)
+	// NeedsRehash reports whether hashed was made with a different algorithm
+	// or weaker parameters than Hash would use today.
+	NeedsRehash(hashed string) bool
+}
+
+// Verifier checks a password against a stored hash. Verifiers for formats
+// imported from other systems cannot produce new hashes.
+type Verifier interface {
+	Compare(hashed, plain string) bool
+}
+
+// Fallback hashes new passwords with Primary and verifies hashes produced by
+// Primary or any of Legacy, so the configured algorithm can change without
+// invalidating stored hashes. Every Hasher here rejects foreign formats
+// without doing any key derivation, so only the matching one pays the cost.
+type Fallback struct {
+	Primary Hasher
+	Legacy  []Verifier
+}
+
+func (f Fallback) Hash(pw string) (string, error) {
+	return f.Primary.Hash(pw)
+}
+
+// NeedsRehash reports whether hashed falls short of Primary's policy; every
+// hash made by a Legacy hasher does.
+func (f Fallback) NeedsRehash(hashed string) bool {
+	return f.Primary.NeedsRehash(hashed)
+}
+
+func (f Fallback) Compare(hashed, plain string) bool {
+	if f.Primary.Compare(hashed, plain) {
+		return true
+	}
+	for _, h := range f.Legacy {
+		if h.Compare(hashed, plain) {
+			return true
+		}
+	}
+	return false
+}
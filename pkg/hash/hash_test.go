@@ -0,0 +1,58 @@
+package hash
+
+import "testing"
+
+func TestFallback(t *testing.T) {
+	h := Fallback{Primary: testArgon2id, Legacy: []Verifier{Bcrypt{Cost: 4}}}
+
+	legacy, _ := Bcrypt{Cost: 4}.Hash("testpassword123")
+	current, err := h.Hash("testpassword123")
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	if !testArgon2id.Compare(current, "testpassword123") {
+		t.Error("Hash() should use the primary hasher")
+	}
+
+	for name, hashed := range map[string]string{"primary": current, "legacy": legacy} {
+		if !h.Compare(hashed, "testpassword123") {
+			t.Errorf("Compare() failed for %s hash", name)
+		}
+		if h.Compare(hashed, "wrongpassword") {
+			t.Errorf("Compare() should fail for wrong password against %s hash", name)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcrypt4, _ := Bcrypt{Cost: 4}.Hash("testpassword123")
+	bcrypt5, _ := Bcrypt{Cost: 5}.Hash("testpassword123")
+	argonWeak, _ := testArgon2id.Hash("testpassword123")
+	argonStrong := Argon2id{Memory: 2048, Time: 1, Parallelism: 1}
+
+	tests := []struct {
+		name   string
+		hasher Hasher
+		hashed string
+		want   bool
+	}{
+		{"bcrypt same cost", Bcrypt{Cost: 4}, bcrypt4, false},
+		{"bcrypt cost raised", Bcrypt{Cost: 5}, bcrypt4, true},
+		{"bcrypt cost lowered", Bcrypt{Cost: 4}, bcrypt5, true},
+		{"bcrypt given argon2id", Bcrypt{Cost: 4}, argonWeak, true},
+		{"argon2id same params", testArgon2id, argonWeak, false},
+		{"argon2id params changed", argonStrong, argonWeak, true},
+		{"argon2id given bcrypt", testArgon2id, bcrypt4, true},
+		{"fallback legacy hash", Fallback{Primary: testArgon2id, Legacy: []Verifier{Bcrypt{Cost: 4}}}, bcrypt4, true},
+		{"fallback primary hash", Fallback{Primary: testArgon2id, Legacy: []Verifier{Bcrypt{Cost: 4}}}, argonWeak, false},
+		{"garbage", Bcrypt{}, "not-a-hash", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hashed); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package hash
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Verifiers for hashes imported from older systems. Each recognizes its own
+// format by prefix and rejects anything else without deriving a key; none of
+// them can hash new passwords, so accounts carrying these hashes are
+// upgraded on their first successful signin.
+
+// PBKDF2 verifies passlib-style PBKDF2 hashes:
+//
+//	$pbkdf2-sha256$<iterations>$<salt>$<checksum>
+//	$pbkdf2-sha512$<iterations>$<salt>$<checksum>
+//
+// Salt and checksum use passlib's adapted base64 ('.' instead of '+', no
+// padding); standard unpadded base64 is accepted as well.
+type PBKDF2 struct{}
+
+func (PBKDF2) Compare(hashed, plain string) bool {
+	h, iter, salt, want, err := decodePBKDF2(hashed)
+	if err != nil {
+		return false
+	}
+	got := pbkdf2.Key([]byte(plain), salt, iter, len(want), h)
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+// Limits on parameters read from imported hashes. Real exports sit far below
+// them; anything above is a crafted hash meant to burn CPU or memory.
+const (
+	maxPBKDF2Iterations  = 5_000_000
+	maxScryptMemory      = 256 << 20
+	maxScryptParallelism = 4
+	maxLegacyKeyLen      = 128
+)
+
+func decodePBKDF2(hashed string) (func() hash.Hash, int, []byte, []byte, error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 5 || parts[0] != "" {
+		return nil, 0, nil, nil, ErrInvalidHash
+	}
+	var h func() hash.Hash
+	switch parts[1] {
+	case "pbkdf2-sha256":
+		h = sha256.New
+	case "pbkdf2-sha512":
+		h = sha512.New
+	default:
+		return nil, 0, nil, nil, ErrInvalidHash
+	}
+	iter, err := strconv.Atoi(parts[2])
+	// Refuse parameters that would let a crafted hash pin a CPU.
+	if err != nil || iter < 1 || iter > maxPBKDF2Iterations {
+		return nil, 0, nil, nil, ErrInvalidHash
+	}
+	salt, err := decodeAdaptedBase64(parts[3])
+	if err != nil {
+		return nil, 0, nil, nil, ErrInvalidHash
+	}
+	want, err := decodeAdaptedBase64(parts[4])
+	if err != nil || len(want) == 0 || len(want) > maxLegacyKeyLen {
+		return nil, 0, nil, nil, ErrInvalidHash
+	}
+	return h, iter, salt, want, nil
+}
+
+// Scrypt verifies passlib-style scrypt hashes:
+//
+//	$scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<checksum>
+type Scrypt struct{}
+
+func (Scrypt) Compare(hashed, plain string) bool {
+	n, r, p, salt, want, err := decodeScrypt(hashed)
+	if err != nil {
+		return false
+	}
+	got, err := scrypt.Key([]byte(plain), salt, n, r, p, len(want))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
+
+func decodeScrypt(hashed string) (n, r, p int, salt, want []byte, err error) {
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "scrypt" {
+		return 0, 0, 0, nil, nil, ErrInvalidHash
+	}
+	var ln int
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &ln, &r, &p); err != nil {
+		return 0, 0, 0, nil, nil, ErrInvalidHash
+	}
+	// Refuse parameters that would let a crafted hash exhaust memory: each
+	// compare needs 128·N·r bytes, and p multiplies the CPU work.
+	if ln < 1 || ln > 20 || r < 1 || r > 32 || p < 1 || p > maxScryptParallelism ||
+		int64(128*r)<<ln > maxScryptMemory {
+		return 0, 0, 0, nil, nil, ErrInvalidHash
+	}
+	salt, err = decodeAdaptedBase64(parts[3])
+	if err != nil {
+		return 0, 0, 0, nil, nil, ErrInvalidHash
+	}
+	want, err = decodeAdaptedBase64(parts[4])
+	if err != nil || len(want) == 0 || len(want) > maxLegacyKeyLen {
+		return 0, 0, 0, nil, nil, ErrInvalidHash
+	}
+	return 1 << ln, r, p, salt, want, nil
+}
+
+// SaltedSHA256 verifies LDAP-style salted SHA-256 hashes:
+//
+//	{SSHA256}<base64(sha256(password || salt) || salt)>
+//
+// A single SHA-256 is far too cheap for password storage; these hashes are
+// only accepted so imported accounts can sign in once and be upgraded.
+type SaltedSHA256 struct{}
+
+func (SaltedSHA256) Compare(hashed, plain string) bool {
+	want, salt, err := decodeSaltedSHA256(hashed)
+	if err != nil {
+		return false
+	}
+	got := sha256.Sum256(append([]byte(plain), salt...))
+	return subtle.ConstantTimeCompare(got[:], want) == 1
+}
+
+func decodeSaltedSHA256(hashed string) (sum, salt []byte, err error) {
+	encoded, ok := strings.CutPrefix(hashed, "{SSHA256}")
+	if !ok {
+		return nil, nil, ErrInvalidHash
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) <= sha256.Size {
+		return nil, nil, ErrInvalidHash
+	}
+	return raw[:sha256.Size], raw[sha256.Size:], nil
+}
+
+func decodeAdaptedBase64(s string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.ReplaceAll(s, ".", "+"))
+}
+
+// Identify names the scheme of hashed by its prefix, or returns "" for
+// formats no Hasher or Verifier in this package understands. Peppered hashes
+// are identified by the scheme underneath the pepper.
+func Identify(hashed string) string {
+	if _, inner, ok := splitPeppered(hashed); ok {
+		return Identify(inner)
+	}
+	switch {
+	case strings.HasPrefix(hashed, "$2a$"), strings.HasPrefix(hashed, "$2b$"), strings.HasPrefix(hashed, "$2y$"):
+		return "bcrypt"
+	case strings.HasPrefix(hashed, "$argon2id$"):
+		return "argon2id"
+	case strings.HasPrefix(hashed, "$pbkdf2-sha256$"):
+		return "pbkdf2-sha256"
+	case strings.HasPrefix(hashed, "$pbkdf2-sha512$"):
+		return "pbkdf2-sha512"
+	case strings.HasPrefix(hashed, "$scrypt$"):
+		return "scrypt"
+	case strings.HasPrefix(hashed, "{SSHA256}"):
+		return "ssha256"
+	default:
+		return ""
+	}
+}
+
+// Validate parses hashed with the decoder for its scheme and returns
+// ErrInvalidHash if it is malformed or its parameters are out of the range
+// the verifiers accept, so such hashes can be turned away before they are
+// stored rather than failing every signin afterwards.
+func Validate(hashed string) error {
+	if _, inner, ok := splitPeppered(hashed); ok {
+		return Validate(inner)
+	}
+	var err error
+	switch Identify(hashed) {
+	case "bcrypt":
+		_, err = bcrypt.Cost([]byte(hashed))
+	case "argon2id":
+		_, _, _, err = decodeArgon2id(hashed)
+	case "pbkdf2-sha256", "pbkdf2-sha512":
+		_, _, _, _, err = decodePBKDF2(hashed)
+	case "scrypt":
+		_, _, _, _, _, err = decodeScrypt(hashed)
+	case "ssha256":
+		_, _, err = decodeSaltedSHA256(hashed)
+	default:
+		err = ErrInvalidHash
+	}
+	if err != nil {
+		return ErrInvalidHash
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package hash
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Vectors for "password123", generated independently with Python's hashlib.
+var legacyVectors = []struct {
+	name     string
+	verifier Verifier
+	hashed   string
+}{
+	{"pbkdf2-sha256", PBKDF2{}, "$pbkdf2-sha256$1000$bGVnYWN5LXNhbHQtMDAwMQ$GOzgi0210x3UfZ05aRLYlKB95IPl305r88lVM3aUUm8"},
+	{"pbkdf2-sha512", PBKDF2{}, "$pbkdf2-sha512$1000$bGVnYWN5LXNhbHQtMDAwMQ$rH.ji56VhFEnikNYEobSzECZP7kMnymZ5PLt/hybXSNGVU36hORD4nUTMUmAW8WoqHhHpcazzIKzAJHvoJTJ7A"},
+	{"scrypt", Scrypt{}, "$scrypt$ln=10,r=8,p=1$bGVnYWN5LXNhbHQtMDAwMQ$QLFuh5gzSK3Ig5fRg/bqzHBv6S6WjYRpNyzhd.bf7Xk"},
+	{"ssha256", SaltedSHA256{}, "{SSHA256}8+2hqbD76p4UyJAX0VHbrtqqQIa9eNRLw9MH275JhIxsZWdhY3ktc2FsdC0wMDAx"},
+}
+
+func TestLegacyVerifiers(t *testing.T) {
+	for _, tt := range legacyVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.verifier.Compare(tt.hashed, "password123") {
+				t.Error("Compare() failed for correct password")
+			}
+			if tt.verifier.Compare(tt.hashed, "password124") {
+				t.Error("Compare() should fail for wrong password")
+			}
+			if got := Identify(tt.hashed); got != tt.name {
+				t.Errorf("Identify() = %q, want %q", got, tt.name)
+			}
+		})
+	}
+}
+
+func TestLegacyVerifiers_RejectForeignFormats(t *testing.T) {
+	bcryptHash, _ := Bcrypt{Cost: 4}.Hash("password123")
+	for _, tt := range legacyVectors {
+		for _, other := range legacyVectors {
+			if other.verifier == tt.verifier {
+				continue
+			}
+			if tt.verifier.Compare(other.hashed, "password123") {
+				t.Errorf("%s verifier accepted a %s hash", tt.name, other.name)
+			}
+		}
+		if tt.verifier.Compare(bcryptHash, "password123") {
+			t.Errorf("%s verifier accepted a bcrypt hash", tt.name)
+		}
+	}
+}
+
+func TestScrypt_RejectsExcessiveParameters(t *testing.T) {
+	for _, params := range []string{
+		"ln=30,r=8,p=1",  // would allocate terabytes before failing
+		"ln=20,r=32,p=1", // 4 GiB
+		"ln=18,r=16,p=1", // 512 MiB, just over the cap
+		"ln=10,r=8,p=16", // 16 times the CPU work
+	} {
+		hashed := "$scrypt$" + params + "$bGVnYWN5LXNhbHQtMDAwMQ$QLFuh5gzSK3Ig5fRg/bqzHBv6S6WjYRpNyzhd.bf7Xk"
+		if (Scrypt{}).Compare(hashed, "password123") {
+			t.Errorf("Compare() should reject %s", params)
+		}
+		if err := Validate(hashed); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("Validate() = %v for %s, want ErrInvalidHash", err, params)
+		}
+	}
+	// 256 MiB is the most allowed
+	if err := Validate("$scrypt$ln=18,r=8,p=4$bGVnYWN5LXNhbHQtMDAwMQ$QLFuh5gzSK3Ig5fRg/bqzHBv6S6WjYRpNyzhd.bf7Xk"); err != nil {
+		t.Errorf("Validate() = %v at the memory cap", err)
+	}
+}
+
+func TestPBKDF2_RejectsExcessiveParameters(t *testing.T) {
+	for _, hashed := range []string{
+		// a billion iterations would pin a core for minutes
+		"$pbkdf2-sha256$1000000000$bGVnYWN5LXNhbHQtMDAwMQ$GOzgi0210x3UfZ05aRLYlKB95IPl305r88lVM3aUUm8",
+		// a 1 KiB checksum multiplies the work by the number of blocks
+		"$pbkdf2-sha256$1000$bGVnYWN5LXNhbHQtMDAwMQ$" + strings.Repeat("A", 1366),
+	} {
+		if (PBKDF2{}).Compare(hashed, "password123") {
+			t.Errorf("Compare(%.40q...) should reject excessive parameters", hashed)
+		}
+		if err := Validate(hashed); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("Validate(%.40q...) = %v, want ErrInvalidHash", hashed, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bcryptHash, _ := Bcrypt{Cost: 4}.Hash("password123")
+	argonHash, _ := testArgon2id.Hash("password123")
+	valid := []string{bcryptHash, argonHash, "$pepper$v=1$" + bcryptHash}
+	for _, tt := range legacyVectors {
+		valid = append(valid, tt.hashed)
+	}
+	for _, hashed := range valid {
+		if err := Validate(hashed); err != nil {
+			t.Errorf("Validate(%q) = %v", hashed, err)
+		}
+	}
+	for _, hashed := range []string{"", "plaintext", "$2a$04$short", "$argon2id$v=19$m=0,t=1,p=1$c2FsdA$a2V5", "$pepper$v=1$plaintext", "{SSHA256}c2hvcnQ"} {
+		if err := Validate(hashed); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("Validate(%q) = %v, want ErrInvalidHash", hashed, err)
+		}
+	}
+}
+
+func TestIdentify_Unknown(t *testing.T) {
+	for _, hashed := range []string{"", "plaintext", "$1$md5crypt$abc", "{SHA}abc"} {
+		if got := Identify(hashed); got != "" {
+			t.Errorf("Identify(%q) = %q, want empty", hashed, got)
+		}
+	}
+}
+
+func TestFallback_UpgradesLegacyHashes(t *testing.T) {
+	h := Fallback{Primary: testArgon2id, Legacy: []Verifier{Bcrypt{}, PBKDF2{}, Scrypt{}, SaltedSHA256{}}}
+	for _, tt := range legacyVectors {
+		if !h.Compare(tt.hashed, "password123") {
+			t.Errorf("Fallback did not verify %s hash", tt.name)
+		}
+		if !h.NeedsRehash(tt.hashed) {
+			t.Errorf("Expected %s hash to need rehash", tt.name)
+		}
+	}
+}
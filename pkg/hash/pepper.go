@@ -0,0 +1,127 @@
+package hash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidPepper = errors.New("hash: invalid pepper configuration")
+
+const pepperPrefix = "$pepper$v="
+
+// Peppered mixes a server-side secret into passwords before Inner hashes
+// them, so a leaked database cannot be cracked without also stealing the
+// pepper. The pepper version is recorded in front of the inner hash:
+//
+//	$pepper$v=2$$2a$10$...
+//
+// Hashes made without a pepper, or with a pepper other than Current, keep
+// verifying as long as their version is still in Peppers, and report
+// NeedsRehash so they are upgraded at the next signin.
+type Peppered struct {
+	Inner   Hasher
+	Peppers map[int][]byte
+	Current int
+}
+
+// NewPeppered returns Inner peppered with the highest version in peppers.
+func NewPeppered(inner Hasher, peppers map[int][]byte) (Peppered, error) {
+	if len(peppers) == 0 {
+		return Peppered{}, fmt.Errorf("%w: no peppers", ErrInvalidPepper)
+	}
+	current := 0
+	for v := range peppers {
+		if v > current {
+			current = v
+		}
+	}
+	return Peppered{Inner: inner, Peppers: peppers, Current: current}, nil
+}
+
+// ParsePeppers parses "version:secret" pairs separated by commas or
+// newlines, e.g. "1:old-secret,2:new-secret".
+func ParsePeppers(s string) (map[int][]byte, error) {
+	peppers := map[int][]byte{}
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		version, secret, ok := strings.Cut(field, ":")
+		v, err := strconv.Atoi(version)
+		if !ok || err != nil || v < 1 {
+			return nil, fmt.Errorf("%w: want version:secret with version >= 1", ErrInvalidPepper)
+		}
+		if len(secret) < 16 {
+			return nil, fmt.Errorf("%w: pepper %d is shorter than 16 bytes", ErrInvalidPepper, v)
+		}
+		if _, dup := peppers[v]; dup {
+			return nil, fmt.Errorf("%w: duplicate version %d", ErrInvalidPepper, v)
+		}
+		peppers[v] = []byte(secret)
+	}
+	return peppers, nil
+}
+
+func (p Peppered) Hash(pw string) (string, error) {
+	pepper, ok := p.Peppers[p.Current]
+	if !ok {
+		return "", fmt.Errorf("%w: no pepper for version %d", ErrInvalidPepper, p.Current)
+	}
+	inner, err := p.Inner.Hash(pepperPassword(pepper, pw))
+	if err != nil {
+		return "", err
+	}
+	return pepperPrefix + strconv.Itoa(p.Current) + "$" + inner, nil
+}
+
+func (p Peppered) Compare(hashed, plain string) bool {
+	version, inner, ok := splitPeppered(hashed)
+	if !ok {
+		return p.Inner.Compare(hashed, plain)
+	}
+	pepper, known := p.Peppers[version]
+	if !known {
+		return false
+	}
+	return p.Inner.Compare(inner, pepperPassword(pepper, plain))
+}
+
+func (p Peppered) NeedsRehash(hashed string) bool {
+	version, inner, ok := splitPeppered(hashed)
+	if !ok || version != p.Current {
+		return true
+	}
+	return p.Inner.NeedsRehash(inner)
+}
+
+// pepperPassword returns the base64 HMAC-SHA256 of pw under pepper. The
+// fixed 44-character result stays under bcrypt's 72-byte limit and contains
+// no NUL bytes, whatever the password.
+func pepperPassword(pepper []byte, pw string) string {
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(pw))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// splitPeppered parses the "$pepper$v=N$" prefix written by Peppered.Hash.
+func splitPeppered(hashed string) (version int, inner string, ok bool) {
+	rest, found := strings.CutPrefix(hashed, pepperPrefix)
+	if !found {
+		return 0, "", false
+	}
+	digits, inner, found := strings.Cut(rest, "$")
+	if !found {
+		return 0, "", false
+	}
+	version, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, "", false
+	}
+	return version, inner, true
+}
@@ -0,0 +1,114 @@
+package hash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPeppered_HashAndCompare(t *testing.T) {
+	p, err := NewPeppered(Bcrypt{Cost: 4}, map[int][]byte{1: []byte("pepper-one-0123456789")})
+	if err != nil {
+		t.Fatalf("NewPeppered() failed: %v", err)
+	}
+
+	hashed, err := p.Hash("password123")
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	if !strings.HasPrefix(hashed, "$pepper$v=1$$2a$04$") {
+		t.Errorf("Expected pepper version in front of the bcrypt hash, got %s", hashed)
+	}
+	if !p.Compare(hashed, "password123") {
+		t.Error("Compare() failed for correct password")
+	}
+	if p.Compare(hashed, "wrongpassword") {
+		t.Error("Compare() should fail for wrong password")
+	}
+	if p.NeedsRehash(hashed) {
+		t.Error("Fresh hash should not need rehash")
+	}
+
+	// Without the pepper the stored hash is useless to an attacker
+	_, inner, _ := splitPeppered(hashed)
+	if (Bcrypt{}).Compare(inner, "password123") {
+		t.Error("Inner hash should not verify the bare password")
+	}
+}
+
+func TestPeppered_Rotation(t *testing.T) {
+	v1 := map[int][]byte{1: []byte("pepper-one-0123456789")}
+	old, _ := NewPeppered(Bcrypt{Cost: 4}, v1)
+	oldHash, _ := old.Hash("password123")
+	unpeppered, _ := Bcrypt{Cost: 4}.Hash("password123")
+
+	rotated, _ := NewPeppered(Bcrypt{Cost: 4}, map[int][]byte{
+		1: []byte("pepper-one-0123456789"),
+		2: []byte("pepper-two-0123456789"),
+	})
+	if rotated.Current != 2 {
+		t.Fatalf("Expected current version 2, got %d", rotated.Current)
+	}
+
+	for name, hashed := range map[string]string{"previous pepper": oldHash, "no pepper": unpeppered} {
+		if !rotated.Compare(hashed, "password123") {
+			t.Errorf("Compare() failed for %s", name)
+		}
+		if !rotated.NeedsRehash(hashed) {
+			t.Errorf("Expected %s hash to need rehash", name)
+		}
+	}
+
+	newHash, _ := rotated.Hash("password123")
+	if !strings.HasPrefix(newHash, "$pepper$v=2$") {
+		t.Errorf("Expected new hash under version 2, got %s", newHash)
+	}
+
+	// Once version 1 is retired its hashes no longer verify
+	retired, _ := NewPeppered(Bcrypt{Cost: 4}, map[int][]byte{2: []byte("pepper-two-0123456789")})
+	if retired.Compare(oldHash, "password123") {
+		t.Error("Compare() should fail for a hash under a removed pepper")
+	}
+	if !retired.Compare(newHash, "password123") {
+		t.Error("Compare() failed under the current pepper")
+	}
+}
+
+func TestPeppered_LongPasswordWithBcrypt(t *testing.T) {
+	p, _ := NewPeppered(Bcrypt{Cost: 4}, map[int][]byte{1: []byte("pepper-one-0123456789")})
+
+	// The HMAC is fixed length, so bcrypt sees every byte of the password
+	long := strings.Repeat("a", 100)
+	hashed, err := p.Hash(long + "1")
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	if p.Compare(hashed, long+"2") {
+		t.Error("Compare() should consider bytes past 72")
+	}
+}
+
+func TestParsePeppers(t *testing.T) {
+	peppers, err := ParsePeppers("1:pepper-one-0123456789,\n2:pepper-two-0123456789\n")
+	if err != nil {
+		t.Fatalf("ParsePeppers() failed: %v", err)
+	}
+	if len(peppers) != 2 || string(peppers[2]) != "pepper-two-0123456789" {
+		t.Errorf("Unexpected peppers %q", peppers)
+	}
+
+	for _, bad := range []string{
+		"pepper-without-version-0123",
+		"0:pepper-zero-0123456789",
+		"x:pepper-bad-0123456789",
+		"1:short",
+		"1:pepper-one-0123456789,1:pepper-dup-0123456789",
+	} {
+		if _, err := ParsePeppers(bad); err == nil {
+			t.Errorf("ParsePeppers(%q) should fail", bad)
+		}
+	}
+
+	if _, err := NewPeppered(Bcrypt{}, nil); err == nil {
+		t.Error("NewPeppered() should require a pepper")
+	}
+}
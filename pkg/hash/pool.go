@@ -0,0 +1,87 @@
+package hash
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPoolFull means every worker is busy and the queue is full.
+	ErrPoolFull = errors.New("hash: pool queue is full")
+	// ErrQueueTimeout means the caller waited longer than the pool's queue
+	// timeout for a worker.
+	ErrQueueTimeout = errors.New("hash: timed out waiting for a worker")
+)
+
+// Pool bounds how many hashes a Hasher computes at once, so a burst of
+// signins cannot take every core from the rest of the process. Callers
+// beyond the worker count wait in a queue of bounded length; once it is full
+// they are turned away at once with ErrPoolFull. A queued caller gives up
+// when its context ends or after the queue timeout, whichever comes first.
+// Work already running is never interrupted.
+type Pool struct {
+	hasher  Hasher
+	workers chan struct{}
+	// admitted holds a token for every caller running or queued.
+	admitted     chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewPool runs h on at most workers goroutines at once with up to queue
+// callers waiting. A zero queueTimeout waits as long as the caller's
+// context allows.
+func NewPool(h Hasher, workers, queue int, queueTimeout time.Duration) *Pool {
+	workers = max(workers, 1)
+	return &Pool{
+		hasher:       h,
+		workers:      make(chan struct{}, workers),
+		admitted:     make(chan struct{}, workers+max(queue, 0)),
+		queueTimeout: queueTimeout,
+	}
+}
+
+func (p *Pool) Hash(ctx context.Context, pw string) (hashed string, err error) {
+	err = p.do(ctx, func() { hashed, err = p.hasher.Hash(pw) })
+	return hashed, err
+}
+
+// Compare reports whether plain matches hashed. A non-nil error means the
+// comparison never ran.
+func (p *Pool) Compare(ctx context.Context, hashed, plain string) (ok bool, err error) {
+	err = p.do(ctx, func() { ok = p.hasher.Compare(hashed, plain) })
+	return ok, err
+}
+
+// NeedsRehash only parses the hash, so it bypasses the pool.
+func (p *Pool) NeedsRehash(hashed string) bool {
+	return p.hasher.NeedsRehash(hashed)
+}
+
+// Running and Queued report the pool's current load.
+func (p *Pool) Running() int { return len(p.workers) }
+func (p *Pool) Queued() int  { return len(p.admitted) - len(p.workers) }
+
+func (p *Pool) do(ctx context.Context, work func()) error {
+	select {
+	case p.admitted <- struct{}{}:
+	default:
+		return ErrPoolFull
+	}
+	defer func() { <-p.admitted }()
+
+	if p.queueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, p.queueTimeout, ErrQueueTimeout)
+		defer cancel()
+	}
+	select {
+	case p.workers <- struct{}{}:
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+	defer func() { <-p.workers }()
+
+	work()
+	return nil
+}
@@ -0,0 +1,216 @@
+package hash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingHasher holds every Hash call until release is closed.
+type blockingHasher struct {
+	Bcrypt
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHasher() *blockingHasher {
+	return &blockingHasher{started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (b *blockingHasher) Hash(pw string) (string, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return "hashed:" + pw, nil
+}
+
+func TestPool_HashAndCompare(t *testing.T) {
+	p := NewPool(Bcrypt{Cost: 4}, 2, 2, 0)
+	ctx := context.Background()
+
+	hashed, err := p.Hash(ctx, "password123")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if ok, err := p.Compare(ctx, hashed, "password123"); !ok || err != nil {
+		t.Errorf("Compare(correct) = %v, %v", ok, err)
+	}
+	if ok, err := p.Compare(ctx, hashed, "wrong"); ok || err != nil {
+		t.Errorf("Compare(wrong) = %v, %v", ok, err)
+	}
+	if p.NeedsRehash(hashed) {
+		t.Error("NeedsRehash() = true for a fresh hash")
+	}
+}
+
+func TestPool_ShedsWhenQueueFull(t *testing.T) {
+	h := newBlockingHasher()
+	p := NewPool(h, 1, 1, 0)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.Hash(ctx, "pw")
+			errs <- err
+		}()
+	}
+	<-h.started // one running
+	waitFor(t, func() bool { return p.Queued() == 1 })
+	if p.Running() != 1 {
+		t.Errorf("Running() = %d, want 1", p.Running())
+	}
+
+	if _, err := p.Hash(ctx, "pw"); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("Hash() with a full queue error = %v, want ErrPoolFull", err)
+	}
+
+	close(h.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("admitted Hash() error = %v", err)
+		}
+	}
+}
+
+func TestPool_QueueTimeout(t *testing.T) {
+	h := newBlockingHasher()
+	defer close(h.release)
+	p := NewPool(h, 1, 1, 20*time.Millisecond)
+
+	go p.Hash(context.Background(), "pw")
+	<-h.started
+
+	if _, err := p.Hash(context.Background(), "pw"); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Hash() error = %v, want ErrQueueTimeout", err)
+	}
+}
+
+func TestPool_HonorsContext(t *testing.T) {
+	h := newBlockingHasher()
+	defer close(h.release)
+	p := NewPool(h, 1, 1, time.Minute)
+
+	go p.Hash(context.Background(), "pw")
+	<-h.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Compare(ctx, "hashed:pw", "pw"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Compare() error = %v, want context.DeadlineExceeded", err)
+	}
+	if p.Queued() != 0 {
+		t.Errorf("Queued() = %d after the caller gave up, want 0", p.Queued())
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// BenchmarkHashUnderLoad floods bcrypt with four times as many concurrent
+// callers as there are CPUs and reports latency percentiles, both for the
+// hashes and for a trivial request served alongside them (think /health).
+// Unbounded, every hash competes for the CPUs and both tails grow with the
+// load; the pool sheds the excess quickly and keeps cores free, so admitted
+// hashes and the side request stay fast.
+func BenchmarkHashUnderLoad(b *testing.B) {
+	hasher := Bcrypt{Cost: 8}
+	workers := max(runtime.GOMAXPROCS(0)-1, 1)
+
+	run := func(b *testing.B, hashFn func(context.Context) error) {
+		var (
+			mu       sync.Mutex
+			hashLat  []time.Duration
+			sideLat  []time.Duration
+			rejected int
+		)
+		stop := make(chan struct{})
+		sideDone := make(chan struct{})
+		go func() {
+			defer close(sideDone)
+			for {
+				select {
+				case <-stop:
+					return
+				case <-time.After(time.Millisecond):
+				}
+				start := time.Now()
+				_ = fmt.Sprint(start) // stand-in for a cheap handler
+				runtime.Gosched()
+				mu.Lock()
+				sideLat = append(sideLat, time.Since(start))
+				mu.Unlock()
+			}
+		}()
+
+		b.SetParallelism(4)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				start := time.Now()
+				err := hashFn(context.Background())
+				d := time.Since(start)
+				mu.Lock()
+				if err != nil {
+					rejected++
+				} else {
+					hashLat = append(hashLat, d)
+				}
+				mu.Unlock()
+				if err != nil {
+					// A shed client backs off before retrying, as a 503
+					// with Retry-After asks it to.
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+		})
+		b.StopTimer()
+		close(stop)
+		<-sideDone
+
+		b.ReportMetric(float64(percentile(hashLat, 50).Microseconds()), "hash-p50-µs")
+		b.ReportMetric(float64(percentile(hashLat, 99).Microseconds()), "hash-p99-µs")
+		b.ReportMetric(float64(percentile(sideLat, 99).Microseconds()), "side-p99-µs")
+		b.ReportMetric(float64(rejected)/float64(b.N), "shed/op")
+	}
+
+	b.Run("unbounded", func(b *testing.B) {
+		run(b, func(context.Context) error {
+			_, err := hasher.Hash("password123")
+			return err
+		})
+	})
+	b.Run("pool", func(b *testing.B) {
+		p := NewPool(hasher, workers, 2*workers, 100*time.Millisecond)
+		run(b, func(ctx context.Context) error {
+			_, err := p.Hash(ctx, "password123")
+			return err
+		})
+	})
+}
+
+func percentile(d []time.Duration, pct int) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	d = slices.Clone(d)
+	slices.Sort(d)
+	return d[(len(d)-1)*pct/100]
+}
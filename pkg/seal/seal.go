@@ -0,0 +1,66 @@
+// Package seal encrypts small secrets, such as TOTP seeds, that the service
+// must read back and so cannot hash.
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var (
+	ErrKeyTooShort = errors.New("seal: key must be at least 32 bytes")
+	ErrInvalid     = errors.New("seal: invalid or tampered ciphertext")
+)
+
+// Box encrypts with AES-256-GCM. Sealed values are base64 text holding a
+// random nonce followed by the ciphertext, ready to store in a text column.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a Box keyed by the SHA-256 of key, which must hold at least
+// 256 bits, so secrets of any length and encoding can be used.
+func New(key []byte) (*Box, error) {
+	if len(key) < 32 {
+		return nil, ErrKeyTooShort
+	}
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext. additional is authenticated but not encrypted;
+// passing the owner's ID stops a sealed value from being moved to another
+// row.
+func (b *Box) Seal(plaintext, additional []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(plaintext)+b.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(b.aead.Seal(nonce, nonce, plaintext, additional)), nil
+}
+
+// Open decrypts a value made by Seal with the same additional data.
+func (b *Box) Open(sealed string, additional []byte) ([]byte, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return nil, ErrInvalid
+	}
+	nonce, ciphertext := raw[:b.aead.NonceSize()], raw[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,71 @@
+package seal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newBox(t *testing.T, key string) *Box {
+	t.Helper()
+	b, err := New([]byte(key))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return b
+}
+
+func TestBox_RoundTrip(t *testing.T) {
+	b := newBox(t, "a-key-of-at-least-thirty-two-bytes!")
+	secret := []byte("12345678901234567890")
+
+	sealed, err := b.Seal(secret, []byte("user-1"))
+	if err != nil {
+		t.Fatalf("Seal() failed: %v", err)
+	}
+	if bytes.Contains([]byte(sealed), secret) {
+		t.Error("Sealed value should not contain the plaintext")
+	}
+	again, _ := b.Seal(secret, []byte("user-1"))
+	if sealed == again {
+		t.Error("Sealing twice should use fresh nonces")
+	}
+
+	got, err := b.Open(sealed, []byte("user-1"))
+	if err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("Open() = %q, want %q", got, secret)
+	}
+}
+
+func TestBox_OpenRejects(t *testing.T) {
+	b := newBox(t, "a-key-of-at-least-thirty-two-bytes!")
+	sealed, _ := b.Seal([]byte("secret"), []byte("user-1"))
+	tampered := []byte(sealed)
+	tampered[len(tampered)-2] ^= 1
+
+	tests := []struct {
+		name   string
+		box    *Box
+		sealed string
+		ad     string
+	}{
+		{"other additional data", b, sealed, "user-2"},
+		{"other key", newBox(t, "another-key-of-at-least-thirty-two-bytes"), sealed, "user-1"},
+		{"tampered", b, string(tampered), "user-1"},
+		{"not base64", b, "!!!", "user-1"},
+		{"too short", b, "AAAA", "user-1"},
+	}
+	for _, tt := range tests {
+		if _, err := tt.box.Open(tt.sealed, []byte(tt.ad)); err != ErrInvalid {
+			t.Errorf("%s: expected ErrInvalid, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestNew_ShortKey(t *testing.T) {
+	if _, err := New([]byte("too-short")); err != ErrKeyTooShort {
+		t.Errorf("Expected ErrKeyTooShort, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestActionSigner(t *testing.T) {
+	s := NewActionSigner([]byte("action-secret-key-for-tests-only"))
+	userID := uuid.New()
+
+	tok, err := s.Sign("verify-email", userID, "test@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	claims, err := s.Verify(tok, "verify-email")
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if got, _ := claims.UserID(); got != userID || claims.Email != "test@example.com" {
+		t.Errorf("Verify() = %v %s, want %v test@example.com", got, claims.Email, userID)
+	}
+
+	if _, err := s.Verify(tok, "reset-password"); err != ErrInvalidActionToken {
+		t.Errorf("Verify() for another purpose error = %v, want ErrInvalidActionToken", err)
+	}
+	other := NewActionSigner([]byte("some-other-secret-key-for-tests!"))
+	if _, err := other.Verify(tok, "verify-email"); err != ErrInvalidActionToken {
+		t.Errorf("Verify() with another key error = %v, want ErrInvalidActionToken", err)
+	}
+
+	expired, _ := s.Sign("verify-email", userID, "test@example.com", -time.Minute)
+	if _, err := s.Verify(expired, "verify-email"); err != ErrInvalidActionToken {
+		t.Errorf("Verify() of expired token error = %v, want ErrInvalidActionToken", err)
+	}
+}
+
+func TestActionSignerRejectsAccessTokens(t *testing.T) {
+	secret := "shared-secret-misconfiguration!!"
+	access, err := NewJWTManager(secret, time.Hour).Generate(uuid.New(), "test@example.com")
+	if err != nil {
+		t.Fatalf("Generate() failed: %v", err)
+	}
+	if _, err := NewActionSigner([]byte(secret)).Verify(access, "verify-email"); err != ErrInvalidActionToken {
+		t.Errorf("Verify() of an access token error = %v, want ErrInvalidActionToken", err)
+	}
+}
@@ -1,52 +1,331 @@
 package token
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+var (
+	ErrTokenRevoked      = errors.New("token revoked")
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
 type Manager interface {
-	Generate(id uuid.UUID, email string) (string, error)
-	Verify(tokenStr string) (*Claims, error)
+	Generate(id uuid.UUID, email string, opts ...GenerateOption) (string, error)
+	Verify(tokenStr string, opts ...VerifyOption) (*Claims, error)
+
+	// Revoke rejects the token described by claims until it expires.
+	Revoke(claims *Claims) error
+	// RevokeSession rejects every token carrying sessionID for as long as
+	// any of them could still be valid.
+	RevokeSession(sessionID string) error
+	// RevokeUser rejects every token issued to the user so far, for as long
+	// as any of them could still be valid. Tokens issued afterwards verify.
+	RevokeUser(userID string) error
+	// JWKS returns the public keys tokens may currently be verified with.
+	JWKS() JWKS
 }
 
 type JWTManager struct {
-	secret string
-	ttl    time.Duration
+	ring    *KeyRing
+	extra   map[string]Key // verification keys outside the ring's schedule
+	ttl     time.Duration
+	revoked RevocationList
+
+	issuer   string
+	audience []string
+	leeway   time.Duration
 }
 
+// DefaultHMACKeyID is the kid of the key built from a shared secret by
+// NewJWTManager.
+const DefaultHMACKeyID = "hs256"
+
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID string `json:"sid,omitempty"`
+	// Scope is a space-separated list of granted scopes (RFC 8693).
+	Scope string `json:"scope,omitempty"`
+	// AMR lists the methods used to authenticate the signin the token
+	// descends from (RFC 8176), such as AMRPassword.
+	AMR []string `json:"amr,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewJWTManager(secret string, ttl time.Duration) *JWTManager {
-	return &JWTManager{secret: secret, ttl: ttl}
+// Authentication method references for the amr claim, from RFC 8176.
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	// AMRMulti is added when more than one factor was used.
+	AMRMulti = "mfa"
+)
+
+// Scopes returns the granted scopes.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether scope was granted.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
+}
+
+// HasAMR reports whether method was used to authenticate.
+func (c *Claims) HasAMR(method string) bool {
+	return slices.Contains(c.AMR, method)
+}
+
+// GenerateOption sets optional claims on a generated token.
+type GenerateOption func(*Claims)
+
+// WithSessionID ties the token to a session, so it can be revoked together
+// with every other token of that session.
+func WithSessionID(sid string) GenerateOption {
+	return func(c *Claims) { c.SessionID = sid }
+}
+
+// WithScopes grants scopes to the token holder.
+func WithScopes(scopes ...string) GenerateOption {
+	return func(c *Claims) { c.Scope = strings.Join(scopes, " ") }
+}
+
+// WithAMR records the methods used to authenticate.
+func WithAMR(methods ...string) GenerateOption {
+	return func(c *Claims) { c.AMR = methods }
+}
+
+// WithAudience addresses the token to aud instead of the manager's default
+// audience, e.g. to mint a token for a specific client.
+func WithAudience(aud ...string) GenerateOption {
+	return func(c *Claims) { c.Audience = aud }
+}
+
+// VerifyOption adds a requirement a token must meet to be accepted.
+type VerifyOption func(*verifyConfig)
+
+type verifyConfig struct {
+	audience string
+	scopes   []string
+}
+
+// RequireAudience rejects tokens whose aud does not include aud.
+func RequireAudience(aud string) VerifyOption {
+	return func(v *verifyConfig) { v.audience = aud }
+}
+
+// RequireScopes rejects tokens not granted every one of scopes with
+// ErrInsufficientScope.
+func RequireScopes(scopes ...string) VerifyOption {
+	return func(v *verifyConfig) { v.scopes = append(v.scopes, scopes...) }
+}
+
+// ManagerOption configures a JWTManager.
+type ManagerOption func(*JWTManager)
+
+// WithVerificationKeys accepts tokens signed by keys in addition to the
+// ring's active and retired keys, regardless of the rotation schedule.
+func WithVerificationKeys(keys ...Key) ManagerOption {
+	return func(j *JWTManager) {
+		for _, k := range keys {
+			j.extra[k.ID] = k
+		}
+	}
+}
+
+// WithRevocationList replaces the default process-local revocation list,
+// e.g. with one shared by every instance of the service.
+func WithRevocationList(rl RevocationList) ManagerOption {
+	return func(j *JWTManager) { j.revoked = rl }
+}
+
+// WithIssuer sets the iss claim on generated tokens and rejects tokens from
+// any other issuer.
+func WithIssuer(iss string) ManagerOption {
+	return func(j *JWTManager) { j.issuer = iss }
+}
+
+// WithDefaultAudience sets the aud claim on generated tokens that do not
+// name their own audience.
+func WithDefaultAudience(aud ...string) ManagerOption {
+	return func(j *JWTManager) { j.audience = aud }
+}
+
+// WithLeeway tolerates clock skew of up to d between this service and the
+// clocks of verifiers when checking exp, nbf and iat.
+func WithLeeway(d time.Duration) ManagerOption {
+	return func(j *JWTManager) { j.leeway = d }
+}
+
+// NewJWTManager returns a manager signing HS256 tokens with a shared secret.
+func NewJWTManager(secret string, ttl time.Duration, opts ...ManagerOption) *JWTManager {
+	j, _ := NewJWTManagerWithKey(NewHMACKey(DefaultHMACKeyID, []byte(secret)), ttl, opts...)
+	return j
 }
 
-func (j *JWTManager) Generate(id uuid.UUID, email string) (string, error) {
+// NewJWTManagerWithKey returns a manager signing tokens with key, which must
+// hold private key material.
+func NewJWTManagerWithKey(key Key, ttl time.Duration, opts ...ManagerOption) (*JWTManager, error) {
+	if !key.CanSign() {
+		return nil, fmt.Errorf("token: key %q cannot sign", key.ID)
+	}
+	ring := NewKeyRing(ttl)
+	if err := ring.Add(key, time.Time{}); err != nil {
+		return nil, err
+	}
+	return NewJWTManagerWithKeyRing(ring, ttl, opts...)
+}
+
+// NewJWTManagerWithKeyRing returns a manager that signs with the ring's
+// active key and verifies with its active and retired keys, following the
+// ring's rotation schedule.
+func NewJWTManagerWithKeyRing(ring *KeyRing, ttl time.Duration, opts ...ManagerOption) (*JWTManager, error) {
+	if _, err := ring.Active(); err != nil {
+		return nil, err
+	}
+	j := &JWTManager{
+		ring:    ring,
+		extra:   map[string]Key{},
+		ttl:     ttl,
+		revoked: NewMemoryRevocationList(),
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j, nil
+}
+
+func (j *JWTManager) Generate(id uuid.UUID, email string, opts ...GenerateOption) (string, error) {
 	now := time.Now()
+	issued := now
+	// A token issued in the same second as RevokeUser would otherwise
+	// carry an iat before the cutoff.
+	if cutoff := j.revoked.RevokedBefore(id.String()); issued.Before(cutoff) {
+		issued = cutoff
+	}
 	claims := &Claims{
 		UserID: id.String(),
 		Email:  email,
 		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    j.issuer,
+			Audience:  j.audience,
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(issued),
 			ExpiresAt: jwt.NewNumericDate(now.Add(j.ttl)),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(j.secret))
+	for _, opt := range opts {
+		opt(claims)
+	}
+	signing, err := j.ring.Active()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(signing.Method, claims)
+	token.Header["kid"] = signing.ID
+	return token.SignedString(signing.signKey)
 }
 
-func (j *JWTManager) Verify(tokenStr string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(j.secret), nil
-	})
+func (j *JWTManager) Verify(tokenStr string, opts ...VerifyOption) (*Claims, error) {
+	var cfg verifyConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	parserOpts := []jwt.ParserOption{jwt.WithLeeway(j.leeway)}
+	if j.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(j.issuer))
+	}
+	if cfg.audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(cfg.audience))
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, j.keyFunc, parserOpts...)
 	if err != nil {
 		return nil, err
 	}
-	return token.Claims.(*Claims), nil
+	claims := token.Claims.(*Claims)
+	for _, scope := range cfg.scopes {
+		if !claims.HasScope(scope) {
+			return nil, ErrInsufficientScope
+		}
+	}
+	if claims.ID != "" && j.revoked.IsRevoked(claims.ID) {
+		return nil, ErrTokenRevoked
+	}
+	if claims.SessionID != "" && j.revoked.IsRevoked(claims.SessionID) {
+		return nil, ErrTokenRevoked
+	}
+	if cutoff := j.revoked.RevokedBefore(claims.UserID); !cutoff.IsZero() &&
+		(claims.IssuedAt == nil || claims.IssuedAt.Time.Before(cutoff)) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// keyFunc selects the verification key named by the token's kid and refuses
+// tokens whose alg differs from the one that key is bound to, so a public
+// key can never be used as an HMAC secret.
+func (j *JWTManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := j.ring.Lookup(kid)
+	if !ok {
+		if key, ok = j.extra[kid]; !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrAlgorithmMismatch
+	}
+	return key.verifyKey, nil
+}
+
+// Revoke denies the token the claims belong to until it can no longer
+// verify, which is the leeway after it expires.
+func (j *JWTManager) Revoke(claims *Claims) error {
+	if claims.ID == "" {
+		return errors.New("token has no jti")
+	}
+	until := time.Now().Add(j.ttl)
+	if claims.ExpiresAt != nil {
+		until = claims.ExpiresAt.Time
+	}
+	j.revoked.Revoke(claims.ID, until.Add(j.leeway))
+	return nil
+}
+
+// RevokeSession denies every token of the session until the last one the
+// session may have been issued stops verifying, leeway included.
+func (j *JWTManager) RevokeSession(sessionID string) error {
+	j.revoked.Revoke(sessionID, time.Now().Add(j.ttl+j.leeway))
+	return nil
+}
+
+// RevokeUser denies every token of the user issued so far, until the last
+// of them stops verifying, leeway included. iat only has whole seconds, so
+// the cutoff is the start of the next second, and Generate dates tokens
+// issued before then at the cutoff instead.
+func (j *JWTManager) RevokeUser(userID string) error {
+	if userID == "" {
+		return errors.New("token has no user")
+	}
+	now := time.Now()
+	cutoff := now.Truncate(time.Second).Add(time.Second)
+	j.revoked.RevokeBefore(userID, cutoff, now.Add(j.ttl+j.leeway))
+	return nil
+}
+
+func (j *JWTManager) JWKS() JWKS {
+	set := j.ring.JWKS()
+	for _, k := range j.extra {
+		if jwk, ok := k.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
 }
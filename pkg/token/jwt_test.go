@@ -1,9 +1,11 @@
 package token
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -95,3 +97,239 @@ func TestJWTManager_VerifyExpiredToken(t *testing.T) {
 		t.Error("Verify() should fail for expired token")
 	}
 }
+
+func TestJWTManager_GenerateSetsJTI(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", 15*time.Minute)
+
+	token1, _ := jm.Generate(uuid.New(), "test@example.com")
+	token2, _ := jm.Generate(uuid.New(), "test@example.com")
+
+	claims1, err := jm.Verify(token1)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	claims2, err := jm.Verify(token2)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	if claims1.ID == "" {
+		t.Error("Generate() should set a jti")
+	}
+
+	if claims1.ID == claims2.ID {
+		t.Error("Generate() should set a unique jti per token")
+	}
+}
+
+func TestJWTManager_Revoke(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", 15*time.Minute)
+	userID := uuid.New()
+
+	revoked, _ := jm.Generate(userID, "test@example.com")
+	kept, _ := jm.Generate(userID, "test@example.com")
+
+	claims, err := jm.Verify(revoked)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+
+	if err := jm.Revoke(claims); err != nil {
+		t.Fatalf("Revoke() failed: %v", err)
+	}
+
+	if _, err := jm.Verify(revoked); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+
+	if _, err := jm.Verify(kept); err != nil {
+		t.Errorf("Verify() of another token failed: %v", err)
+	}
+}
+
+func TestJWTManager_RevokeSession(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", 15*time.Minute)
+	userID := uuid.New()
+
+	inSession, _ := jm.Generate(userID, "test@example.com", WithSessionID("session-1"))
+	otherSession, _ := jm.Generate(userID, "test@example.com", WithSessionID("session-2"))
+
+	if err := jm.RevokeSession("session-1"); err != nil {
+		t.Fatalf("RevokeSession() failed: %v", err)
+	}
+
+	if _, err := jm.Verify(inSession); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+
+	if _, err := jm.Verify(otherSession); err != nil {
+		t.Errorf("Verify() of another session failed: %v", err)
+	}
+}
+
+func TestJWTManager_RevokeUser(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", 15*time.Minute)
+	userID := uuid.New()
+
+	before, _ := jm.Generate(userID, "test@example.com", WithSessionID("session-1"))
+	other, _ := jm.Generate(uuid.New(), "other@example.com")
+
+	if err := jm.RevokeUser(userID.String()); err != nil {
+		t.Fatalf("RevokeUser() failed: %v", err)
+	}
+	if _, err := jm.Verify(before); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := jm.Verify(other); err != nil {
+		t.Errorf("Verify() of another user's token failed: %v", err)
+	}
+
+	// Tokens issued afterwards verify, even within the same second
+	after, _ := jm.Generate(userID, "test@example.com")
+	if _, err := jm.Verify(after); err != nil {
+		t.Errorf("Verify() of a token issued after RevokeUser() failed: %v", err)
+	}
+}
+
+func TestJWTManager_RevokeWithinLeeway(t *testing.T) {
+	// Tokens expired a second ago, but still verify within the leeway
+	jm := NewJWTManager("test-secret-key", -time.Second, WithLeeway(time.Minute))
+	userID := uuid.New()
+
+	tokenStr, _ := jm.Generate(userID, "test@example.com")
+	claims, err := jm.Verify(tokenStr)
+	if err != nil {
+		t.Fatalf("Verify() within leeway failed: %v", err)
+	}
+	if err := jm.Revoke(claims); err != nil {
+		t.Fatalf("Revoke() failed: %v", err)
+	}
+	if _, err := jm.Verify(tokenStr); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked within leeway, got %v", err)
+	}
+
+	inSession, _ := jm.Generate(userID, "test@example.com", WithSessionID("session-1"))
+	if err := jm.RevokeSession("session-1"); err != nil {
+		t.Fatalf("RevokeSession() failed: %v", err)
+	}
+	if _, err := jm.Verify(inSession); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked for the session within leeway, got %v", err)
+	}
+}
+
+func TestJWTManager_SharedRevocationList(t *testing.T) {
+	rl := NewMemoryRevocationList()
+	issuer := NewJWTManager("test-secret-key", 15*time.Minute, WithRevocationList(rl))
+	verifier := NewJWTManager("test-secret-key", 15*time.Minute, WithRevocationList(rl))
+
+	token, _ := issuer.Generate(uuid.New(), "test@example.com")
+	claims, _ := issuer.Verify(token)
+	_ = issuer.Revoke(claims)
+
+	if _, err := verifier.Verify(token); err != ErrTokenRevoked {
+		t.Errorf("Expected ErrTokenRevoked from instance sharing the list, got %v", err)
+	}
+}
+
+func TestJWTManager_Issuer(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", 15*time.Minute, WithIssuer("https://id.example.com"))
+	other := NewJWTManager("test-secret-key", 15*time.Minute, WithIssuer("https://other.example.com"))
+
+	tokenStr, _ := jm.Generate(uuid.New(), "test@example.com")
+	claims, err := jm.Verify(tokenStr)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if claims.Issuer != "https://id.example.com" {
+		t.Errorf("Expected iss https://id.example.com, got %q", claims.Issuer)
+	}
+
+	// Same key, different issuer: the token was not minted by this service
+	if _, err := other.Verify(tokenStr); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Errorf("Expected ErrTokenInvalidIssuer, got %v", err)
+	}
+}
+
+func TestJWTManager_Audience(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", 15*time.Minute, WithDefaultAudience("web"))
+
+	webToken, _ := jm.Generate(uuid.New(), "test@example.com")
+	cliToken, _ := jm.Generate(uuid.New(), "test@example.com", WithAudience("cli"))
+
+	tests := []struct {
+		name    string
+		token   string
+		opts    []VerifyOption
+		wantErr error
+	}{
+		{"default audience", webToken, []VerifyOption{RequireAudience("web")}, nil},
+		{"per-client audience", cliToken, []VerifyOption{RequireAudience("cli")}, nil},
+		{"wrong audience", webToken, []VerifyOption{RequireAudience("cli")}, jwt.ErrTokenInvalidAudience},
+		{"audience not required", cliToken, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jm.Verify(tt.token, tt.opts...)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Verify() failed: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestJWTManager_Scopes(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", 15*time.Minute)
+
+	tokenStr, _ := jm.Generate(uuid.New(), "test@example.com", WithScopes("profile", "email"))
+	claims, err := jm.Verify(tokenStr, RequireScopes("profile", "email"))
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if claims.Scope != "profile email" {
+		t.Errorf("Expected scope %q, got %q", "profile email", claims.Scope)
+	}
+	if !claims.HasScope("email") || claims.HasScope("admin") {
+		t.Errorf("Unexpected HasScope results for %q", claims.Scope)
+	}
+
+	if _, err := jm.Verify(tokenStr, RequireScopes("admin")); !errors.Is(err, ErrInsufficientScope) {
+		t.Errorf("Expected ErrInsufficientScope, got %v", err)
+	}
+}
+
+func TestJWTManager_AMR(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", 15*time.Minute)
+
+	tokenStr, _ := jm.Generate(uuid.New(), "test@example.com", WithAMR(AMRPassword, AMROTP, AMRMulti))
+	claims, err := jm.Verify(tokenStr)
+	if err != nil {
+		t.Fatalf("Verify() failed: %v", err)
+	}
+	if !claims.HasAMR(AMROTP) || !claims.HasAMR(AMRMulti) {
+		t.Errorf("Expected otp and mfa in amr, got %v", claims.AMR)
+	}
+
+	tokenStr, _ = jm.Generate(uuid.New(), "test@example.com")
+	claims, _ = jm.Verify(tokenStr)
+	if len(claims.AMR) != 0 {
+		t.Errorf("Expected no amr, got %v", claims.AMR)
+	}
+}
+
+func TestJWTManager_Leeway(t *testing.T) {
+	jm := NewJWTManager("test-secret-key", -time.Second, WithLeeway(time.Minute))
+	strict := NewJWTManager("test-secret-key", time.Minute)
+
+	// Expired a second ago, which is within the allowed clock skew
+	tokenStr, _ := jm.Generate(uuid.New(), "test@example.com")
+	if _, err := jm.Verify(tokenStr); err != nil {
+		t.Errorf("Verify() within leeway failed: %v", err)
+	}
+	if _, err := strict.Verify(tokenStr); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("Expected ErrTokenExpired without leeway, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnknownKey           = errors.New("unknown signing key")
+	ErrAlgorithmMismatch    = errors.New("signing algorithm mismatch")
+)
+
+// Key is a JWT signing or verification key bound to a single algorithm and
+// identified by the kid header of the tokens it signs.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   any // nil for verification-only keys
+	verifyKey any
+}
+
+// CanSign reports whether k holds private key material.
+func (k Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+// Public returns the public half of an asymmetric key, or nil for HMAC keys.
+func (k Key) Public() crypto.PublicKey {
+	if _, ok := k.verifyKey.([]byte); ok {
+		return nil
+	}
+	return k.verifyKey
+}
+
+// NewHMACKey returns an HS256 key using secret for both signing and
+// verification.
+func NewHMACKey(id string, secret []byte) Key {
+	return Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+}
+
+// ParsePrivateKeyPEM parses a PEM-encoded private key for alg, which must be
+// one of RS256, ES256 or EdDSA.
+func ParsePrivateKeyPEM(id, alg string, pemBytes []byte) (Key, error) {
+	k := Key{ID: id}
+	switch alg {
+	case "RS256":
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token: parse %s key %q: %w", alg, id, err)
+		}
+		k.Method, k.signKey, k.verifyKey = jwt.SigningMethodRS256, priv, &priv.PublicKey
+	case "ES256":
+		priv, err := jwt.ParseECPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token: parse %s key %q: %w", alg, id, err)
+		}
+		if priv.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("token: %s key %q must use curve P-256", alg, id)
+		}
+		k.Method, k.signKey, k.verifyKey = jwt.SigningMethodES256, priv, &priv.PublicKey
+	case "EdDSA":
+		parsed, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token: parse %s key %q: %w", alg, id, err)
+		}
+		priv, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return Key{}, fmt.Errorf("token: %s key %q is not an Ed25519 key", alg, id)
+		}
+		k.Method, k.signKey, k.verifyKey = jwt.SigningMethodEdDSA, priv, priv.Public()
+	default:
+		return Key{}, fmt.Errorf("token: %w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+	return k, nil
+}
+
+// ParsePublicKeyPEM parses a PEM-encoded public key for alg into a
+// verification-only key.
+func ParsePublicKeyPEM(id, alg string, pemBytes []byte) (Key, error) {
+	k := Key{ID: id}
+	switch alg {
+	case "RS256":
+		pub, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token: parse %s public key %q: %w", alg, id, err)
+		}
+		k.Method, k.verifyKey = jwt.SigningMethodRS256, pub
+	case "ES256":
+		pub, err := jwt.ParseECPublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token: parse %s public key %q: %w", alg, id, err)
+		}
+		if pub.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("token: %s key %q must use curve P-256", alg, id)
+		}
+		k.Method, k.verifyKey = jwt.SigningMethodES256, pub
+	case "EdDSA":
+		pub, err := jwt.ParseEdPublicKeyFromPEM(pemBytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token: parse %s public key %q: %w", alg, id, err)
+		}
+		k.Method, k.verifyKey = jwt.SigningMethodEdDSA, pub
+	default:
+		return Key{}, fmt.Errorf("token: %w: %q", ErrUnsupportedAlgorithm, alg)
+	}
+	return k, nil
+}
@@ -0,0 +1,201 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// testKeyPEM generates a key pair for alg and returns PKCS#8 private and
+// PKIX public PEM blocks.
+func testKeyPEM(t *testing.T, alg string) (privPEM, pubPEM []byte) {
+	t.Helper()
+
+	var priv, pub any
+	switch alg {
+	case "RS256":
+		k, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, pub = k, &k.PublicKey
+	case "ES256":
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, pub = k, &k.PublicKey
+	case "ES384":
+		k, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, pub = k, &k.PublicKey
+	case "EdDSA":
+		p, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, pub = k, p
+	default:
+		t.Fatalf("unknown alg %s", alg)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			privPEM, pubPEM := testKeyPEM(t, alg)
+
+			key, err := ParsePrivateKeyPEM("key-1", alg, privPEM)
+			if err != nil {
+				t.Fatalf("ParsePrivateKeyPEM() failed: %v", err)
+			}
+			issuer, err := NewJWTManagerWithKey(key, 15*time.Minute)
+			if err != nil {
+				t.Fatalf("NewJWTManagerWithKey() failed: %v", err)
+			}
+
+			userID := uuid.New()
+			tokenStr, err := issuer.Generate(userID, "test@example.com")
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(tokenStr, &Claims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified() failed: %v", err)
+			}
+			if parsed.Header["kid"] != "key-1" {
+				t.Errorf("Expected kid key-1, got %v", parsed.Header["kid"])
+			}
+			if parsed.Header["alg"] != alg {
+				t.Errorf("Expected alg %s, got %v", alg, parsed.Header["alg"])
+			}
+
+			// A downstream service holding only the public key can verify
+			pub, err := ParsePublicKeyPEM("key-1", alg, pubPEM)
+			if err != nil {
+				t.Fatalf("ParsePublicKeyPEM() failed: %v", err)
+			}
+			if pub.CanSign() {
+				t.Error("public key should not be able to sign")
+			}
+			verifier := &JWTManager{ring: NewKeyRing(0), extra: map[string]Key{pub.ID: pub}, revoked: NewMemoryRevocationList()}
+
+			claims, err := verifier.Verify(tokenStr)
+			if err != nil {
+				t.Fatalf("Verify() with public key failed: %v", err)
+			}
+			if claims.UserID != userID.String() {
+				t.Errorf("Expected UserID %s, got %s", userID, claims.UserID)
+			}
+		})
+	}
+}
+
+func TestParsePrivateKeyPEM_Rejects(t *testing.T) {
+	rsaPEM, _ := testKeyPEM(t, "RS256")
+	p384PEM, _ := testKeyPEM(t, "ES384")
+
+	tests := []struct {
+		name string
+		alg  string
+		pem  []byte
+	}{
+		{"unsupported algorithm", "HS512", rsaPEM},
+		{"none algorithm", "none", rsaPEM},
+		{"wrong key type", "ES256", rsaPEM},
+		{"wrong curve", "ES256", p384PEM},
+		{"garbage", "RS256", []byte("not a pem")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePrivateKeyPEM("key-1", tt.alg, tt.pem); err == nil {
+				t.Error("ParsePrivateKeyPEM() should fail")
+			}
+		})
+	}
+}
+
+func TestNewJWTManagerWithKey_RequiresPrivateKey(t *testing.T) {
+	_, pubPEM := testKeyPEM(t, "EdDSA")
+	pub, err := ParsePublicKeyPEM("key-1", "EdDSA", pubPEM)
+	if err != nil {
+		t.Fatalf("ParsePublicKeyPEM() failed: %v", err)
+	}
+
+	if _, err := NewJWTManagerWithKey(pub, time.Minute); err == nil {
+		t.Error("NewJWTManagerWithKey() should reject verification-only keys")
+	}
+}
+
+func TestVerify_AlgorithmConfusion(t *testing.T) {
+	privPEM, pubPEM := testKeyPEM(t, "RS256")
+	key, _ := ParsePrivateKeyPEM("rsa-1", "RS256", privPEM)
+	jm, _ := NewJWTManagerWithKey(key, 15*time.Minute)
+
+	// Classic attack: sign with HS256 using the public key as the secret
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: uuid.NewString(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	forged.Header["kid"] = "rsa-1"
+	forgedStr, err := forged.SignedString(pubPEM)
+	if err != nil {
+		t.Fatalf("SignedString() failed: %v", err)
+	}
+
+	if _, err := jm.Verify(forgedStr); !errors.Is(err, ErrAlgorithmMismatch) {
+		t.Errorf("Expected ErrAlgorithmMismatch, got %v", err)
+	}
+}
+
+func TestVerify_SelectsKeyByKID(t *testing.T) {
+	oldKey := NewHMACKey("old", []byte("old-secret"))
+	newKey := NewHMACKey("new", []byte("new-secret"))
+
+	oldManager, _ := NewJWTManagerWithKey(oldKey, 15*time.Minute)
+	newManager, _ := NewJWTManagerWithKey(newKey, 15*time.Minute, WithVerificationKeys(oldKey))
+
+	oldToken, _ := oldManager.Generate(uuid.New(), "test@example.com")
+	if _, err := newManager.Verify(oldToken); err != nil {
+		t.Errorf("Verify() of token signed by a verification key failed: %v", err)
+	}
+
+	strangerManager, _ := NewJWTManagerWithKey(NewHMACKey("stranger", []byte("x")), time.Minute)
+	strangerToken, _ := strangerManager.Generate(uuid.New(), "test@example.com")
+	if _, err := newManager.Verify(strangerToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+
+	noKID := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: uuid.NewString()})
+	noKIDStr, _ := noKID.SignedString([]byte("new-secret"))
+	if _, err := newManager.Verify(noKIDStr); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey for token without kid, got %v", err)
+	}
+}
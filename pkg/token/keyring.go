@@ -0,0 +1,244 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoActiveKey = errors.New("no active signing key")
+
+type KeyState int
+
+const (
+	// KeyNext keys are published but not used for signing yet, so verifiers
+	// can cache them before the first token signed with them shows up.
+	KeyNext KeyState = iota
+	// KeyActive is the single key currently signing tokens.
+	KeyActive
+	// KeyRetired keys no longer sign but keep verifying, and stay published,
+	// until every token they signed has expired.
+	KeyRetired
+	// KeyExpired keys are no longer accepted.
+	KeyExpired
+)
+
+func (s KeyState) String() string {
+	switch s {
+	case KeyNext:
+		return "next"
+	case KeyActive:
+		return "active"
+	case KeyRetired:
+		return "retired"
+	default:
+		return "expired"
+	}
+}
+
+type scheduledKey struct {
+	key        Key
+	activeFrom time.Time
+}
+
+// KeyRing is a signing key rotation schedule. Each key becomes the active
+// signing key at its activation time and is superseded by the next one.
+// Because states derive from the clock rather than from mutations, every
+// instance sharing the same schedule rotates at the same moment.
+type KeyRing struct {
+	mu        sync.RWMutex
+	keys      []scheduledKey // ordered by activeFrom
+	retention time.Duration
+	now       func() time.Time
+}
+
+// NewKeyRing returns an empty ring that keeps retired keys for retention,
+// which should be at least the access token TTL.
+func NewKeyRing(retention time.Duration) *KeyRing {
+	return &KeyRing{retention: retention, now: time.Now}
+}
+
+// Add schedules k to become the signing key at activeFrom. Use the zero time
+// for a key that is active immediately.
+func (r *KeyRing) Add(k Key, activeFrom time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, sk := range r.keys {
+		if sk.key.ID == k.ID {
+			return fmt.Errorf("token: duplicate key id %q", k.ID)
+		}
+		if sk.activeFrom.Equal(activeFrom) {
+			return fmt.Errorf("token: keys %q and %q share activation time %s", sk.key.ID, k.ID, activeFrom)
+		}
+	}
+	r.keys = append(r.keys, scheduledKey{key: k, activeFrom: activeFrom})
+	sort.Slice(r.keys, func(i, j int) bool { return r.keys[i].activeFrom.Before(r.keys[j].activeFrom) })
+	return nil
+}
+
+// state must be called with r.mu held.
+func (r *KeyRing) state(i int, now time.Time) KeyState {
+	if now.Before(r.keys[i].activeFrom) {
+		return KeyNext
+	}
+	if i+1 == len(r.keys) || now.Before(r.keys[i+1].activeFrom) {
+		return KeyActive
+	}
+	if now.Before(r.keys[i+1].activeFrom.Add(r.retention)) {
+		return KeyRetired
+	}
+	return KeyExpired
+}
+
+// State reports the current state of the key with the given ID.
+func (r *KeyRing) State(kid string) (KeyState, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	for i, sk := range r.keys {
+		if sk.key.ID == kid {
+			return r.state(i, now), true
+		}
+	}
+	return KeyExpired, false
+}
+
+// Active returns the key tokens should be signed with right now.
+func (r *KeyRing) Active() (Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	for i, sk := range r.keys {
+		if r.state(i, now) == KeyActive {
+			if !sk.key.CanSign() {
+				return Key{}, fmt.Errorf("token: active key %q cannot sign", sk.key.ID)
+			}
+			return sk.key, nil
+		}
+	}
+	return Key{}, ErrNoActiveKey
+}
+
+// Lookup returns the key with the given ID if tokens signed by it are still
+// acceptable: it is active or retired. Next keys are not accepted yet.
+func (r *KeyRing) Lookup(kid string) (Key, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	for i, sk := range r.keys {
+		if sk.key.ID != kid {
+			continue
+		}
+		switch r.state(i, now) {
+		case KeyActive, KeyRetired:
+			return sk.key, true
+		}
+		return Key{}, false
+	}
+	return Key{}, false
+}
+
+// JWKS returns the public halves of every next, active and retired key.
+// HMAC keys are never published.
+func (r *KeyRing) JWKS() JWKS {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := r.now()
+	set := JWKS{Keys: []JWK{}}
+	for i, sk := range r.keys {
+		if r.state(i, now) == KeyExpired {
+			continue
+		}
+		if jwk, ok := sk.key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// keyRingFile is the on-disk format read by LoadKeyRingFile.
+type keyRingFile struct {
+	Keys []struct {
+		ID             string    `json:"kid"`
+		Alg            string    `json:"alg"`
+		PrivateKeyFile string    `json:"private_key_file"`
+		PublicKeyFile  string    `json:"public_key_file"`
+		SecretFile     string    `json:"secret_file"`
+		ActiveFrom     time.Time `json:"active_from"`
+	} `json:"keys"`
+}
+
+// LoadKeyRingFile reads a JSON rotation schedule such as
+//
+//	{"keys": [
+//	  {"kid": "2025-01", "alg": "EdDSA", "private_key_file": "2025-01.pem", "active_from": "2025-01-01T00:00:00Z"},
+//	  {"kid": "2025-04", "alg": "EdDSA", "private_key_file": "2025-04.pem", "active_from": "2025-04-01T00:00:00Z"}
+//	]}
+//
+// Key file paths are relative to the schedule file. HS256 keys use
+// secret_file instead of a PEM key; a key whose private half is held
+// elsewhere can be listed with public_key_file to keep verifying its tokens.
+func LoadKeyRingFile(path string, retention time.Duration) (*KeyRing, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyRingFile
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("token: parse %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	read := func(name string) ([]byte, error) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		return os.ReadFile(name)
+	}
+
+	ring := NewKeyRing(retention)
+	for _, entry := range f.Keys {
+		var k Key
+		switch {
+		case entry.Alg == "HS256":
+			secret, err := read(entry.SecretFile)
+			if err != nil {
+				return nil, err
+			}
+			k = NewHMACKey(entry.ID, secret)
+		case entry.PrivateKeyFile != "":
+			pemBytes, err := read(entry.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if k, err = ParsePrivateKeyPEM(entry.ID, entry.Alg, pemBytes); err != nil {
+				return nil, err
+			}
+		default:
+			pemBytes, err := read(entry.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if k, err = ParsePublicKeyPEM(entry.ID, entry.Alg, pemBytes); err != nil {
+				return nil, err
+			}
+		}
+		if err := ring.Add(k, entry.ActiveFrom); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := ring.Active(); err != nil {
+		return nil, fmt.Errorf("token: %s: %w", path, err)
+	}
+	return ring, nil
+}
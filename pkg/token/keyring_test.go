@@ -0,0 +1,230 @@
+package token
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyRing_Schedule(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	retention := time.Hour
+
+	ring := NewKeyRing(retention)
+	current := start
+	ring.now = func() time.Time { return current }
+
+	oldKey := NewHMACKey("old", []byte("old-secret"))
+	newKey := NewHMACKey("new", []byte("new-secret"))
+	if err := ring.Add(oldKey, time.Time{}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := ring.Add(newKey, start.Add(24*time.Hour)); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		active   string
+		oldState KeyState
+		newState KeyState
+	}{
+		{"before rotation", start, "old", KeyActive, KeyNext},
+		{"at rotation", start.Add(24 * time.Hour), "new", KeyRetired, KeyActive},
+		{"within retention", start.Add(24*time.Hour + 59*time.Minute), "new", KeyRetired, KeyActive},
+		{"after retention", start.Add(25 * time.Hour), "new", KeyExpired, KeyActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current = tt.at
+
+			active, err := ring.Active()
+			if err != nil {
+				t.Fatalf("Active() failed: %v", err)
+			}
+			if active.ID != tt.active {
+				t.Errorf("Expected active key %s, got %s", tt.active, active.ID)
+			}
+			if s, _ := ring.State("old"); s != tt.oldState {
+				t.Errorf("Expected old key %s, got %s", tt.oldState, s)
+			}
+			if s, _ := ring.State("new"); s != tt.newState {
+				t.Errorf("Expected new key %s, got %s", tt.newState, s)
+			}
+
+			_, oldOK := ring.Lookup("old")
+			wantOld := tt.oldState == KeyActive || tt.oldState == KeyRetired
+			if oldOK != wantOld {
+				t.Errorf("Lookup(old) = %v, want %v", oldOK, wantOld)
+			}
+			_, newOK := ring.Lookup("new")
+			if newOK != (tt.newState == KeyActive) {
+				t.Errorf("Lookup(new) = %v, want %v", newOK, tt.newState == KeyActive)
+			}
+		})
+	}
+}
+
+func TestKeyRing_AddRejectsDuplicates(t *testing.T) {
+	ring := NewKeyRing(time.Hour)
+	if err := ring.Add(NewHMACKey("a", []byte("x")), time.Time{}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := ring.Add(NewHMACKey("a", []byte("y")), time.Now()); err == nil {
+		t.Error("Add() should reject a duplicate kid")
+	}
+	if err := ring.Add(NewHMACKey("b", []byte("y")), time.Time{}); err == nil {
+		t.Error("Add() should reject a duplicate activation time")
+	}
+}
+
+func TestKeyRing_NoActiveKey(t *testing.T) {
+	ring := NewKeyRing(time.Hour)
+	if err := ring.Add(NewHMACKey("future", []byte("x")), time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if _, err := ring.Active(); !errors.Is(err, ErrNoActiveKey) {
+		t.Errorf("Expected ErrNoActiveKey, got %v", err)
+	}
+	if _, err := NewJWTManagerWithKeyRing(ring, time.Minute); err == nil {
+		t.Error("NewJWTManagerWithKeyRing() should fail without an active key")
+	}
+}
+
+func TestKeyRing_JWKS(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ring := NewKeyRing(time.Hour)
+	ring.now = func() time.Time { return start }
+
+	for i, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		privPEM, _ := testKeyPEM(t, alg)
+		k, err := ParsePrivateKeyPEM(alg, alg, privPEM)
+		if err != nil {
+			t.Fatalf("ParsePrivateKeyPEM() failed: %v", err)
+		}
+		if err := ring.Add(k, start.Add(time.Duration(i-1)*time.Minute)); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	// HMAC secrets must never be published
+	if err := ring.Add(NewHMACKey("hmac", []byte("secret")), start.Add(-time.Hour)); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	set := ring.JWKS()
+	if len(set.Keys) != 3 {
+		t.Fatalf("Expected 3 published keys, got %d", len(set.Keys))
+	}
+	want := map[string]struct{ kty, crv string }{
+		"RS256": {"RSA", ""},
+		"ES256": {"EC", "P-256"},
+		"EdDSA": {"OKP", "Ed25519"},
+	}
+	for _, jwk := range set.Keys {
+		w, ok := want[jwk.KeyID]
+		if !ok {
+			t.Errorf("Unexpected key %s", jwk.KeyID)
+			continue
+		}
+		if jwk.KeyType != w.kty || jwk.Curve != w.crv || jwk.Alg != jwk.KeyID || jwk.Use != "sig" {
+			t.Errorf("Unexpected JWK %+v", jwk)
+		}
+	}
+	for _, jwk := range set.Keys {
+		switch jwk.KeyType {
+		case "RSA":
+			if jwk.N == "" || jwk.E != "AQAB" {
+				t.Errorf("Unexpected RSA parameters n=%q e=%q", jwk.N, jwk.E)
+			}
+		case "EC":
+			if len(jwk.X) != 43 || len(jwk.Y) != 43 {
+				t.Errorf("Expected 32-byte coordinates, got x=%q y=%q", jwk.X, jwk.Y)
+			}
+		case "OKP":
+			if len(jwk.X) != 43 {
+				t.Errorf("Expected 32-byte public key, got %q", jwk.X)
+			}
+		}
+	}
+}
+
+func TestJWTManager_RotatesWithRing(t *testing.T) {
+	start := time.Now()
+	ring := NewKeyRing(time.Hour)
+	current := start
+	ring.now = func() time.Time { return current }
+
+	_ = ring.Add(NewHMACKey("old", []byte("old-secret")), time.Time{})
+	_ = ring.Add(NewHMACKey("new", []byte("new-secret")), start.Add(time.Minute))
+
+	jm, err := NewJWTManagerWithKeyRing(ring, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("NewJWTManagerWithKeyRing() failed: %v", err)
+	}
+	oldToken, _ := jm.Generate(uuid.New(), "test@example.com")
+
+	current = start.Add(2 * time.Minute)
+	newToken, _ := jm.Generate(uuid.New(), "test@example.com")
+	if newToken == oldToken {
+		t.Fatal("Expected a different token after rotation")
+	}
+	if _, err := jm.Verify(oldToken); err != nil {
+		t.Errorf("Verify() of token signed by retired key failed: %v", err)
+	}
+	if _, err := jm.Verify(newToken); err != nil {
+		t.Errorf("Verify() of token signed by active key failed: %v", err)
+	}
+
+	current = start.Add(2 * time.Hour)
+	if _, err := jm.Verify(oldToken); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey for expired key, got %v", err)
+	}
+}
+
+func TestLoadKeyRingFile(t *testing.T) {
+	dir := t.TempDir()
+	privPEM, pubPEM := testKeyPEM(t, "EdDSA")
+	files := map[string][]byte{
+		"current.pem": privPEM,
+		"legacy.pub":  pubPEM,
+		"hmac.secret": []byte("secret"),
+		"keys.json": []byte(`{"keys": [
+			{"kid": "legacy", "alg": "EdDSA", "public_key_file": "legacy.pub", "active_from": "2024-01-01T00:00:00Z"},
+			{"kid": "hmac", "alg": "HS256", "secret_file": "hmac.secret", "active_from": "2024-06-01T00:00:00Z"},
+			{"kid": "current", "alg": "EdDSA", "private_key_file": "current.pem", "active_from": "2025-01-01T00:00:00Z"}
+		]}`),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ring, err := LoadKeyRingFile(filepath.Join(dir, "keys.json"), time.Hour)
+	if err != nil {
+		t.Fatalf("LoadKeyRingFile() failed: %v", err)
+	}
+	active, err := ring.Active()
+	if err != nil {
+		t.Fatalf("Active() failed: %v", err)
+	}
+	if active.ID != "current" {
+		t.Errorf("Expected active key current, got %s", active.ID)
+	}
+
+	// The only active key being verification-only is a configuration error
+	if err := os.WriteFile(filepath.Join(dir, "keys.json"), []byte(`{"keys": [
+		{"kid": "legacy", "alg": "EdDSA", "public_key_file": "legacy.pub"}
+	]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyRingFile(filepath.Join(dir, "keys.json"), time.Hour); err == nil {
+		t.Error("LoadKeyRingFile() should fail when the active key cannot sign")
+	}
+}
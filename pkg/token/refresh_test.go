@@ -0,0 +1,41 @@
+package token
+
+import (
+	"testing"
+)
+
+func TestNewRefreshToken(t *testing.T) {
+	a, err := NewRefreshToken()
+	if err != nil {
+		t.Fatalf("NewRefreshToken() failed: %v", err)
+	}
+	b, err := NewRefreshToken()
+	if err != nil {
+		t.Fatalf("NewRefreshToken() failed: %v", err)
+	}
+
+	if len(a) != 43 {
+		t.Errorf("Expected 43-character token, got %d", len(a))
+	}
+
+	if a == b {
+		t.Error("NewRefreshToken() should return unique tokens")
+	}
+}
+
+func TestHashRefreshToken(t *testing.T) {
+	tok, _ := NewRefreshToken()
+
+	if HashRefreshToken(tok) != HashRefreshToken(tok) {
+		t.Error("HashRefreshToken() should be deterministic")
+	}
+
+	if HashRefreshToken(tok) == tok {
+		t.Error("HashRefreshToken() returned the token itself")
+	}
+
+	other, _ := NewRefreshToken()
+	if HashRefreshToken(tok) == HashRefreshToken(other) {
+		t.Error("different tokens should hash differently")
+	}
+}
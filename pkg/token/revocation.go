@@ -0,0 +1,117 @@
+package token
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationList is a denylist of token and session IDs, and of users whose
+// tokens issued before a cutoff are no longer valid. Entries only need to
+// outlive the tokens they cover, so each carries its own expiry.
+type RevocationList interface {
+	Revoke(id string, until time.Time)
+	IsRevoked(id string) bool
+	// RevokeBefore rejects the tokens of subject issued before cutoff.
+	RevokeBefore(subject string, cutoff, until time.Time)
+	// RevokedBefore returns the cutoff for subject, or the zero time.
+	RevokedBefore(subject string) time.Time
+}
+
+// revocationSweepInterval is how often MemoryRevocationList drops expired
+// entries.
+const revocationSweepInterval = time.Minute
+
+// MemoryRevocationList is a process-local RevocationList.
+type MemoryRevocationList struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	cutoffs   map[string]cutoff
+	lastSweep time.Time
+}
+
+type cutoff struct {
+	before time.Time
+	until  time.Time
+}
+
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{
+		entries: make(map[string]time.Time),
+		cutoffs: make(map[string]cutoff),
+	}
+}
+
+func (l *MemoryRevocationList) Revoke(id string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(time.Now())
+	if exp, ok := l.entries[id]; !ok || until.After(exp) {
+		l.entries[id] = until
+	}
+}
+
+func (l *MemoryRevocationList) IsRevoked(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	exp, ok := l.entries[id]
+	if !ok {
+		return false
+	}
+	if time.Now().After(exp) {
+		delete(l.entries, id)
+		return false
+	}
+	return true
+}
+
+func (l *MemoryRevocationList) RevokeBefore(subject string, before, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(time.Now())
+	c := l.cutoffs[subject]
+	if before.After(c.before) {
+		c.before = before
+	}
+	if until.After(c.until) {
+		c.until = until
+	}
+	l.cutoffs[subject] = c
+}
+
+func (l *MemoryRevocationList) RevokedBefore(subject string) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.cutoffs[subject]
+	if !ok {
+		return time.Time{}
+	}
+	if time.Now().After(c.until) {
+		delete(l.cutoffs, subject)
+		return time.Time{}
+	}
+	return c.before
+}
+
+// sweep drops expired entries. It runs at most once per
+// revocationSweepInterval, so Revoke stays cheap however many entries there
+// are.
+func (l *MemoryRevocationList) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < revocationSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for k, exp := range l.entries {
+		if now.After(exp) {
+			delete(l.entries, k)
+		}
+	}
+	for k, c := range l.cutoffs {
+		if now.After(c.until) {
+			delete(l.cutoffs, k)
+		}
+	}
+}
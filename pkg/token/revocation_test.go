@@ -0,0 +1,69 @@
+package token
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryRevocationList(t *testing.T) {
+	l := NewMemoryRevocationList()
+
+	if l.IsRevoked("a") {
+		t.Error("unknown id should not be revoked")
+	}
+
+	l.Revoke("a", time.Now().Add(time.Minute))
+	if !l.IsRevoked("a") {
+		t.Error("revoked id should be reported")
+	}
+
+	// A shorter expiry must not shorten an existing entry
+	l.Revoke("a", time.Now().Add(-time.Minute))
+	if !l.IsRevoked("a") {
+		t.Error("re-revoking with an earlier expiry should keep the later one")
+	}
+}
+
+func TestMemoryRevocationList_Expiry(t *testing.T) {
+	l := NewMemoryRevocationList()
+
+	l.Revoke("a", time.Now().Add(10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+
+	if l.IsRevoked("a") {
+		t.Error("entry should lapse after its expiry")
+	}
+
+	// Expired entries are swept by the first write after the sweep interval
+	l.Revoke("b", time.Now().Add(-time.Millisecond))
+	l.Revoke("c", time.Now().Add(time.Minute))
+	if _, ok := l.entries["b"]; !ok {
+		t.Error("entries should only be swept once per interval")
+	}
+	l.lastSweep = time.Now().Add(-revocationSweepInterval)
+	l.Revoke("d", time.Now().Add(time.Minute))
+	if _, ok := l.entries["b"]; ok {
+		t.Error("expired entries should be swept")
+	}
+}
+
+func TestMemoryRevocationList_RevokeBefore(t *testing.T) {
+	l := NewMemoryRevocationList()
+	now := time.Now()
+
+	if !l.RevokedBefore("u").IsZero() {
+		t.Error("unknown subject should have no cutoff")
+	}
+
+	l.RevokeBefore("u", now, now.Add(time.Minute))
+	// An earlier cutoff must not move an existing one back
+	l.RevokeBefore("u", now.Add(-time.Hour), now.Add(time.Minute))
+	if got := l.RevokedBefore("u"); !got.Equal(now) {
+		t.Errorf("Expected cutoff %v, got %v", now, got)
+	}
+
+	l.RevokeBefore("v", now, now.Add(-time.Millisecond))
+	if !l.RevokedBefore("v").IsZero() {
+		t.Error("cutoff should lapse after its expiry")
+	}
+}
@@ -0,0 +1,94 @@
+// Package totp implements time-based one-time passwords (RFC 6238) with the
+// parameters authenticator apps assume by default: HMAC-SHA1, six digits and
+// a 30-second step.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// Step is how long each code is valid.
+	Step = 30 * time.Second
+	// Digits is the length of a code.
+	Digits = 6
+
+	secretSize = 20 // 160 bits, as RFC 4226 recommends
+)
+
+// Encoding is how secrets are written in provisioning URIs and shown to
+// users who type them in by hand.
+var Encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random shared secret.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// Counter returns the time step t falls in.
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Step/time.Second)
+}
+
+// Code returns the code for the given time step.
+func Code(secret []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, bin%1_000_000)
+}
+
+// Validate checks code against the steps within skew of the one t falls
+// in, to tolerate clock drift and slow typing, and returns the step it
+// matched. Callers should refuse steps at or before the last one accepted
+// for the same secret, so a code cannot be replayed.
+func Validate(secret []byte, code string, t time.Time, skew int) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	now := Counter(t)
+	for i := -skew; i <= skew; i++ {
+		counter := now + int64(i)
+		if subtle.ConstantTimeCompare([]byte(Code(secret, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth:// provisioning URI for secret, which
+// authenticator apps read from a QR code. issuer names the service and
+// account the user within it.
+func URI(issuer, account string, secret []byte) string {
+	q := url.Values{}
+	q.Set("secret", Encoding.EncodeToString(secret))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Step/time.Second)))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
@@ -0,0 +1,92 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 appendix B test vectors.
+var rfc6238Secret = []byte("12345678901234567890")
+
+func TestCode_RFC6238(t *testing.T) {
+	// The RFC lists eight-digit codes; six-digit codes are their last six.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		if got := Code(rfc6238Secret, Counter(time.Unix(tt.unix, 0))); got != tt.want {
+			t.Errorf("Code(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := Counter(now)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current", Code(rfc6238Secret, step), step, true},
+		{"previous", Code(rfc6238Secret, step-1), step - 1, true},
+		{"next", Code(rfc6238Secret, step+1), step + 1, true},
+		{"too old", Code(rfc6238Secret, step-2), 0, false},
+		{"wrong", "000000", 0, false},
+		{"short", "12345", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Validate(rfc6238Secret, tt.code, now, 1)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Errorf("Validate() = %d, %v; want %d, %v", got, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() failed: %v", err)
+	}
+	b, _ := NewSecret()
+	if len(a) != secretSize {
+		t.Errorf("Expected %d-byte secret, got %d", secretSize, len(a))
+	}
+	if string(a) == string(b) {
+		t.Error("NewSecret() should return unique secrets")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Identity Service", "user@example.com", rfc6238Secret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("URI() is not a URL: %v", err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		t.Errorf("Expected otpauth://totp, got %s://%s", u.Scheme, u.Host)
+	}
+	if u.Path != "/Identity Service:user@example.com" {
+		t.Errorf("Unexpected label %q", u.Path)
+	}
+	q := u.Query()
+	if q.Get("secret") != "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" {
+		t.Errorf("Unexpected secret %q", q.Get("secret"))
+	}
+	if q.Get("issuer") != "Identity Service" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("Unexpected parameters %v", q)
+	}
+}